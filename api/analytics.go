@@ -0,0 +1,42 @@
+package api
+
+import (
+	"FizzBuzz/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxAnalyticsTop is the maximum number of values returned per field
+const maxAnalyticsTop = 100
+
+type analyticsController struct {
+	as     service.AnalyticsService
+	logger *zap.Logger
+}
+
+func SetupAnalyticsAPI(as service.AnalyticsService, router *gin.Engine, logger *zap.Logger) {
+	ac := &analyticsController{as: as, logger: logger}
+	router.GET("/metrics/analytics", ac.Report)
+}
+
+func (ac *analyticsController) Report(c *gin.Context) {
+	top, err := strconv.ParseInt(c.DefaultQuery("top", "10"), 10, 64)
+	if err != nil || top < 1 || top > maxAnalyticsTop {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "top",
+			Message:   "Should be between 1 and " + strconv.Itoa(maxAnalyticsTop),
+		}}})
+		return
+	}
+
+	report, err := ac.as.Report(top)
+	if err != nil {
+		ac.logger.Error("Failed to build analytics report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
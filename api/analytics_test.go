@@ -0,0 +1,60 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	mock_service "FizzBuzz/service/mock"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type AnalyticsControllerSuite struct {
+	suite.Suite
+	ctrl   *gomock.Controller
+	Router *gin.Engine
+	mas    *mock_service.MockAnalyticsService
+}
+
+func (suite *AnalyticsControllerSuite) SetupTest() {
+	var err error
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mas = mock_service.NewMockAnalyticsService(suite.ctrl)
+	suite.Router, err = Setup(nil, nil, zap.NewExample(), WithAnalytics(suite.mas))
+	suite.Require().NoError(err)
+}
+
+func (suite *AnalyticsControllerSuite) TestReport() {
+	suite.mas.EXPECT().Report(int64(3)).Return(&domain.AnalyticsReport{
+		Fields: map[string][]domain.AnalyticsValue{"fst_mod": {{Value: "3", Count: 12}}},
+		Limits: []domain.HistogramBucket{{UpperBound: "+Inf", Count: 12}},
+	}, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics/analytics").
+		Query("top", "3").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.fields.fst_mod[0].value`, "3")).
+		Assert(jsonpath.Equal(`$.limits[0].le`, "+Inf")).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics/analytics").
+		Query("top", "0").
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal(`$.errors[0].field_name`, "top")).
+		End()
+}
+
+func TestAnalyticsControllerSuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsControllerSuite))
+}
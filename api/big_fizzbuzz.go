@@ -0,0 +1,64 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type inputBigFizzBuzzRequest struct {
+	domain.BigFizzBuzzRequest
+}
+
+func (i *inputBigFizzBuzzRequest) inputValidator() ValidationFormatter {
+	return ValidationFormatter{
+		structToJson: map[string]string{
+			"FstModulo": "fst_mod",
+			"SndModulo": "snd_mod",
+			"Start":     "start",
+			"End":       "end",
+			"FstStr":    "fst_str",
+			"SndStr":    "snd_str",
+		},
+	}
+}
+
+func ParseBigError(err error) (int, ErrorResponse) {
+	switch {
+	case errors.Is(err, service.ErrBigInvalidModulo):
+		return http.StatusBadRequest, ErrorResponse{Message: "Modulos should be greater than or equal to 1"}
+	case errors.Is(err, service.ErrBigInvalidRange):
+		return http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "start",
+			Message:   "Should be less than or equal to end",
+		}}}
+	case errors.Is(err, service.ErrBigTooManyTerms):
+		return http.StatusBadRequest, ErrorResponse{
+			Message: "Too many terms, at most " + strconv.Itoa(service.MaxBigTerms),
+		}
+	case errors.Is(err, service.ErrBigMalformed):
+		return http.StatusBadRequest, ErrorResponse{Message: "Numbers should be decimal integers"}
+	}
+	return http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"}
+}
+
+// Big computes the terms of a window of numbers given as decimal strings, far beyond int64
+func (fb *fizzBuzzController) Big(c *gin.Context) {
+	var inp inputBigFizzBuzzRequest
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+
+	res, err := fb.fbs.BigFizzBuzz(inp.BigFizzBuzzRequest)
+	if err != nil {
+		code, errResp := ParseBigError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/service"
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCapturedBody is the biggest body kept by the capture, bigger ones are skipped
+const maxCapturedBody = 1 << 20
+
+var capturedRoutes = map[string]bool{
+	http.MethodPost + " /fizzbuzz": true,
+}
+
+// CaptureRequest samples the bodies of captured routes and hands them to the
+// capture service once the response status is known.
+func CaptureRequest(cs service.CaptureService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		title := c.Request.Method + " " + c.FullPath()
+		if !capturedRoutes[title] || !cs.Sample() {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCapturedBody+1))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+		if len(body) > maxCapturedBody {
+			c.Next()
+			return
+		}
+
+		timestamp := time.Now().UTC()
+		c.Next()
+
+		id, err := usecase.RandomID(8)
+		if err != nil {
+			return
+		}
+		cs.Capture(domain.CapturedRequest{
+			RequestID: id,
+			Title:     title,
+			Body:      string(body),
+			Timestamp: timestamp,
+			Client:    ClientID(c),
+			Status:    c.Writer.Status(),
+		})
+	}
+}
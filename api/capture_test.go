@@ -0,0 +1,89 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	mock_repository "FizzBuzz/repository/mock"
+	"FizzBuzz/service"
+	mock_service "FizzBuzz/service/mock"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type CaptureMiddlewareSuite struct {
+	suite.Suite
+	logger        *zap.Logger
+	ctrl          *gomock.Controller
+	Router        *gin.Engine
+	mockCacheRepo *mock_repository.MockCacheCounterRepository
+	mcs           *mock_service.MockCaptureService
+}
+
+func (suite *CaptureMiddlewareSuite) SetupTest() {
+	var err error
+	suite.logger = zap.NewExample()
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mockCacheRepo = mock_repository.NewMockCacheCounterRepository(suite.ctrl)
+	suite.mcs = mock_service.NewMockCaptureService(suite.ctrl)
+	suite.Router, err = Setup(service.NewFizzBuzzService(suite.logger),
+		service.NewMetricService(suite.mockCacheRepo, domain.RankByHits, suite.logger),
+		suite.logger,
+		WithCapture(suite.mcs))
+	suite.Require().NoError(err)
+}
+
+func (suite *CaptureMiddlewareSuite) TestCaptureSampled() {
+	body := `{"fst_mod": 3, "snd_mod": 5, "limit": 15, "fst_str": "fizz", "snd_str": "buzz"}`
+	suite.mockCacheRepo.EXPECT().IncrementRequest(gomock.Any(), gomock.Any(), gomock.Any())
+	suite.mockCacheRepo.EXPECT().AddCosts(gomock.Any(), gomock.Len(1), gomock.Len(1))
+	suite.mcs.EXPECT().Sample().Return(true)
+	suite.mcs.EXPECT().Capture(gomock.Any()).Do(func(record domain.CapturedRequest) {
+		suite.Equal("POST /fizzbuzz", record.Title)
+		suite.Equal(body, record.Body)
+		suite.Equal(http.StatusOK, record.Status)
+		suite.NotEmpty(record.Client)
+		suite.NotEmpty(record.RequestID)
+	})
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz").
+		Body(body).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$[14]`, "fizzbuzz")).
+		End()
+}
+
+func (suite *CaptureMiddlewareSuite) TestCaptureNotSampled() {
+	suite.mockCacheRepo.EXPECT().IncrementRequest(gomock.Any(), gomock.Any(), gomock.Any())
+	suite.mockCacheRepo.EXPECT().AddCosts(gomock.Any(), gomock.Len(1), gomock.Len(1))
+	suite.mcs.EXPECT().Sample().Return(false)
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz").
+		Body(`{"fst_mod": 3, "snd_mod": 5, "limit": 15, "fst_str": "fizz", "snd_str": "buzz"}`).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		End()
+}
+
+func (suite *CaptureMiddlewareSuite) TestOtherRoutesIgnored() {
+	apitest.New().
+		Handler(suite.Router).
+		Get("/").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		End()
+}
+
+func TestCaptureMiddlewareSuite(t *testing.T) {
+	suite.Run(t, new(CaptureMiddlewareSuite))
+}
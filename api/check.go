@@ -0,0 +1,107 @@
+package api
+
+import (
+	"FizzBuzz/service"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTermLength is the maximum length of a submitted term
+const maxTermLength = 64 * 1024
+
+// lineReader reads a term per line, line endings are dropped
+type lineReader struct {
+	scanner *bufio.Scanner
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxTermLength)
+	return &lineReader{scanner: scanner}
+}
+
+func (lr *lineReader) ReadTerm() (string, error) {
+	if !lr.scanner.Scan() {
+		if err := lr.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return strings.TrimSuffix(lr.scanner.Text(), "\r"), nil
+}
+
+// jsonArrayReader reads the strings of a JSON array one at a time
+type jsonArrayReader struct {
+	decoder *json.Decoder
+	started bool
+	read    int
+}
+
+func newJSONArrayReader(r io.Reader) *jsonArrayReader {
+	return &jsonArrayReader{decoder: json.NewDecoder(r)}
+}
+
+func (jr *jsonArrayReader) ReadTerm() (string, error) {
+	if !jr.started {
+		token, err := jr.decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return "", errors.New("submission should be an array of strings")
+		}
+		jr.started = true
+	}
+	if !jr.decoder.More() {
+		// Reads the closing bracket
+		if _, err := jr.decoder.Token(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	var term string
+	jr.read++
+	if err := jr.decoder.Decode(&term); err != nil {
+		return "", fmt.Errorf("term %d should be a string: %w", jr.read, err)
+	}
+	return term, nil
+}
+
+// Check grades the sequence of the body against the one of the request given in
+// the query. The body is a JSON array of strings, or a term per line as text.
+func (fb *fizzBuzzController) Check(c *gin.Context) {
+	var inp inputFizzBuzzRequest
+	if err := c.ShouldBindQuery(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	inp.Normalize()
+
+	var submission service.TermReader
+	switch c.ContentType() {
+	case gin.MIMEJSON:
+		submission = newJSONArrayReader(c.Request.Body)
+	case gin.MIMEPlain, "":
+		submission = newLineReader(c.Request.Body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, ErrorResponse{Message: "Submission should be application/json or text/plain"})
+		return
+	}
+
+	report, err := fb.fbs.Check(inp.FizzBuzzRequest, submission)
+	if errors.Is(err, service.ErrCheckSubmission) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid submission: " + err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"FizzBuzz/domain/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIDKey is where IdentifyClient keeps the caller in the context
+const clientIDKey = "fizzbuzz/client"
+
+// IdentifyClient identifies the caller by its API key when it is one of apiKeys,
+// or by its IP otherwise. Unknown keys are ignored so they can't be made up to
+// inflate the number of clients. Keys and IPs are hashed so neither is stored.
+func IdentifyClient(apiKeys []string) gin.HandlerFunc {
+	known := make(map[string]struct{}, len(apiKeys))
+	for _, key := range apiKeys {
+		if key != "" {
+			known[key] = struct{}{}
+		}
+	}
+	return func(c *gin.Context) {
+		client := "ip:" + c.ClientIP()
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			if _, ok := known[key]; ok {
+				client = "key:" + key
+			}
+		}
+		if hash, err := usecase.GetHash([]byte(client)); err == nil {
+			c.Set(clientIDKey, hash)
+		}
+		c.Next()
+	}
+}
+
+// ClientID returns the caller identified by IdentifyClient, empty without it
+func ClientID(c *gin.Context) string {
+	return c.GetString(clientIDKey)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentifyClient(t *testing.T) {
+	router := gin.New()
+	require.NoError(t, router.SetTrustedProxies(nil))
+	router.Use(IdentifyClient([]string{"known"}))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, ClientID(c)) })
+
+	client := func(remote string, headers map[string]string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	byIP := client("10.0.0.1:1234", nil)
+	require.NotEmpty(t, byIP)
+	// Forwarding headers of an untrusted proxy and unknown keys are ignored
+	require.Equal(t, byIP, client("10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}))
+	require.Equal(t, byIP, client("10.0.0.1:1234", map[string]string{"X-API-Key": "made-up"}))
+	require.NotEqual(t, byIP, client("10.0.0.2:1234", nil))
+
+	byKey := client("10.0.0.1:1234", map[string]string{"X-API-Key": "known"})
+	require.NotEqual(t, byIP, byKey)
+	require.Equal(t, byKey, client("10.0.0.2:1234", map[string]string{"X-API-Key": "known"}))
+}
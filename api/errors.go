@@ -1,8 +1,11 @@
 package api
 
 import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/numfmt"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -19,6 +22,8 @@ type ValidationFormatter struct {
 type ErrorField struct {
 	FieldName string `json:"field_name"`
 	Message   string `json:"message"`
+	// Position is the column of the error in an expression
+	Position int `json:"position,omitempty"`
 }
 
 func (v ValidationFormatter) getErrorMsg(fe validator.FieldError) string {
@@ -29,16 +34,47 @@ func (v ValidationFormatter) getErrorMsg(fe validator.FieldError) string {
 		return fmt.Sprintf("Should be less than %s", fe.Param())
 	case "gte":
 		return fmt.Sprintf("Should be greater than %s", fe.Param())
+	case "min":
+		return fmt.Sprintf("Should contain at least %s item", fe.Param())
+	case "max":
+		return fmt.Sprintf("Should contain at most %s items", fe.Param())
+	case "url":
+		return "Should be an URL"
+	case "oneof":
+		return fmt.Sprintf("Should be one of %s", fe.Param())
+	case "template":
+		if _, err := numfmt.Compile(fe.Value().(string)); err != nil {
+			return "Invalid template: " + err.Error()
+		}
+		return "Invalid template"
+	case "numberformat":
+		if _, err := numfmt.ParseNumberFormat(fe.Value().(string)); err != nil {
+			return "Invalid number format: " + err.Error()
+		}
+		return "Invalid number format"
+	case "bigint":
+		return fmt.Sprintf("Should be a decimal integer of at most %d digits", domain.MaxBigDigits)
 	}
 	return "Unknown error"
 }
 
+// fieldName translates the struct field to its json name, keeping the index of slice items
+func (v ValidationFormatter) fieldName(field string) string {
+	if name, ok := v.structToJson[field]; ok {
+		return name
+	}
+	if i := strings.IndexByte(field, '['); i > 0 {
+		return v.structToJson[field[:i]] + field[i:]
+	}
+	return ""
+}
+
 func (v ValidationFormatter) validate(err error) []ErrorField {
 	var ve validator.ValidationErrors
 	if errors.As(err, &ve) {
 		errList := make([]ErrorField, len(ve))
 		for i, fe := range ve {
-			errList[i] = ErrorField{v.structToJson[fe.Field()], v.getErrorMsg(fe)}
+			errList[i] = ErrorField{FieldName: v.fieldName(fe.Field()), Message: v.getErrorMsg(fe)}
 		}
 		return errList
 	}
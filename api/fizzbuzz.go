@@ -3,53 +3,205 @@ package api
 import (
 	"FizzBuzz/domain"
 	"FizzBuzz/service"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"go.uber.org/zap"
 )
 
+// DefaultBatchBudget is the default maximum sum of limits computed by one batch
+const DefaultBatchBudget = 1_000_000
+
 type fizzBuzzController struct {
-	fbs    service.FizzBuzzService
-	ms     service.MetricService
-	logger *zap.Logger
+	fbs         service.FizzBuzzService
+	ms          service.MetricService
+	batchBudget int
+	logger      *zap.Logger
 }
 
 type inputFizzBuzzRequest struct {
 	domain.FizzBuzzRequest
 }
 
+// batchItemResponse result holds the terms, explained ones with explain=true
+type batchItemResponse struct {
+	Result interface{}  `json:"result,omitempty"`
+	Errors []ErrorField `json:"errors,omitempty"`
+}
+
 func (i *inputFizzBuzzRequest) inputValidator() ValidationFormatter {
 	return ValidationFormatter{
 		structToJson: map[string]string{
-			"FstModulo": "fst_mod",
-			"SndModulo": "snd_mod",
-			"Limit":     "limit",
-			"FstStr":    "fst_str",
-			"SndStr":    "snd_str",
+			"FstModulo":    "fst_mod",
+			"SndModulo":    "snd_mod",
+			"Limit":        "limit",
+			"FstStr":       "fst_str",
+			"SndStr":       "snd_str",
+			"NumberFormat": "number_format",
 		},
 	}
 }
 
+// explainQuery reads the explain query parameter, false when the error response
+// has been written.
+func explainQuery(c *gin.Context) (explain bool, ok bool) {
+	value := c.Query("explain")
+	if value == "" {
+		return false, true
+	}
+	explain, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "explain",
+			Message:   "Should be true or false",
+		}}})
+		return false, false
+	}
+	return explain, true
+}
+
+// compute returns the terms of a request, explained ones when explain is set
+func (fb *fizzBuzzController) compute(inp *inputFizzBuzzRequest, explain bool) (res interface{}, items int) {
+	if explain {
+		return fb.fbs.ExplainFizzBuzz(inp.FizzBuzzRequest), inp.Limit
+	}
+	return fb.fbs.SimpleFizzBuzz(inp.Limit, inp.FstModulo, inp.SndModulo, inp.FstStr, inp.SndStr, inp.NumberFormat), inp.Limit
+}
+
 func (fb *fizzBuzzController) Index(c *gin.Context) {
 	var inp inputFizzBuzzRequest
 	if err := c.ShouldBindJSON(&inp); err != nil {
 		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
 		return
 	}
+	inp.Normalize()
+	explain, ok := explainQuery(c)
+	if !ok {
+		return
+	}
 
-	if err := fb.ms.Increment(&inp); err != nil {
+	if err := fb.ms.Increment(&inp, ClientID(c)); err != nil {
 		fb.logger.Error("while incrementing request", zap.Error(err))
 	}
 
-	res := fb.fbs.SimpleFizzBuzz(inp.Limit, inp.FstModulo, inp.SndModulo, inp.FstStr, inp.SndStr)
+	start := time.Now()
+	res, items := fb.compute(&inp, explain)
+	duration := time.Since(start)
 	c.JSON(http.StatusOK, res)
+
+	cost := domain.RequestCost{Items: items, Duration: duration, Bytes: c.Writer.Size()}
+	if err := fb.ms.AddCosts([]domain.Identifiable{&inp}, []domain.RequestCost{cost}); err != nil {
+		fb.logger.Error("while adding request cost", zap.Error(err))
+	}
+}
+
+// Batch computes many fizzbuzz requests at once, each item is validated on its own
+// and invalid items do not prevent the others to be computed.
+func (fb *fizzBuzzController) Batch(c *gin.Context) {
+	explain, ok := explainQuery(c)
+	if !ok {
+		return
+	}
+	var items []json.RawMessage
+	if err := c.ShouldBindJSON(&items); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Body should be an array of fizzbuzz requests"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Batch is empty"})
+		return
+	}
+
+	res := make([]batchItemResponse, len(items))
+	inputs := make([]*inputFizzBuzzRequest, len(items))
+	valid := make([]domain.Identifiable, 0, len(items))
+	budget := 0
+	for i, item := range items {
+		var inp inputFizzBuzzRequest
+		if err := json.Unmarshal(item, &inp); err != nil {
+			res[i].Errors = []ErrorField{{Message: "Item should be a fizzbuzz request object"}}
+			continue
+		}
+		if err := binding.Validator.ValidateStruct(&inp); err != nil {
+			res[i].Errors = BuildValidationError(err, inp.inputValidator()).Fields
+			continue
+		}
+		inp.Normalize()
+		// Compared before adding so that huge limits cannot wrap the sum around
+		if inp.Limit > fb.batchBudget-budget {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{
+				Message: fmt.Sprintf("Sum of limits should be less than %d", fb.batchBudget),
+			})
+			return
+		}
+		budget += inp.Limit
+		inputs[i] = &inp
+		valid = append(valid, &inp)
+	}
+
+	if err := fb.ms.IncrementBatch(valid, ClientID(c)); err != nil {
+		fb.logger.Error("while incrementing batch", zap.Error(err))
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	durations := make([]time.Duration, len(items))
+	counts := make([]int, len(items))
+	for i, inp := range inputs {
+		if inp == nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, inp *inputFizzBuzzRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			start := time.Now()
+			res[i].Result, counts[i] = fb.compute(inp, explain)
+			durations[i] = time.Since(start)
+		}(i, inp)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, res)
+
+	// Response bytes are shared between items by their number of terms
+	costs := make([]domain.RequestCost, 0, len(valid))
+	for i, inp := range inputs {
+		if inp == nil {
+			continue
+		}
+		costs = append(costs, domain.RequestCost{
+			Items:    counts[i],
+			Duration: durations[i],
+			Bytes:    c.Writer.Size() * inp.Limit / budget,
+		})
+	}
+	if err := fb.ms.AddCosts(valid, costs); err != nil {
+		fb.logger.Error("while adding batch costs", zap.Error(err))
+	}
 }
 
 func SetupFizzBuzzAPI(fbService service.FizzBuzzService,
 	metricService service.MetricService,
+	batchBudget int,
 	router *gin.Engine,
 	logger *zap.Logger) {
-	c := &fizzBuzzController{fbs: fbService, ms: metricService, logger: logger}
+	c := &fizzBuzzController{fbs: fbService, ms: metricService, batchBudget: batchBudget, logger: logger}
 	router.POST("/fizzbuzz", c.Index)
+	router.POST("/fizzbuzz/batch", c.Batch)
+	router.POST("/fizzbuzz/big", c.Big)
+	router.GET("/fizzbuzz/term", c.Term)
+	router.GET("/fizzbuzz/terms", c.Terms)
+	router.GET("/fizzbuzz/stats", c.Stats)
+	router.POST("/fizzbuzz/stats", c.StatsRules)
+	router.POST("/fizzbuzz/check", c.Check)
+	router.POST("/fizzbuzz/infer", c.Infer)
 }
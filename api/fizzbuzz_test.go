@@ -1,6 +1,7 @@
 package api
 
 import (
+	"FizzBuzz/domain"
 	mock_repository "FizzBuzz/repository/mock"
 	"FizzBuzz/service"
 	"github.com/gin-gonic/gin"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
 	"net/http"
+	"net/url"
 	"testing"
 )
 
@@ -31,7 +33,7 @@ func (suite *FizzBuzzControllerSuite) SetupSuite() {
 	suite.mockCacheRepo = mock_repository.NewMockCacheCounterRepository(suite.ctrl)
 	// Services needed
 	suite.fbs = service.NewFizzBuzzService(suite.logger)
-	suite.ms = service.NewMetricService(suite.mockCacheRepo, suite.logger)
+	suite.ms = service.NewMetricService(suite.mockCacheRepo, domain.RankByHits, suite.logger)
 	suite.Router, err = Setup(suite.fbs, suite.ms, suite.logger)
 	suite.Require().NoError(err)
 }
@@ -101,9 +103,89 @@ func (suite *FizzBuzzControllerSuite) TestFizzbuzzJsonRequest() {
 				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "fst_mod"))
 			},
 		},
+		{
+			name: "Ok 200 templates",
+			body: `{
+	"fst_mod": 3,
+	"snd_mod": 5,
+	"limit": 15,
+	"fst_str": "fizz({n})",
+	"snd_str": "{n:roman}"
+}`,
+			expectedStatus: http.StatusOK,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$[2]`, "fizz(3)"))
+				r.Assert(jsonpath.Equal(`$[4]`, "V"))
+				r.Assert(jsonpath.Equal(`$[14]`, "fizz(15)XV"))
+			},
+		},
+		{
+			name: "Error invalid template",
+			body: `{
+	"fst_mod": 3,
+	"snd_mod": 5,
+	"limit": 15,
+	"fst_str": "fizz",
+	"snd_str": "{n:words}"
+}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "snd_str"))
+				r.Assert(jsonpath.Contains(`$.errors[0].message`, "Unknown formatter"))
+			},
+		},
+		{
+			name: "Error unescaped brace written as is before templates",
+			body: `{
+	"fst_mod": 3,
+	"snd_mod": 5,
+	"limit": 15,
+	"fst_str": "{fizz}",
+	"snd_str": "{{buzz}}"
+}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "fst_str"))
+				r.Assert(jsonpath.Contains(`$.errors[0].message`, "write '{{' for a brace"))
+			},
+		},
+		{
+			name: "Ok 200 number format",
+			body: `{
+	"fst_mod": 3,
+	"snd_mod": 5,
+	"limit": 15,
+	"fst_str": "fizz",
+	"snd_str": "buzz",
+	"number_format": "digits=devanagari,pad=2"
+}`,
+			expectedStatus: http.StatusOK,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$[0]`, "०१"))
+				r.Assert(jsonpath.Equal(`$[13]`, "१४"))
+				r.Assert(jsonpath.Equal(`$[14]`, "fizzbuzz"))
+			},
+		},
+		{
+			name: "Error invalid number format",
+			body: `{
+	"fst_mod": 3,
+	"snd_mod": 5,
+	"limit": 15,
+	"fst_str": "fizz",
+	"snd_str": "buzz",
+	"number_format": "roman,base=2"
+}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "number_format"))
+				r.Assert(jsonpath.Contains(`$.errors[0].message`, "can't be combined"))
+			},
+		},
 	}
 
-	suite.mockCacheRepo.EXPECT().IncrementRequest(gomock.Any(), gomock.Any()).MaxTimes(len(tests))
+	suite.mockCacheRepo.EXPECT().IncrementRequest(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(len(tests))
+	suite.mockCacheRepo.EXPECT().AddCosts(gomock.Any(), gomock.Len(1), gomock.Len(1)).MaxTimes(len(tests))
 	for _, test := range tests {
 		suite.Run(test.name, func() {
 			response := apitest.New().
@@ -119,6 +201,383 @@ func (suite *FizzBuzzControllerSuite) TestFizzbuzzJsonRequest() {
 	}
 }
 
+func (suite *FizzBuzzControllerSuite) TestFizzbuzzBatchRequest() {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		check          func(r *apitest.Response)
+	}{
+		{
+			name: "Ok 200 with invalid item",
+			body: `[
+	{"fst_mod": 3, "snd_mod": 5, "limit": 15, "fst_str": "fizz", "snd_str": "buzz"},
+	{"fst_mod": 3, "limit": 15, "fst_str": "fizz", "snd_str": "buzz"},
+	"not an object",
+	{"fst_mod": 2, "snd_mod": 4, "limit": 4, "fst_str": "two", "snd_str": "four"}
+]`,
+			expectedStatus: http.StatusOK,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Len(`$`, 4))
+				r.Assert(jsonpath.Equal(`$[0].result[14]`, "fizzbuzz"))
+				r.Assert(jsonpath.NotPresent(`$[0].errors`))
+				r.Assert(jsonpath.Equal(`$[1].errors[0].field_name`, "snd_mod"))
+				r.Assert(jsonpath.NotPresent(`$[1].result`))
+				r.Assert(jsonpath.Present(`$[2].errors`))
+				r.Assert(jsonpath.Equal(`$[3].result[3]`, "twofour"))
+			},
+		},
+		{
+			name:           "Error empty batch",
+			body:           `[]`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Present(`$.message`))
+			},
+		},
+		{
+			name:           "Error not an array",
+			body:           `{"fst_mod": 3}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Present(`$.message`))
+			},
+		},
+		{
+			name: "Error budget exceeded",
+			body: `[
+	{"fst_mod": 3, "snd_mod": 5, "limit": 1000000, "fst_str": "fizz", "snd_str": "buzz"},
+	{"fst_mod": 3, "snd_mod": 5, "limit": 1, "fst_str": "fizz", "snd_str": "buzz"}
+]`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Present(`$.message`))
+			},
+		},
+		{
+			name: "Error budget exceeded by wrapping limits",
+			body: `[
+	{"fst_mod": 3, "snd_mod": 5, "limit": 4611686018427387904, "fst_str": "fizz", "snd_str": "buzz"},
+	{"fst_mod": 3, "snd_mod": 5, "limit": 4611686018427387904, "fst_str": "fizz", "snd_str": "buzz"},
+	{"fst_mod": 3, "snd_mod": 5, "limit": 4611686018427387904, "fst_str": "fizz", "snd_str": "buzz"},
+	{"fst_mod": 3, "snd_mod": 5, "limit": 4611686018427387904, "fst_str": "fizz", "snd_str": "buzz"}
+]`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Present(`$.message`))
+			},
+		},
+	}
+
+	suite.mockCacheRepo.EXPECT().IncrementRequests(gomock.Any(), gomock.Len(2), gomock.Any()).Times(1)
+	suite.mockCacheRepo.EXPECT().AddCosts(gomock.Any(), gomock.Len(2), gomock.Len(2)).Times(1)
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			response := apitest.New().
+				Handler(suite.Router).
+				Post("/fizzbuzz/batch").
+				Body(test.body).
+				Expect(suite.T()).
+				Status(test.expectedStatus)
+			test.check(response)
+			response.End()
+		})
+	}
+}
+
+func (suite *FizzBuzzControllerSuite) TestFizzbuzzBigRequest() {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		check          func(r *apitest.Response)
+	}{
+		{
+			name: "Ok 200",
+			body: `{
+	"fst_mod": "3",
+	"snd_mod": "5",
+	"start": "1000000000000000000000000000000",
+	"end": "1000000000000000000000000000005",
+	"fst_str": "fizz",
+	"snd_str": "buzz"
+}`,
+			expectedStatus: http.StatusOK,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Len(`$`, 6))
+				r.Assert(jsonpath.Equal(`$[0]`, "buzz"))
+				r.Assert(jsonpath.Equal(`$[1]`, "1000000000000000000000000000001"))
+				r.Assert(jsonpath.Equal(`$[5]`, "fizzbuzz"))
+			},
+		},
+		{
+			name: "Error malformed numeral",
+			body: `{
+	"fst_mod": "3",
+	"snd_mod": "5",
+	"start": "1e30",
+	"end": "1000000000000000000000000000005",
+	"fst_str": "fizz",
+	"snd_str": "buzz"
+}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "start"))
+			},
+		},
+		{
+			name: "Error too many terms",
+			body: `{
+	"fst_mod": "3",
+	"snd_mod": "5",
+	"start": "1",
+	"end": "1000000000000000000000000000005",
+	"fst_str": "fizz",
+	"snd_str": "buzz"
+}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Contains(`$.message`, "Too many terms"))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			response := apitest.New().
+				Debug().
+				Handler(suite.Router).
+				Post("/fizzbuzz/big").
+				Body(test.body).
+				Expect(suite.T()).
+				Status(test.expectedStatus)
+			test.check(response)
+			response.End()
+		})
+	}
+}
+
+func (suite *FizzBuzzControllerSuite) TestFizzbuzzTermRequest() {
+	tests := []struct {
+		name           string
+		path           string
+		query          string
+		expectedStatus int
+		check          func(r *apitest.Response)
+	}{
+		{
+			name:           "Ok 200 single term",
+			path:           "/fizzbuzz/term",
+			query:          "fst_mod=3&snd_mod=5&fst_str=fizz&snd_str=buzz&n=1000000000",
+			expectedStatus: http.StatusOK,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.n`, float64(1000000000)))
+				r.Assert(jsonpath.Equal(`$.value`, "buzz"))
+			},
+		},
+		{
+			name:           "Ok 200 many terms",
+			path:           "/fizzbuzz/terms",
+			query:          "fst_mod=3&snd_mod=5&fst_str=fizz&snd_str=buzz&n=15&n=7",
+			expectedStatus: http.StatusOK,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Len(`$`, 2))
+				r.Assert(jsonpath.Equal(`$[0].value`, "fizzbuzz"))
+				r.Assert(jsonpath.Equal(`$[1].value`, "7"))
+			},
+		},
+		{
+			name:           "Error many indexes on single term",
+			path:           "/fizzbuzz/term",
+			query:          "fst_mod=3&snd_mod=5&fst_str=fizz&snd_str=buzz&n=15&n=7",
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "n"))
+			},
+		},
+		{
+			name:           "Error invalid index",
+			path:           "/fizzbuzz/terms",
+			query:          "fst_mod=3&snd_mod=5&fst_str=fizz&snd_str=buzz&n=15&n=0",
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "n[1]"))
+			},
+		},
+	}
+
+	suite.mockCacheRepo.EXPECT().IncrementRequest(gomock.Any(), gomock.AssignableToTypeOf(&inputTermRequest{}), gomock.Any()).MaxTimes(len(tests))
+	suite.mockCacheRepo.EXPECT().AddCosts(gomock.Any(), gomock.Len(1), gomock.Len(1)).MaxTimes(len(tests))
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			query, err := url.ParseQuery(test.query)
+			suite.Require().NoError(err)
+			response := apitest.New().
+				Debug().
+				Handler(suite.Router).
+				Get(test.path).
+				QueryCollection(query).
+				Expect(suite.T()).
+				Status(test.expectedStatus)
+			test.check(response)
+			response.End()
+		})
+	}
+}
+
+func (suite *FizzBuzzControllerSuite) TestFizzbuzzStats() {
+	apitest.New().
+		Handler(suite.Router).
+		Get("/fizzbuzz/stats").
+		QueryParams(map[string]string{"fst_mod": "3", "snd_mod": "5", "limit": "1000000000000", "fst_str": "fizz", "snd_str": "buzz"}).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.period`, float64(15))).
+		Assert(jsonpath.Equal(`$.words[3].word`, "fizzbuzz")).
+		Assert(jsonpath.Equal(`$.words[3].count`, float64(66666666666))).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/stats").
+		Body(`{"limit": 100, "rules": [{"mod": 2, "str": "a"}, {"mod": 3, "str": "b"}, {"mod": 7, "str": "c"}]}`).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len(`$.words`, 8)).
+		Assert(jsonpath.Equal(`$.period`, float64(42))).
+		Assert(jsonpath.Equal(`$.words[7].word`, "abc")).
+		Assert(jsonpath.Equal(`$.words[7].count`, float64(2))).
+		Assert(jsonpath.Equal(`$.words[7].last`, float64(84))).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/stats").
+		Body(`{"limit": 100, "rules": [{"mod": 0, "str": "a"}]}`).
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal(`$.errors[0].field_name`, "mod")).
+		End()
+}
+
+func (suite *FizzBuzzControllerSuite) TestFizzbuzzExplain() {
+	body := `{"fst_mod": 3, "snd_mod": 5, "limit": 15, "fst_str": "fizz", "snd_str": "buzz"}`
+	suite.mockCacheRepo.EXPECT().IncrementRequest(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(1)
+	suite.mockCacheRepo.EXPECT().IncrementRequests(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(1)
+	suite.mockCacheRepo.EXPECT().AddCosts(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(2)
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz").
+		Query("explain", "true").
+		Body(body).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len(`$`, 15)).
+		Assert(jsonpath.Equal(`$[14].n`, float64(15))).
+		Assert(jsonpath.Equal(`$[14].value`, "fizzbuzz")).
+		Assert(jsonpath.Equal(`$[14].matched`, []interface{}{"fst", "snd"})).
+		Assert(jsonpath.Equal(`$[6].residues.fst`, float64(1))).
+		Assert(jsonpath.Equal(`$[6].residues.snd`, float64(2))).
+		Assert(jsonpath.Len(`$[6].matched`, 0)).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/batch").
+		Query("explain", "true").
+		Body("[" + body + "]").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$[0].result[2].matched`, []interface{}{"fst"})).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz").
+		Query("explain", "maybe").
+		Body(body).
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal(`$.errors[0].field_name`, "explain")).
+		End()
+}
+
+func (suite *FizzBuzzControllerSuite) TestFizzbuzzCheck() {
+	query := map[string]string{"fst_mod": "3", "snd_mod": "5", "limit": "15", "fst_str": "fizz", "snd_str": "buzz"}
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/check").
+		QueryParams(query).
+		Header("Content-Type", "text/plain").
+		Body("1\r\n2\nfizz\n4\nbuzz\nfizz\n7\n8\nfizz\nbuzz\n11\nfizz\n13\n14\nfizz\n").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.correct`, float64(14))).
+		Assert(jsonpath.Equal(`$.first_mismatch.n`, float64(15))).
+		Assert(jsonpath.Equal(`$.first_mismatch.expected`, "fizzbuzz")).
+		Assert(jsonpath.Equal(`$.first_mismatch.actual`, "fizz")).
+		Assert(jsonpath.Equal(`$.missing`, float64(0))).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/check").
+		QueryParams(query).
+		JSON(`["1", "2", "fizz"]`).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.correct`, float64(3))).
+		Assert(jsonpath.Equal(`$.missing`, float64(12))).
+		Assert(jsonpath.Equal(`$.score`, 0.2)).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/check").
+		QueryParams(query).
+		JSON(`["1", 2]`).
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Contains(`$.message`, "term 2 should be a string")).
+		End()
+}
+
+func (suite *FizzBuzzControllerSuite) TestFizzbuzzInfer() {
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/infer").
+		JSON(`{"sequence": ["1", "2", "fizz", "4", "buzz", "fizz", "7", "8", "fizz", "buzz", "11", "fizz", "13", "14", "fizzbuzz"]}`).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.consistent`, true)).
+		Assert(jsonpath.Equal(`$.ambiguous`, false)).
+		Assert(jsonpath.Len(`$.candidates`, 1)).
+		Assert(jsonpath.Equal(`$.candidates[0].fst_mod`, float64(3))).
+		Assert(jsonpath.Equal(`$.candidates[0].snd_str`, "buzz")).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/infer").
+		JSON(`{"sequence": ["1", "fizz", "fizz", "buzz"]}`).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.consistent`, false)).
+		Assert(jsonpath.Len(`$.reasons`, 1)).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/infer").
+		JSON(`{"sequence": []}`).
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal(`$.errors[0].field_name`, "sequence")).
+		End()
+}
+
 func TestFizzBuzzControllerSuite(t *testing.T) {
 	suite.Run(t, new(FizzBuzzControllerSuite))
 }
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type inputInferRequest struct {
+	domain.InferRequest
+}
+
+func (i *inputInferRequest) inputValidator() ValidationFormatter {
+	return ValidationFormatter{
+		structToJson: map[string]string{
+			"Sequence": "sequence",
+		},
+	}
+}
+
+// Infer returns the rule sets which could have produced the sequence of the body
+func (fb *fizzBuzzController) Infer(c *gin.Context) {
+	var inp inputInferRequest
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	c.JSON(http.StatusOK, fb.fbs.Infer(inp.InferRequest))
+}
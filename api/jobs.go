@@ -0,0 +1,128 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type jobsController struct {
+	js     service.JobService
+	ms     service.MetricService
+	logger *zap.Logger
+}
+
+type jobResponse struct {
+	domain.Job
+	Percent float64 `json:"percent"`
+}
+
+func newJobResponse(job *domain.Job) jobResponse {
+	return jobResponse{Job: *job, Percent: job.Percent()}
+}
+
+func ParseJobsError(err error) (int, ErrorResponse) {
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		return http.StatusNotFound, ErrorResponse{Message: "Job not found"}
+	case errors.Is(err, service.ErrJobQueueFull):
+		return http.StatusServiceUnavailable, ErrorResponse{Message: "Too many jobs waiting, retry later"}
+	case errors.Is(err, service.ErrJobNotFinished):
+		return http.StatusConflict, ErrorResponse{Message: "Job is not done"}
+	case errors.Is(err, service.ErrJobFinished):
+		return http.StatusConflict, ErrorResponse{Message: "Job is already finished"}
+	case errors.Is(err, service.ErrJobNoResult):
+		return http.StatusNotFound, ErrorResponse{Message: "Job result is not available on this instance"}
+	}
+
+	return http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"}
+}
+
+func SetupJobsAPI(js service.JobService,
+	ms service.MetricService,
+	router *gin.Engine,
+	logger *zap.Logger) {
+	jc := &jobsController{js: js, ms: ms, logger: logger}
+	router.POST("/jobs", jc.Create)
+	router.GET("/jobs/:id", jc.Get)
+	router.DELETE("/jobs/:id", jc.Cancel)
+	router.GET("/jobs/:id/result", jc.Result)
+}
+
+func (jc *jobsController) Create(c *gin.Context) {
+	var inp inputFizzBuzzRequest
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	inp.Normalize()
+	explain, ok := explainQuery(c)
+	if !ok {
+		return
+	}
+
+	job, err := jc.js.Enqueue(inp.FizzBuzzRequest, explain)
+	if err != nil {
+		code, errResp := ParseJobsError(err)
+		c.JSON(code, errResp)
+		return
+	}
+
+	if err := jc.ms.Increment(&inp, ClientID(c)); err != nil {
+		jc.logger.Error("while incrementing request", zap.Error(err))
+	}
+
+	c.Header("Location", "/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, newJobResponse(job))
+}
+
+func (jc *jobsController) Get(c *gin.Context) {
+	job, err := jc.js.Get(c.Param("id"))
+	if err != nil {
+		code, errResp := ParseJobsError(err)
+		c.JSON(code, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, newJobResponse(job))
+}
+
+func (jc *jobsController) Cancel(c *gin.Context) {
+	job, err := jc.js.Cancel(c.Param("id"))
+	if err != nil {
+		code, errResp := ParseJobsError(err)
+		c.JSON(code, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, newJobResponse(job))
+}
+
+func (jc *jobsController) Result(c *gin.Context) {
+	id := c.Param("id")
+	path, err := jc.js.ResultPath(id)
+	if err != nil {
+		code, errResp := ParseJobsError(err)
+		c.JSON(code, errResp)
+		return
+	}
+
+	job, err := jc.js.Get(id)
+	if err != nil {
+		code, errResp := ParseJobsError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	// Explained terms are written one JSON object per line
+	if job.Explain {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.FileAttachment(path, "fizzbuzz-"+id+".ndjson")
+		return
+	}
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.FileAttachment(path, "fizzbuzz-"+id+".txt")
+}
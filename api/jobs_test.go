@@ -0,0 +1,112 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	mock_repository "FizzBuzz/repository/mock"
+	"FizzBuzz/service"
+	mock_service "FizzBuzz/service/mock"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type JobsControllerSuite struct {
+	suite.Suite
+	logger        *zap.Logger
+	ctrl          *gomock.Controller
+	Router        *gin.Engine
+	mockCacheRepo *mock_repository.MockCacheCounterRepository
+	mjs           *mock_service.MockJobService
+}
+
+func (suite *JobsControllerSuite) SetupTest() {
+	var err error
+	suite.logger = zap.NewExample()
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mockCacheRepo = mock_repository.NewMockCacheCounterRepository(suite.ctrl)
+	suite.mjs = mock_service.NewMockJobService(suite.ctrl)
+	suite.Router, err = Setup(nil,
+		service.NewMetricService(suite.mockCacheRepo, domain.RankByHits, suite.logger),
+		suite.logger,
+		WithJobs(suite.mjs))
+	suite.Require().NoError(err)
+}
+
+func (suite *JobsControllerSuite) TestCreate() {
+	request := domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 3_000_000_000, FstStr: "fizz", SndStr: "buzz"}
+	suite.mockCacheRepo.EXPECT().IncrementRequest(gomock.Any(), gomock.Any(), gomock.Any())
+	suite.mjs.EXPECT().Enqueue(request, false).Return(&domain.Job{
+		ID:      "abc",
+		Status:  domain.JobPending,
+		Request: request,
+	}, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/jobs").
+		Body(`{"fst_mod": 3, "snd_mod": 5, "limit": 3000000000, "fst_str": "fizz", "snd_str": "buzz"}`).
+		Expect(suite.T()).
+		Status(http.StatusAccepted).
+		Header("Location", "/jobs/abc").
+		Assert(jsonpath.Equal(`$.id`, "abc")).
+		Assert(jsonpath.Equal(`$.status`, "pending")).
+		Assert(jsonpath.Equal(`$.percent`, float64(0))).
+		End()
+}
+
+func (suite *JobsControllerSuite) TestCreateQueueFull() {
+	suite.mjs.EXPECT().Enqueue(gomock.Any(), gomock.Any()).Return(nil, service.ErrJobQueueFull)
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/jobs").
+		Body(`{"fst_mod": 3, "snd_mod": 5, "limit": 30, "fst_str": "fizz", "snd_str": "buzz"}`).
+		Expect(suite.T()).
+		Status(http.StatusServiceUnavailable).
+		End()
+}
+
+func (suite *JobsControllerSuite) TestGet() {
+	suite.mjs.EXPECT().Get("abc").Return(&domain.Job{
+		ID:       "abc",
+		Status:   domain.JobRunning,
+		Progress: 25,
+		Request:  domain.FizzBuzzRequest{Limit: 100},
+	}, nil)
+	suite.mjs.EXPECT().Get("unknown").Return(nil, service.ErrJobNotFound)
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/jobs/abc").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.percent`, float64(25))).
+		End()
+	apitest.New().
+		Handler(suite.Router).
+		Get("/jobs/unknown").
+		Expect(suite.T()).
+		Status(http.StatusNotFound).
+		End()
+}
+
+func (suite *JobsControllerSuite) TestResultNotFinished() {
+	suite.mjs.EXPECT().ResultPath("abc").Return("", service.ErrJobNotFinished)
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/jobs/abc/result").
+		Expect(suite.T()).
+		Status(http.StatusConflict).
+		End()
+}
+
+func TestJobsControllerSuite(t *testing.T) {
+	suite.Run(t, new(JobsControllerSuite))
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"FizzBuzz/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type leaderboardController struct {
+	ls        service.LeaderboardService
+	heartbeat time.Duration
+	logger    *zap.Logger
+}
+
+func SetupLeaderboardAPI(ls service.LeaderboardService,
+	heartbeat time.Duration,
+	router *gin.Engine,
+	logger *zap.Logger) {
+	lc := &leaderboardController{ls: ls, heartbeat: heartbeat, logger: logger}
+	router.GET("/metrics/stream", lc.Stream)
+}
+
+// Stream pushes the top requests as Server-Sent Events each time their ranking changes
+func (lc *leaderboardController) Stream(c *gin.Context) {
+	sub, err := lc.ls.Subscribe(c.GetHeader("Last-Event-ID"))
+	if errors.Is(err, service.ErrTooManySubscribers) {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{Message: "Too many subscribers, retry later"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"})
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(lc.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event := <-sub.Events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				lc.logger.Error("Failed to marshal leaderboard event", zap.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: ranking\ndata: %s\n\n", event.ID, data); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
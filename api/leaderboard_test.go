@@ -0,0 +1,74 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/service"
+	mock_service "FizzBuzz/service/mock"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type LeaderboardControllerSuite struct {
+	suite.Suite
+	ctrl   *gomock.Controller
+	Router *gin.Engine
+	mls    *mock_service.MockLeaderboardService
+}
+
+func (suite *LeaderboardControllerSuite) SetupTest() {
+	var err error
+	logger := zap.NewExample()
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mls = mock_service.NewMockLeaderboardService(suite.ctrl)
+	suite.Router, err = Setup(nil, nil, logger, WithLeaderboard(suite.mls, 10*time.Millisecond))
+	suite.Require().NoError(err)
+}
+
+func (suite *LeaderboardControllerSuite) TestStream() {
+	events := make(chan domain.LeaderboardEvent, 1)
+	events <- domain.LeaderboardEvent{
+		ID: "42",
+		Ranking: []domain.MetricCountFizzBuzz{{
+			Score:   3,
+			Request: domain.CountedRequest{Identifiable: &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: "fizz", SndStr: "buzz"}},
+		}},
+	}
+	closed := false
+	suite.mls.EXPECT().Subscribe("41").Return(&service.LeaderboardSubscription{
+		Events: events,
+		Close:  func() { closed = true },
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/metrics/stream", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "41")
+	rec := httptest.NewRecorder()
+	suite.Router.ServeHTTP(rec, req)
+
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal("text/event-stream", rec.Header().Get("Content-Type"))
+	suite.Contains(rec.Body.String(), "id: 42\nevent: ranking\ndata: {\"ranking\":[{\"counter\":3,")
+	suite.Contains(rec.Body.String(), ": heartbeat\n\n")
+	suite.True(closed)
+}
+
+func (suite *LeaderboardControllerSuite) TestTooManySubscribers() {
+	suite.mls.EXPECT().Subscribe("").Return(nil, service.ErrTooManySubscribers)
+
+	rec := httptest.NewRecorder()
+	suite.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/stream", nil))
+	suite.Equal(http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestLeaderboardControllerSuite(t *testing.T) {
+	suite.Run(t, new(LeaderboardControllerSuite))
+}
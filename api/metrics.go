@@ -1,11 +1,14 @@
 package api
 
 import (
+	"FizzBuzz/domain"
 	"FizzBuzz/service"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +23,14 @@ func ParseMetricsError(err error) (int, ErrorResponse) {
 		return http.StatusNoContent, ErrorResponse{
 			Message: "No data was found for top metric",
 		}
+	} else if errors.Is(err, service.ErrMetricsNotRequested) {
+		return http.StatusNotFound, ErrorResponse{
+			Message: "This request was never made",
+		}
+	} else if errors.Is(err, service.ErrMetricsNotSupported) {
+		return http.StatusNotImplemented, ErrorResponse{
+			Message: "Not available with approximate counters",
+		}
 	} else if errors.Is(err, service.ErrMetricsNoRequestFound) {
 		return http.StatusInternalServerError, ErrorResponse{
 			Message: "Data has been corrupted",
@@ -35,10 +46,33 @@ func SetupMetricsAPI(ms service.MetricService, router *gin.Engine, logger *zap.L
 		ms:     ms,
 	}
 	router.GET("/metrics", mc.Index)
+	router.GET("/metrics/lookup", mc.Lookup)
+}
+
+// sortBy reads the ranking asked in the sort_by query parameter, it answers
+// the error itself and returns false when it is invalid.
+func sortBy(ctx *gin.Context) (domain.Ranking, bool) {
+	ranking := domain.Ranking(ctx.Query("sort_by"))
+	if ranking != "" && !ranking.Valid() {
+		rankings := make([]string, len(domain.Rankings))
+		for i, r := range domain.Rankings {
+			rankings[i] = string(r)
+		}
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "sort_by",
+			Message:   "Should be one of " + strings.Join(rankings, " "),
+		}}})
+		return "", false
+	}
+	return ranking, true
 }
 
 func (mc *metricsController) Index(ctx *gin.Context) {
-	res, err := mc.ms.MostRequested()
+	ranking, ok := sortBy(ctx)
+	if !ok {
+		return
+	}
+	res, err := mc.ms.MostRequested(ranking)
 	if err != nil {
 		code, errResp := ParseMetricsError(err)
 		ctx.JSON(code, errResp)
@@ -47,3 +81,29 @@ func (mc *metricsController) Index(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, res)
 }
+
+// Lookup takes the request either as query parameters or as a JSON body
+func (mc *metricsController) Lookup(ctx *gin.Context) {
+	ranking, ok := sortBy(ctx)
+	if !ok {
+		return
+	}
+	var inp inputFizzBuzzRequest
+	bind := ctx.ShouldBindQuery
+	if ctx.ContentType() == binding.MIMEJSON {
+		bind = ctx.ShouldBindJSON
+	}
+	if err := bind(&inp); err != nil {
+		ctx.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	inp.Normalize()
+
+	res, err := mc.ms.Lookup(inp.FizzBuzzRequest, ranking)
+	if err != nil {
+		code, errResp := ParseMetricsError(err)
+		ctx.JSON(code, errResp)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
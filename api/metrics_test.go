@@ -46,13 +46,13 @@ func (suite *MetricsControllerSuite) TestMetricRequest() {
 			metric: &domain.MetricCountFizzBuzz{
 				Key:   "hash",
 				Score: 2,
-				Request: domain.FizzBuzzRequest{
+				Request: domain.CountedRequest{Identifiable: &domain.FizzBuzzRequest{
 					FstModulo: 3,
 					SndModulo: 5,
 					Limit:     10,
 					FstStr:    "test",
 					SndStr:    "test2",
-				},
+				}},
 			},
 			check: func(r *apitest.Response) {
 				r.Assert(jsonpath.Equal(`$.counter`, float64(2)))
@@ -95,9 +95,9 @@ func (suite *MetricsControllerSuite) TestMetricRequest() {
 	for _, test := range tests {
 		suite.Run(test.name, func() {
 			if test.serviceErr != nil {
-				suite.mms.EXPECT().MostRequested().Return(nil, test.serviceErr)
+				suite.mms.EXPECT().MostRequested(domain.Ranking("")).Return(nil, test.serviceErr)
 			} else {
-				suite.mms.EXPECT().MostRequested().Return(test.metric, nil)
+				suite.mms.EXPECT().MostRequested(domain.Ranking("")).Return(test.metric, nil)
 			}
 			response := apitest.New().
 				Debug().
@@ -111,6 +111,73 @@ func (suite *MetricsControllerSuite) TestMetricRequest() {
 	}
 }
 
+func (suite *MetricsControllerSuite) TestLookup() {
+	request := domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 100, FstStr: "fizz", SndStr: "buzz"}
+	lookup := &domain.MetricLookup{Score: 7, Rank: 2, Percentile: 75, Total: 4, Request: request}
+
+	suite.Run("Ok 200 query", func() {
+		suite.mms.EXPECT().Lookup(request, domain.Ranking("")).Return(lookup, nil)
+		apitest.New().
+			Handler(suite.Router).
+			Get("/metrics/lookup").
+			QueryParams(map[string]string{
+				"fst_mod": "3", "snd_mod": "5", "limit": "100", "fst_str": "fizz", "snd_str": "buzz",
+			}).
+			Expect(suite.T()).
+			Status(http.StatusOK).
+			Assert(jsonpath.Equal(`$.counter`, float64(7))).
+			Assert(jsonpath.Equal(`$.rank`, float64(2))).
+			Assert(jsonpath.Equal(`$.percentile`, float64(75))).
+			End()
+	})
+
+	suite.Run("404 json never requested", func() {
+		suite.mms.EXPECT().Lookup(request, domain.Ranking("")).Return(nil, service.ErrMetricsNotRequested)
+		apitest.New().
+			Handler(suite.Router).
+			Get("/metrics/lookup").
+			JSON(`{"fst_mod": 3, "snd_mod": 5, "limit": 100, "fst_str": "fizz", "snd_str": "buzz"}`).
+			Expect(suite.T()).
+			Status(http.StatusNotFound).
+			End()
+	})
+
+	suite.Run("400 missing field", func() {
+		apitest.New().
+			Handler(suite.Router).
+			Get("/metrics/lookup").
+			QueryParams(map[string]string{"fst_mod": "3", "snd_mod": "5", "limit": "100", "fst_str": "fizz"}).
+			Expect(suite.T()).
+			Status(http.StatusBadRequest).
+			Assert(jsonpath.Equal(`$.errors[0].field_name`, "snd_str")).
+			End()
+	})
+}
+
+func (suite *MetricsControllerSuite) TestSortBy() {
+	suite.mms.EXPECT().MostRequested(domain.RankByBytes).Return(&domain.MetricCountFizzBuzz{
+		Score: 1,
+		Cost:  domain.MetricCost{Items: 10_000, Bytes: 80_000},
+	}, nil)
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics").
+		Query("sort_by", "bytes").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.cost.bytes`, float64(80_000))).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics").
+		Query("sort_by", "cpu").
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal(`$.errors[0].field_name`, "sort_by")).
+		End()
+}
+
 func TestMetricsControllerSuite(t *testing.T) {
 	suite.Run(t, new(MetricsControllerSuite))
 }
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxGlobalTop is the maximum number of counters of the global leaderboard
+const maxGlobalTop = 100
+
+type replicationController struct {
+	rs     service.ReplicationService
+	logger *zap.Logger
+}
+
+func SetupReplicationAPI(rs service.ReplicationService,
+	adminToken string,
+	router *gin.Engine,
+	logger *zap.Logger) {
+	rc := &replicationController{rs: rs, logger: logger}
+	router.GET("/metrics/global", rc.Top)
+	admin := router.Group("/admin", AdminAuth(adminToken))
+	admin.GET("/replication/snapshot", rc.Snapshot)
+	admin.POST("/replication/merge", rc.Merge)
+}
+
+// Top returns the most requested requests summed over every replica
+func (rc *replicationController) Top(c *gin.Context) {
+	top, err := strconv.ParseInt(c.DefaultQuery("top", "10"), 10, 64)
+	if err != nil || top < 1 || top > maxGlobalTop {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "top",
+			Message:   "Should be between 1 and " + strconv.Itoa(maxGlobalTop),
+		}}})
+		return
+	}
+
+	counters, err := rc.rs.Top(top)
+	if err != nil {
+		rc.logger.Error("Failed to get global counters", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"})
+		return
+	}
+	c.JSON(http.StatusOK, counters)
+}
+
+// Snapshot is fetched by the peers of this instance
+func (rc *replicationController) Snapshot(c *gin.Context) {
+	snapshot, err := rc.rs.Snapshot()
+	if err != nil {
+		rc.logger.Error("Failed to take replication snapshot", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"})
+		return
+	}
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// Merge takes the snapshot of a peer, merging it again changes nothing
+func (rc *replicationController) Merge(c *gin.Context) {
+	var snapshot domain.ReplicaSnapshot
+	if err := c.ShouldBindJSON(&snapshot); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Body should be a replication snapshot"})
+		return
+	}
+	for i, counter := range snapshot.Counters {
+		if counter.Key == "" || counter.Data == "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+				FieldName: "counters[" + strconv.Itoa(i) + "]",
+				Message:   "Should have a key and data",
+			}}})
+			return
+		}
+	}
+
+	merged, err := rc.rs.Merge(snapshot)
+	if err != nil {
+		rc.logger.Error("Failed to merge replication snapshot", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"merged": merged})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	mock_service "FizzBuzz/service/mock"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type ReplicationControllerSuite struct {
+	suite.Suite
+	ctrl   *gomock.Controller
+	Router *gin.Engine
+	mrs    *mock_service.MockReplicationService
+}
+
+func (suite *ReplicationControllerSuite) SetupTest() {
+	var err error
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mrs = mock_service.NewMockReplicationService(suite.ctrl)
+	suite.Router, err = Setup(nil, mock_service.NewMockMetricService(suite.ctrl), zap.NewExample(),
+		WithReplication(suite.mrs, "token"))
+	suite.Require().NoError(err)
+}
+
+func (suite *ReplicationControllerSuite) TestTop() {
+	suite.mrs.EXPECT().Top(int64(5)).Return([]domain.GlobalCounter{{
+		Key:        "hash",
+		Score:      6,
+		Components: domain.GCounter{"eu": 2, "us": 4},
+	}}, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics/global").
+		Query("top", "5").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$[0].counter`, float64(6))).
+		Assert(jsonpath.Equal(`$[0].components.us`, float64(4))).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics/global").
+		Query("top", "0").
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		End()
+}
+
+func (suite *ReplicationControllerSuite) TestMerge() {
+	snapshot := domain.ReplicaSnapshot{
+		Replica:  "us",
+		Counters: []domain.ReplicaCounter{{Key: "hash", Data: "{}", Components: domain.GCounter{"us": 4}}},
+	}
+	suite.mrs.EXPECT().Merge(snapshot).Return(1, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/admin/replication/merge").
+		Header("Authorization", "Bearer token").
+		JSON(`{"replica": "us", "counters": [{"key": "hash", "data": "{}", "components": {"us": 4}}]}`).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$.merged`, float64(1))).
+		End()
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/admin/replication/merge").
+		Header("Authorization", "Bearer token").
+		JSON(`{"replica": "us", "counters": [{"key": "hash", "components": {"us": 4}}]}`).
+		Expect(suite.T()).
+		Status(http.StatusBadRequest).
+		Assert(jsonpath.Equal(`$.errors[0].field_name`, "counters[0]")).
+		End()
+}
+
+func (suite *ReplicationControllerSuite) TestSnapshotUnauthorized() {
+	apitest.New().
+		Handler(suite.Router).
+		Get("/admin/replication/snapshot").
+		Expect(suite.T()).
+		Status(http.StatusUnauthorized).
+		End()
+}
+
+func TestReplicationControllerSuite(t *testing.T) {
+	suite.Run(t, new(ReplicationControllerSuite))
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"FizzBuzz"
 	"FizzBuzz/service"
+	"errors"
 	"path/filepath"
 	"time"
 
@@ -15,15 +16,135 @@ import (
 	"go.uber.org/zap"
 )
 
+var (
+	ErrNoAdminToken = errors.New("admin API requires a token")
+)
+
+// Option enables an optional feature of the router
+type Option func(*setupOptions)
+
+type setupOptions struct {
+	middlewares    []gin.HandlerFunc
+	apiKeys        []string
+	trustedProxies []string
+	batchBudget    int
+	jobs           service.JobService
+	leaderboard    service.LeaderboardService
+	heartbeat      time.Duration
+	webhooks       service.WebhookService
+	adminToken     string
+	analytics      service.AnalyticsService
+	series         service.SeriesService
+	replication    service.ReplicationService
+	rules          service.RuleService
+}
+
+// WithCapture records sampled incoming requests through the capture service
+func WithCapture(cs service.CaptureService) Option {
+	return func(o *setupOptions) {
+		o.middlewares = append(o.middlewares, CaptureRequest(cs))
+	}
+}
+
+// WithAPIKeys identifies the clients sending one of these keys in X-API-Key,
+// the others are identified by their IP
+func WithAPIKeys(keys []string) Option {
+	return func(o *setupOptions) {
+		o.apiKeys = keys
+	}
+}
+
+// WithTrustedProxies reads the client IP from the forwarding headers set by these
+// proxies, given as IPs or CIDRs. No proxy is trusted by default.
+func WithTrustedProxies(proxies []string) Option {
+	return func(o *setupOptions) {
+		o.trustedProxies = proxies
+	}
+}
+
+// WithBatchBudget caps the sum of limits computed by one batch request
+func WithBatchBudget(budget int) Option {
+	return func(o *setupOptions) {
+		o.batchBudget = budget
+	}
+}
+
+// WithJobs serves the asynchronous jobs API
+func WithJobs(js service.JobService) Option {
+	return func(o *setupOptions) {
+		o.jobs = js
+	}
+}
+
+// WithLeaderboard streams leaderboard changes, sending a heartbeat every given time
+func WithLeaderboard(ls service.LeaderboardService, heartbeat time.Duration) Option {
+	return func(o *setupOptions) {
+		o.leaderboard = ls
+		o.heartbeat = heartbeat
+	}
+}
+
+// WithWebhooks serves the webhook subscriptions admin API, protected by adminToken
+func WithWebhooks(ws service.WebhookService, adminToken string) Option {
+	return func(o *setupOptions) {
+		o.webhooks = ws
+		o.adminToken = adminToken
+	}
+}
+
+// WithAnalytics serves the per-field analytics of the requests
+func WithAnalytics(as service.AnalyticsService) Option {
+	return func(o *setupOptions) {
+		o.analytics = as
+	}
+}
+
+// WithSeries serves the time series of the counters
+func WithSeries(ss service.SeriesService) Option {
+	return func(o *setupOptions) {
+		o.series = ss
+	}
+}
+
+// WithReplication serves the global leaderboard and the snapshots exchanged with
+// the peers, protected by adminToken
+func WithReplication(rs service.ReplicationService, adminToken string) Option {
+	return func(o *setupOptions) {
+		o.replication = rs
+		o.adminToken = adminToken
+	}
+}
+
+// WithRules serves sequences computed from custom rules
+func WithRules(rs service.RuleService) Option {
+	return func(o *setupOptions) {
+		o.rules = rs
+	}
+}
+
 func Setup(fbService service.FizzBuzzService,
 	metricService service.MetricService,
-	logger *zap.Logger) (*gin.Engine, error) {
+	logger *zap.Logger,
+	opts ...Option) (*gin.Engine, error) {
+	options := setupOptions{batchBudget: DefaultBatchBudget}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if (options.webhooks != nil || options.replication != nil) && options.adminToken == "" {
+		return nil, ErrNoAdminToken
+	}
+
 	router := gin.New()
 	router.RemoveExtraSlash = true
+	if err := router.SetTrustedProxies(options.trustedProxies); err != nil {
+		return nil, err
+	}
 
 	router.Use(ginzap.Ginzap(logger.Named("access"), time.RFC3339, true))
 	router.Use(ginzap.RecoveryWithZap(logger, true))
 	router.Use(MetricHttpRequest())
+	router.Use(IdentifyClient(options.apiKeys))
+	router.Use(options.middlewares...)
 
 	router.GET("/", Index)
 	router.GET("/prometheus-metrics", gin.WrapH(promhttp.Handler()))
@@ -31,9 +152,37 @@ func Setup(fbService service.FizzBuzzService,
 
 	{ // Exposed routes for users
 		// Serv fizz buzz service
-		SetupFizzBuzzAPI(fbService, metricService, router, logger)
+		SetupFizzBuzzAPI(fbService, metricService, options.batchBudget, router, logger)
+		// Serv custom rules
+		if options.rules != nil {
+			SetupRulesAPI(options.rules, router, logger)
+		}
 		// Serv custom metrics
 		SetupMetricsAPI(metricService, router, logger)
+		// Serv per-field analytics
+		if options.analytics != nil {
+			SetupAnalyticsAPI(options.analytics, router, logger)
+		}
+		// Serv counters time series
+		if options.series != nil {
+			SetupSeriesAPI(options.series, router, logger)
+		}
+		// Serv live leaderboard
+		if options.leaderboard != nil {
+			SetupLeaderboardAPI(options.leaderboard, options.heartbeat, router, logger)
+		}
+		// Serv webhooks administration
+		if options.webhooks != nil {
+			SetupWebhooksAPI(options.webhooks, options.adminToken, router, logger)
+		}
+		// Serv replication between instances
+		if options.replication != nil {
+			SetupReplicationAPI(options.replication, options.adminToken, router, logger)
+		}
+		// Serv asynchronous jobs
+		if options.jobs != nil {
+			SetupJobsAPI(options.jobs, metricService, router, logger)
+		}
 	}
 	return router, nil
 }
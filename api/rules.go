@@ -0,0 +1,66 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/expr"
+	"FizzBuzz/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type rulesController struct {
+	rs     service.RuleService
+	logger *zap.Logger
+}
+
+type inputRulesRequest struct {
+	domain.RulesRequest
+}
+
+func (i *inputRulesRequest) inputValidator() ValidationFormatter {
+	return ValidationFormatter{
+		structToJson: map[string]string{
+			"Limit": "limit",
+			"Rules": "rules",
+		},
+	}
+}
+
+func SetupRulesAPI(rs service.RuleService, router *gin.Engine, logger *zap.Logger) {
+	rc := &rulesController{rs: rs, logger: logger}
+	router.POST("/fizzbuzz/rules", rc.Evaluate)
+}
+
+func ParseRulesError(err error) (int, ErrorResponse) {
+	var ruleErrs domain.RuleErrors
+	if errors.As(err, &ruleErrs) {
+		fields := make([]ErrorField, len(ruleErrs))
+		for i, e := range ruleErrs {
+			fields[i] = ErrorField{FieldName: e.Field, Message: e.Message, Position: e.Position}
+		}
+		return http.StatusBadRequest, ErrorResponse{Fields: fields}
+	} else if errors.Is(err, expr.ErrStepBudget) || errors.Is(err, expr.ErrTimeBudget) {
+		return http.StatusUnprocessableEntity, ErrorResponse{Message: "Rule expressions took too long: " + err.Error()}
+	}
+	return http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"}
+}
+
+// Evaluate computes a sequence from custom rules, every invalid rule is reported
+func (rc *rulesController) Evaluate(c *gin.Context) {
+	var inp inputRulesRequest
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+
+	res, err := rc.rs.Evaluate(inp.RulesRequest)
+	if err != nil {
+		code, errResp := ParseRulesError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
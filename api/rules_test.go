@@ -0,0 +1,103 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/expr"
+	mock_service "FizzBuzz/service/mock"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type RulesControllerSuite struct {
+	suite.Suite
+	ctrl   *gomock.Controller
+	Router *gin.Engine
+	mrs    *mock_service.MockRuleService
+}
+
+func (suite *RulesControllerSuite) SetupTest() {
+	var err error
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mrs = mock_service.NewMockRuleService(suite.ctrl)
+	suite.Router, err = Setup(nil, mock_service.NewMockMetricService(suite.ctrl), zap.NewExample(),
+		WithRules(suite.mrs))
+	suite.Require().NoError(err)
+}
+
+func (suite *RulesControllerSuite) TestEvaluate() {
+	suite.mrs.EXPECT().Evaluate(gomock.Any()).Return([]string{"1", "prime"}, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Post("/fizzbuzz/rules").
+		JSON(`{"limit": 2, "rules": [{"type": "prime", "str": "prime"}]}`).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$[1]`, "prime")).
+		End()
+}
+
+func (suite *RulesControllerSuite) TestErrors() {
+	suite.Run("400 per rule", func() {
+		suite.mrs.EXPECT().Evaluate(gomock.Any()).Return(nil, domain.RuleErrors{
+			{Field: "rules[1].digit", Message: "Should be between 0 and 9"},
+		})
+		apitest.New().
+			Handler(suite.Router).
+			Post("/fizzbuzz/rules").
+			JSON(`{"limit": 2, "rules": [{"type": "prime", "str": "a"}, {"type": "contains_digit", "digit": 10, "str": "b"}]}`).
+			Expect(suite.T()).
+			Status(http.StatusBadRequest).
+			Assert(jsonpath.Equal(`$.errors[0].field_name`, "rules[1].digit")).
+			End()
+	})
+
+	suite.Run("400 expression position", func() {
+		suite.mrs.EXPECT().Evaluate(gomock.Any()).Return(nil, domain.RuleErrors{
+			{Field: "rules[0].expr", Message: "Unexpected end of expression", Position: 9},
+		})
+		apitest.New().
+			Handler(suite.Router).
+			Post("/fizzbuzz/rules").
+			JSON(`{"limit": 2, "rules": [{"type": "expr", "expr": "n % 3 ==", "str": "a"}]}`).
+			Expect(suite.T()).
+			Status(http.StatusBadRequest).
+			Assert(jsonpath.Equal(`$.errors[0].field_name`, "rules[0].expr")).
+			Assert(jsonpath.Equal(`$.errors[0].position`, float64(9))).
+			End()
+	})
+
+	suite.Run("422 budget", func() {
+		suite.mrs.EXPECT().Evaluate(gomock.Any()).Return(nil, fmt.Errorf("rules[0] at n = 9: %w", expr.ErrStepBudget))
+		apitest.New().
+			Handler(suite.Router).
+			Post("/fizzbuzz/rules").
+			JSON(`{"limit": 10, "rules": [{"type": "expr", "expr": "is_prime(n)", "str": "a"}]}`).
+			Expect(suite.T()).
+			Status(http.StatusUnprocessableEntity).
+			End()
+	})
+
+	suite.Run("400 limit", func() {
+		apitest.New().
+			Handler(suite.Router).
+			Post("/fizzbuzz/rules").
+			JSON(`{"limit": 2000000, "rules": [{"type": "prime", "str": "a"}]}`).
+			Expect(suite.T()).
+			Status(http.StatusBadRequest).
+			Assert(jsonpath.Equal(`$.errors[0].field_name`, "limit")).
+			End()
+	})
+}
+
+func TestRulesControllerSuite(t *testing.T) {
+	suite.Run(t, new(RulesControllerSuite))
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"FizzBuzz/service"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type seriesController struct {
+	ss     service.SeriesService
+	logger *zap.Logger
+}
+
+func SetupSeriesAPI(ss service.SeriesService, router *gin.Engine, logger *zap.Logger) {
+	sc := &seriesController{ss: ss, logger: logger}
+	router.GET("/metrics/:hash/series", sc.Get)
+}
+
+func ParseSeriesError(err error) (int, ErrorResponse) {
+	switch {
+	case errors.Is(err, service.ErrSeriesNotFound):
+		return http.StatusNotFound, ErrorResponse{Message: "No counter for this hash"}
+	case errors.Is(err, service.ErrSeriesUnknownResolution):
+		return http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "resolution",
+			Message:   "Should be one of 1m 1h 1d",
+		}}}
+	case errors.Is(err, service.ErrSeriesInvalidRange):
+		return http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "from",
+			Message:   "Should be before to",
+		}}}
+	case errors.Is(err, service.ErrSeriesTooManyPoints):
+		return http.StatusBadRequest, ErrorResponse{
+			Message: "Too many buckets, at most " + strconv.Itoa(service.MaxSeriesPoints),
+		}
+	}
+	return http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"}
+}
+
+// parseTime accepts RFC 3339 dates or unix timestamps, empty is the zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Get returns the series as JSON, or as CSV with format=csv or an Accept of text/csv
+func (sc *seriesController) Get(c *gin.Context) {
+	var from, to time.Time
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		var err error
+		if *t, err = parseTime(c.Query(name)); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+				FieldName: name,
+				Message:   "Should be a RFC 3339 date or a unix timestamp",
+			}}})
+			return
+		}
+	}
+
+	points, err := sc.ss.Get(c.Param("hash"), c.DefaultQuery("resolution", "1h"), from, to)
+	if err != nil {
+		code, errResp := ParseSeriesError(err)
+		c.JSON(code, errResp)
+		return
+	}
+
+	if c.Query("format") != "csv" && !strings.Contains(c.GetHeader("Accept"), "text/csv") {
+		c.JSON(http.StatusOK, points)
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"time", "count"})
+	for _, point := range points {
+		_ = w.Write([]string{point.Time.Format(time.RFC3339), strconv.Itoa(point.Count)})
+	}
+	w.Flush()
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/service"
+	mock_service "FizzBuzz/service/mock"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type SeriesControllerSuite struct {
+	suite.Suite
+	ctrl   *gomock.Controller
+	Router *gin.Engine
+	mss    *mock_service.MockSeriesService
+	from   time.Time
+	points []domain.SeriesPoint
+}
+
+func (suite *SeriesControllerSuite) SetupTest() {
+	var err error
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mss = mock_service.NewMockSeriesService(suite.ctrl)
+	suite.Router, err = Setup(nil, mock_service.NewMockMetricService(suite.ctrl), zap.NewExample(),
+		WithSeries(suite.mss))
+	suite.Require().NoError(err)
+	suite.from = time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	suite.points = []domain.SeriesPoint{
+		{Time: suite.from, Count: 3},
+		{Time: suite.from.Add(time.Hour), Count: 0},
+	}
+}
+
+func (suite *SeriesControllerSuite) TestJSON() {
+	suite.mss.EXPECT().Get("hash", "1h", suite.from, time.Time{}).Return(suite.points, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics/hash/series").
+		Query("from", "2023-01-01T10:00:00Z").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len(`$`, 2)).
+		Assert(jsonpath.Equal(`$[0].count`, float64(3))).
+		Assert(jsonpath.Equal(`$[1].time`, "2023-01-01T11:00:00Z")).
+		End()
+}
+
+func (suite *SeriesControllerSuite) TestCSV() {
+	suite.mss.EXPECT().Get("hash", "1m", suite.from, suite.from.Add(time.Hour)).Return(suite.points, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/metrics/hash/series").
+		QueryParams(map[string]string{
+			"resolution": "1m",
+			"from":       "1672567200",
+			"to":         "1672570800",
+			"format":     "csv",
+		}).
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Header("Content-Type", "text/csv; charset=utf-8").
+		Body("time,count\n2023-01-01T10:00:00Z,3\n2023-01-01T11:00:00Z,0\n").
+		End()
+}
+
+func (suite *SeriesControllerSuite) TestErrors() {
+	suite.Run("400 invalid date", func() {
+		apitest.New().
+			Handler(suite.Router).
+			Get("/metrics/hash/series").
+			Query("to", "yesterday").
+			Expect(suite.T()).
+			Status(http.StatusBadRequest).
+			Assert(jsonpath.Equal(`$.errors[0].field_name`, "to")).
+			End()
+	})
+
+	suite.Run("400 unknown resolution", func() {
+		suite.mss.EXPECT().Get("hash", "1s", time.Time{}, time.Time{}).Return(nil, service.ErrSeriesUnknownResolution)
+		apitest.New().
+			Handler(suite.Router).
+			Get("/metrics/hash/series").
+			Query("resolution", "1s").
+			Expect(suite.T()).
+			Status(http.StatusBadRequest).
+			Assert(jsonpath.Equal(`$.errors[0].field_name`, "resolution")).
+			End()
+	})
+
+	suite.Run("404 unknown hash", func() {
+		suite.mss.EXPECT().Get("other", "1h", time.Time{}, time.Time{}).Return(nil, service.ErrSeriesNotFound)
+		apitest.New().
+			Handler(suite.Router).
+			Get("/metrics/other/series").
+			Expect(suite.T()).
+			Status(http.StatusNotFound).
+			End()
+	})
+}
+
+func TestSeriesControllerSuite(t *testing.T) {
+	suite.Run(t, new(SeriesControllerSuite))
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type inputStatsRequest struct {
+	domain.StatsRequest
+}
+
+func (i *inputStatsRequest) inputValidator() ValidationFormatter {
+	return ValidationFormatter{
+		structToJson: map[string]string{
+			"Limit": "limit",
+			"Rules": "rules",
+			"Mod":   "mod",
+			"Str":   "str",
+		},
+	}
+}
+
+// Stats counts the words of a fizzbuzz request given in the query
+func (fb *fizzBuzzController) Stats(c *gin.Context) {
+	var inp inputFizzBuzzRequest
+	if err := c.ShouldBindQuery(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	c.JSON(http.StatusOK, fb.fbs.Stats(inp.StatsRequest()))
+}
+
+// StatsRules counts the words of any number of divisibility rules
+func (fb *fizzBuzzController) StatsRules(c *gin.Context) {
+	var inp inputStatsRequest
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	c.JSON(http.StatusOK, fb.fbs.Stats(inp.StatsRequest))
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type inputTermRequest struct {
+	domain.TermRequest
+}
+
+func (i *inputTermRequest) inputValidator() ValidationFormatter {
+	return ValidationFormatter{
+		structToJson: map[string]string{
+			"FstModulo":    "fst_mod",
+			"SndModulo":    "snd_mod",
+			"FstStr":       "fst_str",
+			"SndStr":       "snd_str",
+			"NumberFormat": "number_format",
+			"N":            "n",
+		},
+	}
+}
+
+// terms binds the query, counts it and writes its terms, a single object when single
+func (fb *fizzBuzzController) terms(c *gin.Context, single bool) {
+	var inp inputTermRequest
+	if err := c.ShouldBindQuery(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	inp.Normalize()
+	if single && len(inp.N) != 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "n",
+			Message:   "Should be a single index, use /fizzbuzz/terms for many",
+		}}})
+		return
+	}
+
+	if err := fb.ms.Increment(&inp, ClientID(c)); err != nil {
+		fb.logger.Error("while incrementing request", zap.Error(err))
+	}
+
+	start := time.Now()
+	res := fb.fbs.Terms(inp.TermRequest)
+	duration := time.Since(start)
+	if single {
+		c.JSON(http.StatusOK, res[0])
+	} else {
+		c.JSON(http.StatusOK, res)
+	}
+
+	cost := domain.RequestCost{Items: len(res), Duration: duration, Bytes: c.Writer.Size()}
+	if err := fb.ms.AddCosts([]domain.Identifiable{&inp}, []domain.RequestCost{cost}); err != nil {
+		fb.logger.Error("while adding request cost", zap.Error(err))
+	}
+}
+
+// Term computes the term at the index n without the ones before it
+func (fb *fizzBuzzController) Term(c *gin.Context) {
+	fb.terms(c, true)
+}
+
+// Terms computes the terms at every index n given
+func (fb *fizzBuzzController) Terms(c *gin.Context) {
+	fb.terms(c, false)
+}
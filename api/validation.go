@@ -0,0 +1,36 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/numfmt"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// init registers the validations of the domain tags on the validator of gin
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation("template", validateTemplate)
+		_ = v.RegisterValidation("numberformat", validateNumberFormat)
+		_ = v.RegisterValidation("bigint", validateBigInt)
+	}
+}
+
+// validateTemplate checks the words templated with the number
+func validateTemplate(fl validator.FieldLevel) bool {
+	_, err := numfmt.Compile(fl.Field().String())
+	return err == nil
+}
+
+// validateNumberFormat checks the format of the numbers not replaced by a word
+func validateNumberFormat(fl validator.FieldLevel) bool {
+	_, err := numfmt.ParseNumberFormat(fl.Field().String())
+	return err == nil
+}
+
+// validateBigInt checks the decimal numerals of the big integers
+func validateBigInt(fl validator.FieldLevel) bool {
+	_, ok := domain.ParseBigInt(fl.Field().String())
+	return ok
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/service"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type webhooksController struct {
+	ws     service.WebhookService
+	logger *zap.Logger
+}
+
+type inputWebhookSubscription struct {
+	URL       string                `json:"url" binding:"required,url"`
+	Secret    string                `json:"secret"`
+	Events    []domain.WebhookEvent `json:"events" binding:"required,min=1,dive,oneof=leader_changed threshold_crossed"`
+	Threshold int                   `json:"threshold" binding:"gte=0"`
+}
+
+func (i *inputWebhookSubscription) inputValidator() ValidationFormatter {
+	return ValidationFormatter{
+		structToJson: map[string]string{
+			"URL":       "url",
+			"Secret":    "secret",
+			"Events":    "events",
+			"Threshold": "threshold",
+		},
+	}
+}
+
+// AdminAuth requires the bearer token, nothing is allowed without one
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: "Admin API is disabled"})
+			return
+		}
+		expected := []byte("Bearer " + token)
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func SetupWebhooksAPI(ws service.WebhookService,
+	adminToken string,
+	router *gin.Engine,
+	logger *zap.Logger) {
+	wc := &webhooksController{ws: ws, logger: logger}
+	admin := router.Group("/admin", AdminAuth(adminToken))
+	admin.POST("/webhooks", wc.Create)
+	admin.GET("/webhooks", wc.List)
+	admin.GET("/webhooks/:id", wc.Get)
+	admin.DELETE("/webhooks/:id", wc.Delete)
+	admin.GET("/webhook-dead-letters", wc.DeadLetters)
+}
+
+func ParseWebhooksError(err error) (int, ErrorResponse) {
+	if errors.Is(err, service.ErrWebhookNotFound) {
+		return http.StatusNotFound, ErrorResponse{Message: "Webhook subscription not found"}
+	}
+	return http.StatusInternalServerError, ErrorResponse{Message: "Sorry something went wrong"}
+}
+
+func (wc *webhooksController) Create(c *gin.Context) {
+	var inp inputWebhookSubscription
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		c.JSON(http.StatusBadRequest, BuildValidationError(err, inp.inputValidator()))
+		return
+	}
+	sub := domain.WebhookSubscription{
+		URL:       inp.URL,
+		Secret:    inp.Secret,
+		Events:    inp.Events,
+		Threshold: inp.Threshold,
+	}
+	if sub.Wants(domain.WebhookThresholdCrossed) && sub.Threshold == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "threshold",
+			Message:   "This field is required for threshold_crossed",
+		}}})
+		return
+	}
+
+	created, err := wc.ws.Create(sub)
+	if err != nil {
+		code, errResp := ParseWebhooksError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	// The secret is only shown once
+	c.JSON(http.StatusCreated, created)
+}
+
+func (wc *webhooksController) List(c *gin.Context) {
+	subs, err := wc.ws.List()
+	if err != nil {
+		code, errResp := ParseWebhooksError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func (wc *webhooksController) Get(c *gin.Context) {
+	sub, err := wc.ws.Get(c.Param("id"))
+	if err != nil {
+		code, errResp := ParseWebhooksError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	sub.Secret = ""
+	c.JSON(http.StatusOK, sub)
+}
+
+func (wc *webhooksController) Delete(c *gin.Context) {
+	if err := wc.ws.Delete(c.Param("id")); err != nil {
+		code, errResp := ParseWebhooksError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (wc *webhooksController) DeadLetters(c *gin.Context) {
+	count, err := strconv.ParseInt(c.DefaultQuery("count", "100"), 10, 64)
+	if err != nil || count < 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Fields: []ErrorField{{
+			FieldName: "count",
+			Message:   "Should be greater than 1",
+		}}})
+		return
+	}
+
+	deliveries, err := wc.ws.DeadLetters(count)
+	if err != nil {
+		code, errResp := ParseWebhooksError(err)
+		c.JSON(code, errResp)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
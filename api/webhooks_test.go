@@ -0,0 +1,152 @@
+package api
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/service"
+	mock_service "FizzBuzz/service/mock"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type WebhooksControllerSuite struct {
+	suite.Suite
+	ctrl   *gomock.Controller
+	Router *gin.Engine
+	mws    *mock_service.MockWebhookService
+}
+
+func (suite *WebhooksControllerSuite) SetupTest() {
+	var err error
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.mws = mock_service.NewMockWebhookService(suite.ctrl)
+	suite.Router, err = Setup(nil, nil, zap.NewExample(), WithWebhooks(suite.mws, "token"))
+	suite.Require().NoError(err)
+}
+
+func (suite *WebhooksControllerSuite) TestCreate() {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		check          func(r *apitest.Response)
+	}{
+		{
+			name:           "Ok 201",
+			body:           `{"url": "https://example.com/hook", "events": ["leader_changed"]}`,
+			expectedStatus: http.StatusCreated,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.id`, "abc"))
+				r.Assert(jsonpath.Equal(`$.secret`, "generated"))
+			},
+		},
+		{
+			name:           "Error invalid event",
+			body:           `{"url": "https://example.com/hook", "events": ["leader_changed", "unknown"]}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "events[1]"))
+			},
+		},
+		{
+			name:           "Error invalid url",
+			body:           `{"url": "example", "events": ["leader_changed"]}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "url"))
+			},
+		},
+		{
+			name:           "Error missing threshold",
+			body:           `{"url": "https://example.com/hook", "events": ["threshold_crossed"]}`,
+			expectedStatus: http.StatusBadRequest,
+			check: func(r *apitest.Response) {
+				r.Assert(jsonpath.Equal(`$.errors[0].field_name`, "threshold"))
+			},
+		},
+	}
+
+	suite.mws.EXPECT().Create(domain.WebhookSubscription{
+		URL:    "https://example.com/hook",
+		Events: []domain.WebhookEvent{domain.WebhookLeaderChanged},
+	}).Return(&domain.WebhookSubscription{
+		ID:     "abc",
+		URL:    "https://example.com/hook",
+		Secret: "generated",
+		Events: []domain.WebhookEvent{domain.WebhookLeaderChanged},
+	}, nil)
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			response := apitest.New().
+				Handler(suite.Router).
+				Post("/admin/webhooks").
+				Header("Authorization", "Bearer token").
+				Body(test.body).
+				Expect(suite.T()).
+				Status(test.expectedStatus)
+			test.check(response)
+			response.End()
+		})
+	}
+}
+
+func (suite *WebhooksControllerSuite) TestListHidesSecrets() {
+	suite.mws.EXPECT().List().Return([]domain.WebhookSubscription{{ID: "abc", Secret: "secret"}}, nil)
+
+	apitest.New().
+		Handler(suite.Router).
+		Get("/admin/webhooks").
+		Header("Authorization", "Bearer token").
+		Expect(suite.T()).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal(`$[0].id`, "abc")).
+		Assert(jsonpath.NotPresent(`$[0].secret`)).
+		End()
+}
+
+func (suite *WebhooksControllerSuite) TestDeleteNotFound() {
+	suite.mws.EXPECT().Delete("abc").Return(service.ErrWebhookNotFound)
+
+	apitest.New().
+		Handler(suite.Router).
+		Delete("/admin/webhooks/abc").
+		Header("Authorization", "Bearer token").
+		Expect(suite.T()).
+		Status(http.StatusNotFound).
+		End()
+}
+
+func (suite *WebhooksControllerSuite) TestUnauthorized() {
+	apitest.New().
+		Handler(suite.Router).
+		Get("/admin/webhooks").
+		Header("Authorization", "Bearer wrong").
+		Expect(suite.T()).
+		Status(http.StatusUnauthorized).
+		End()
+}
+
+func (suite *WebhooksControllerSuite) TestNoAdminToken() {
+	_, err := Setup(nil, nil, zap.NewExample(), WithWebhooks(suite.mws, ""))
+	suite.ErrorIs(err, ErrNoAdminToken)
+
+	router := gin.New()
+	router.GET("/admin", AdminAuth(""), func(c *gin.Context) { c.Status(http.StatusOK) })
+	apitest.New().
+		Handler(router).
+		Get("/admin").
+		Header("Authorization", "Bearer ").
+		Expect(suite.T()).
+		Status(http.StatusUnauthorized).
+		End()
+}
+
+func TestWebhooksControllerSuite(t *testing.T) {
+	suite.Run(t, new(WebhooksControllerSuite))
+}
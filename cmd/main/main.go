@@ -3,18 +3,25 @@ package main
 import (
 	"FizzBuzz"
 	"FizzBuzz/api"
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
 	"FizzBuzz/repository"
 	fbRedis "FizzBuzz/repository/redis"
 	"FizzBuzz/service"
 	"context"
+	"errors"
 	goflag "flag"
 	"fmt"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -27,6 +34,66 @@ type Config struct {
 	RedisPwd    string `mapstructure:"redis-pwd"`
 	LogLevel    string `mapstructure:"log-level"`
 	Listen      string `mapstructure:"listen"`
+	Hash        string `mapstructure:"hash"`
+
+	APIKeys        []string `mapstructure:"api-keys"`
+	TrustedProxies []string `mapstructure:"trusted-proxies"`
+
+	BatchBudget int `mapstructure:"batch-budget"`
+
+	ExprSteps     int64         `mapstructure:"expr-steps"`
+	ExprTimeout   time.Duration `mapstructure:"expr-timeout"`
+	ExprCacheSize int           `mapstructure:"expr-cache-size"`
+
+	MetricsMode   string  `mapstructure:"metrics-mode"`
+	MetricsRankBy string  `mapstructure:"metrics-rank-by"`
+	SketchBackend string  `mapstructure:"sketch-backend"`
+	SketchK       int     `mapstructure:"sketch-k"`
+	SketchEpsilon float64 `mapstructure:"sketch-epsilon"`
+	SketchDelta   float64 `mapstructure:"sketch-delta"`
+
+	AnalyticsMaxValues    int      `mapstructure:"analytics-max-values"`
+	AnalyticsLimitBuckets []int    `mapstructure:"analytics-limit-buckets"`
+	AnalyticsPairs        []string `mapstructure:"analytics-pairs"`
+	AnalyticsBuffer       int      `mapstructure:"analytics-buffer"`
+
+	SeriesRetentionMinute time.Duration `mapstructure:"series-retention-minute"`
+	SeriesRetentionHour   time.Duration `mapstructure:"series-retention-hour"`
+	SeriesRetentionDay    time.Duration `mapstructure:"series-retention-day"`
+
+	CompactionInterval time.Duration `mapstructure:"compaction-interval"`
+	CompactionMinScore int           `mapstructure:"compaction-min-score"`
+	CompactionTTL      time.Duration `mapstructure:"compaction-ttl"`
+	CompactionLockTTL  time.Duration `mapstructure:"compaction-lock-ttl"`
+
+	LeaderboardSize        int           `mapstructure:"leaderboard-size"`
+	LeaderboardInterval    time.Duration `mapstructure:"leaderboard-interval"`
+	LeaderboardSubscribers int           `mapstructure:"leaderboard-subscribers"`
+	LeaderboardHeartbeat   time.Duration `mapstructure:"leaderboard-heartbeat"`
+
+	AdminToken         string        `mapstructure:"admin-token"`
+	WebhookWorkers     int           `mapstructure:"webhook-workers"`
+	WebhookMaxAttempts int           `mapstructure:"webhook-max-attempts"`
+	WebhookBackoff     time.Duration `mapstructure:"webhook-backoff"`
+	WebhookTimeout     time.Duration `mapstructure:"webhook-timeout"`
+
+	Replica             string        `mapstructure:"replica"`
+	ReplicationPeers    []string      `mapstructure:"replication-peers"`
+	ReplicationToken    string        `mapstructure:"replication-token"`
+	ReplicationInterval time.Duration `mapstructure:"replication-interval"`
+	ReplicationTimeout  time.Duration `mapstructure:"replication-timeout"`
+
+	JobsBackend string        `mapstructure:"jobs-backend"`
+	JobsDir     string        `mapstructure:"jobs-dir"`
+	JobsWorkers int           `mapstructure:"jobs-workers"`
+	JobsQueue   int           `mapstructure:"jobs-queue"`
+	JobsTTL     time.Duration `mapstructure:"jobs-ttl"`
+
+	CaptureFile       string  `mapstructure:"capture-file"`
+	CaptureRate       float64 `mapstructure:"capture-rate"`
+	CaptureMaxSize    int64   `mapstructure:"capture-max-size"`
+	CaptureMaxBackups int     `mapstructure:"capture-max-backups"`
+	CaptureBuffer     int     `mapstructure:"capture-buffer"`
 }
 
 func GetConfig() (Config, error) {
@@ -36,6 +103,54 @@ func GetConfig() (Config, error) {
 	pflag.String("redis-pwd", "", "redis password")
 	pflag.String("log-level", "", "log level to use: debug, info, warn, error")
 	pflag.String("listen", ":8080", "listen address")
+	pflag.String("hash", "xxhash", "hash function of the counter keys: xxhash, sha256, blake2b")
+	pflag.StringSlice("api-keys", nil, "API keys identifying clients in X-API-Key, other clients are identified by their IP")
+	pflag.StringSlice("trusted-proxies", nil, "IPs or CIDRs of the proxies whose X-Forwarded-For is trusted for the client IP")
+	pflag.Int("batch-budget", api.DefaultBatchBudget, "maximum sum of limits computed by one batch request")
+	pflag.Int64("expr-steps", 50_000_000, "maximum steps of the rules of a request, expressions and built-in rules")
+	pflag.Duration("expr-timeout", 2*time.Second, "maximum time spent in the rule expressions of a request")
+	pflag.Int("expr-cache-size", 1024, "number of compiled rule expressions kept")
+	pflag.String("metrics-mode", "exact", "how requests are counted: exact, approx keeps only the heaviest ones without stream, webhooks, series or replication")
+	pflag.String("metrics-rank-by", string(domain.RankByHits), "default ranking of the metrics: hits, clients, items, time, bytes")
+	pflag.String("sketch-backend", "redis", "where approximate counters are kept: redis, memory")
+	pflag.Int("sketch-k", 100, "number of heaviest requests kept by the approximate counters")
+	pflag.Float64("sketch-epsilon", 0.001, "maximum overestimate of an approximate counter, relative to the total count")
+	pflag.Float64("sketch-delta", 0.01, "probability for an approximate counter to exceed its error bound")
+	pflag.Int("analytics-max-values", 1000, "maximum number of distinct values counted per field")
+	pflag.IntSlice("analytics-limit-buckets", []int{10, 100, 1000, 10000, 100000, 1000000}, "upper bounds of the limit histogram")
+	pflag.StringSlice("analytics-pairs", []string{"fst_mod+snd_mod", "fst_str+snd_str"}, "fields counted together")
+	pflag.Int("analytics-buffer", 10000, "number of requests waiting to be analysed before dropping new ones")
+	pflag.Duration("series-retention-minute", 48*time.Hour, "time minute buckets of the series are kept")
+	pflag.Duration("series-retention-hour", 30*24*time.Hour, "time hour buckets of the series are kept")
+	pflag.Duration("series-retention-day", 365*24*time.Hour, "time day buckets of the series are kept")
+	pflag.Duration("compaction-interval", time.Hour, "time between two compactions of the counters")
+	pflag.Int("compaction-min-score", 0, "counters requested less are pruned, disabled when 0")
+	pflag.Duration("compaction-ttl", 0, "counters not requested for this long are pruned, disabled when 0")
+	pflag.Duration("compaction-lock-ttl", 5*time.Minute, "maximum duration of a compaction holding the lock")
+	pflag.Int("leaderboard-size", 10, "number of top requests streamed on leaderboard changes")
+	pflag.Duration("leaderboard-interval", 500*time.Millisecond, "minimum time between two leaderboard checks")
+	pflag.Int("leaderboard-subscribers", 100, "maximum number of concurrent leaderboard subscribers")
+	pflag.Duration("leaderboard-heartbeat", 15*time.Second, "time between two heartbeats on the leaderboard stream")
+	pflag.String("admin-token", "", "bearer token required by the admin API, webhooks and replication are disabled when empty")
+	pflag.Int("webhook-workers", 4, "number of concurrent webhook deliveries")
+	pflag.Int("webhook-max-attempts", 5, "number of delivery attempts before a webhook goes to dead letters")
+	pflag.Duration("webhook-backoff", time.Second, "wait before the first webhook retry, doubled on each attempt")
+	pflag.Duration("webhook-timeout", 5*time.Second, "timeout of a webhook delivery")
+	pflag.String("replica", "", "name of this replica to merge counters with peers, disabled when empty")
+	pflag.StringSlice("replication-peers", nil, "base URLs of the peer instances synced in the background")
+	pflag.String("replication-token", "", "admin token of the peers")
+	pflag.Duration("replication-interval", time.Minute, "time between two syncs with the peers")
+	pflag.Duration("replication-timeout", 30*time.Second, "timeout of a replication snapshot")
+	pflag.String("jobs-backend", "redis", "where job states are kept: redis, memory")
+	pflag.String("jobs-dir", filepath.Join(os.TempDir(), "fizzbuzz-jobs"), "directory where job results are written")
+	pflag.Int("jobs-workers", 2, "number of jobs computed concurrently")
+	pflag.Int("jobs-queue", 100, "number of jobs waiting for a worker before rejecting new ones")
+	pflag.Duration("jobs-ttl", 24*time.Hour, "time after which a job and its result are purged")
+	pflag.String("capture-file", "", "JSONL file where sampled requests are captured, disabled when empty")
+	pflag.Float64("capture-rate", 0.01, "ratio of requests captured, between 0 and 1")
+	pflag.Int64("capture-max-size", 100<<20, "size in bytes before rotating the capture file")
+	pflag.Int("capture-max-backups", 5, "number of rotated capture files kept")
+	pflag.Int("capture-buffer", 1024, "number of captured requests waiting to be written before dropping")
 	pflag.CommandLine.AddGoFlagSet(goflag.CommandLine)
 	pflag.Parse()
 
@@ -80,16 +195,229 @@ func main() {
 
 	go fbRedis.RedisHealth(redisCli, 5*time.Second, logger)
 
-	cacheRepo := repository.NewCacheCounterRepository(redisCli, logger)
+	hasher, err := usecase.NewHasher(config.Hash)
+	if err != nil {
+		logger.Fatal("Invalid hash function", zap.String("hash", config.Hash))
+	}
+	seriesResolutions := []domain.SeriesResolution{domain.SeriesMinute, domain.SeriesHour, domain.SeriesDay}
+	seriesResolutions[0].Retention = config.SeriesRetentionMinute
+	seriesResolutions[1].Retention = config.SeriesRetentionHour
+	seriesResolutions[2].Retention = config.SeriesRetentionDay
+	seriesRepo := repository.NewSeriesRepository(redisCli, seriesResolutions, logger)
+	cacheOptions := []repository.CacheOption{repository.WithSeries(seriesRepo)}
+	if config.Replica != "" {
+		cacheOptions = append(cacheOptions, repository.WithGlobalRanking())
+	}
+	cacheRepo := repository.NewCacheCounterRepository(redisCli, hasher, logger, cacheOptions...)
 	fbService := service.NewFizzBuzzService(logger)
-	metricService := service.NewMetricService(cacheRepo, logger)
+	var metricService service.MetricService
+	switch config.MetricsMode {
+	case "exact":
+		if !domain.Ranking(config.MetricsRankBy).Valid() {
+			logger.Fatal("Unknown metrics ranking", zap.String("rank-by", config.MetricsRankBy))
+		}
+		metricService = service.NewMetricService(cacheRepo, domain.Ranking(config.MetricsRankBy), logger)
+	case "approx":
+		sketch, err := usecase.NewCountMinSketch(config.SketchEpsilon, config.SketchDelta)
+		if err != nil {
+			sugarLogger.Fatal(err)
+		}
+		var sketchRepo repository.SketchRepository
+		switch config.SketchBackend {
+		case "memory":
+			sketchRepo = repository.NewMemorySketchRepository(sketch, config.SketchK, hasher)
+		case "redis":
+			sketchRepo = repository.NewRedisSketchRepository(redisCli, sketch, config.SketchK, hasher, logger)
+		default:
+			logger.Fatal("Unknown sketch backend", zap.String("backend", config.SketchBackend))
+		}
+		metricService = service.NewApproxMetricService(sketchRepo, logger)
+	default:
+		logger.Fatal("Unknown metrics mode", zap.String("mode", config.MetricsMode))
+	}
+
+	if config.AnalyticsMaxValues < 1 {
+		logger.Fatal("Analytics max values should be positive", zap.Int("max-values", config.AnalyticsMaxValues))
+	}
+	analysedFields := (&domain.FizzBuzzRequest{}).Fields()
+	analyticsPairs := make([][2]string, len(config.AnalyticsPairs))
+	for i, pair := range config.AnalyticsPairs {
+		fields := strings.Split(pair, "+")
+		if len(fields) != 2 {
+			logger.Fatal("Invalid analytics pair", zap.String("pair", pair))
+		}
+		_, okFst := analysedFields[fields[0]]
+		_, okSnd := analysedFields[fields[1]]
+		if !okFst || !okSnd {
+			logger.Fatal("Unknown analytics pair field", zap.String("pair", pair))
+		}
+		analyticsPairs[i] = [2]string{fields[0], fields[1]}
+	}
+	analyticsService := service.NewAnalyticsService(
+		repository.NewAnalyticsRepository(redisCli, config.AnalyticsMaxValues, logger),
+		service.AnalyticsConfig{
+			LimitBuckets: config.AnalyticsLimitBuckets,
+			Pairs:        analyticsPairs,
+			Buffer:       config.AnalyticsBuffer,
+		}, logger)
+	metricService = service.NewAnalyticsMetricService(metricService, analyticsService)
+
+	if config.CompactionMinScore > 0 || config.CompactionTTL > 0 {
+		compactionService := service.NewCompactionService(repository.NewCompactionRepository(redisCli, logger),
+			service.CompactionConfig{
+				Interval: config.CompactionInterval,
+				MinScore: config.CompactionMinScore,
+				TTL:      config.CompactionTTL,
+				LockTTL:  config.CompactionLockTTL,
+			}, logger)
+		go compactionService.Run(context.Background())
+	}
+
+	// Live features follow the changes of the exact counters, the sketch has none
+	exact := config.MetricsMode == "exact"
+	if !exact {
+		logger.Warn("Leaderboard stream, webhooks and series are disabled with approximate metrics")
+	}
 
-	router, err := api.Setup(fbService, metricService, logger)
+	var leaderboardService service.LeaderboardService
+	if exact {
+		leaderboardService = service.NewLeaderboardService(cacheRepo, config.LeaderboardSize,
+			config.LeaderboardInterval, config.LeaderboardSubscribers, logger)
+		go func() {
+			if err := leaderboardService.Run(context.Background()); err != nil {
+				logger.Error("Leaderboard stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	var webhookService service.WebhookService
+	switch {
+	case !exact:
+	case config.AdminToken == "":
+		logger.Warn("Webhooks are disabled without an admin token")
+	default:
+		webhookService = service.NewWebhookService(repository.NewWebhookRepository(redisCli, logger), cacheRepo,
+			service.WebhookConfig{
+				Workers:     config.WebhookWorkers,
+				MaxAttempts: config.WebhookMaxAttempts,
+				BaseBackoff: config.WebhookBackoff,
+				Timeout:     config.WebhookTimeout,
+			}, logger)
+		go func() {
+			if err := webhookService.Run(context.Background()); err != nil {
+				logger.Error("Webhooks stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	var replicationService service.ReplicationService
+	if config.Replica != "" {
+		if !exact {
+			logger.Fatal("Replication requires exact metrics", zap.String("replica", config.Replica))
+		}
+		if config.AdminToken == "" {
+			logger.Fatal("Replication requires an admin token", zap.String("replica", config.Replica))
+		}
+		if len(config.ReplicationPeers) > 0 && config.ReplicationInterval <= 0 {
+			logger.Fatal("Replication interval should be positive", zap.Duration("interval", config.ReplicationInterval))
+		}
+		replicationRepo := repository.NewReplicationRepository(redisCli, hasher, config.Replica, logger)
+		rankCtx, cancelRank := context.WithTimeout(context.Background(), config.ReplicationTimeout)
+		if err := replicationRepo.Rank(rankCtx); err != nil {
+			logger.Fatal("Impossible to rank the global counters", zap.Error(err))
+		}
+		cancelRank()
+		replicationService = service.NewReplicationService(
+			replicationRepo,
+			service.ReplicationConfig{
+				Peers:    config.ReplicationPeers,
+				Token:    config.ReplicationToken,
+				Interval: config.ReplicationInterval,
+				Timeout:  config.ReplicationTimeout,
+			}, logger)
+		if len(config.ReplicationPeers) > 0 {
+			go replicationService.Run(context.Background())
+		}
+	}
+
+	var jobRepo repository.JobRepository
+	switch config.JobsBackend {
+	case "memory":
+		jobRepo = repository.NewMemoryJobRepository()
+	case "redis":
+		jobRepo = repository.NewRedisJobRepository(redisCli, config.JobsTTL, logger)
+	default:
+		logger.Fatal("Unknown jobs backend", zap.String("backend", config.JobsBackend))
+	}
+	jobService, err := service.NewJobService(jobRepo, fbService, config.JobsDir,
+		config.JobsWorkers, config.JobsQueue, config.JobsTTL, logger)
+	if err != nil {
+		sugarLogger.Fatal(err)
+	}
+	go jobService.RunJanitor(context.Background(), time.Minute)
+
+	apiOptions := []api.Option{
+		api.WithAPIKeys(config.APIKeys),
+		api.WithTrustedProxies(config.TrustedProxies),
+		api.WithBatchBudget(config.BatchBudget),
+		api.WithRules(service.NewRuleService(service.RuleConfig{
+			ExprSteps:     config.ExprSteps,
+			ExprTimeout:   config.ExprTimeout,
+			ExprCacheSize: config.ExprCacheSize,
+		}, logger)),
+		api.WithAnalytics(analyticsService),
+		api.WithJobs(jobService),
+	}
+	if exact {
+		apiOptions = append(apiOptions,
+			api.WithSeries(service.NewSeriesService(seriesRepo, cacheRepo, seriesResolutions, logger)),
+			api.WithLeaderboard(leaderboardService, config.LeaderboardHeartbeat))
+	}
+	if webhookService != nil {
+		apiOptions = append(apiOptions, api.WithWebhooks(webhookService, config.AdminToken))
+	}
+	if replicationService != nil {
+		apiOptions = append(apiOptions, api.WithReplication(replicationService, config.AdminToken))
+	}
+	var captureService service.CaptureService
+	if config.CaptureFile != "" {
+		captureRepo, err := repository.NewFileCaptureRepository(config.CaptureFile,
+			config.CaptureMaxSize, config.CaptureMaxBackups, logger)
+		if err != nil {
+			sugarLogger.Fatal(err)
+		}
+		captureService = service.NewCaptureService(captureRepo, config.CaptureRate, config.CaptureBuffer, logger)
+		apiOptions = append(apiOptions, api.WithCapture(captureService))
+	}
+
+	router, err := api.Setup(fbService, metricService, logger, apiOptions...)
 	if err != nil {
 		sugarLogger.Fatal(err)
 	}
 
-	logger.Fatal("fizzbuzz service crashed", zap.Error(router.Run(config.Listen)))
+	server := &http.Server{Addr: config.Listen, Handler: router, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("fizzbuzz service crashed", zap.Error(err))
+		}
+	}()
+	<-ctx.Done()
+
+	logger.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Impossible to drain requests", zap.Error(err))
+	}
+	// Queued records are flushed once no request can add new ones
+	analyticsService.Close()
+	if captureService != nil {
+		if err := captureService.Close(); err != nil {
+			logger.Error("Impossible to close the capture", zap.Error(err))
+		}
+	}
 }
 
 func initLog(config Config) (logger *zap.Logger, err error) {
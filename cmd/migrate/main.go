@@ -0,0 +1,49 @@
+package main
+
+import (
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// migrate rekeys the stored counters with the current request identity,
+// it must be run after a change of domain.IdentityVersion.
+func main() {
+	host := pflag.String("redis-host", "localhost", "host of redis")
+	port := pflag.String("redis-port", "6379", "port for redis")
+	pwd := pflag.String("redis-pwd", "", "redis password")
+	hash := pflag.String("hash", "xxhash", "hash function of the counter keys: xxhash, sha256, blake2b")
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		fmt.Printf("Impossible to init logger: %s\n", err)
+		os.Exit(1)
+	}
+
+	hasher, err := usecase.NewHasher(*hash)
+	if err != nil {
+		logger.Fatal("Invalid hash function", zap.String("hash", *hash))
+	}
+
+	redisCli := fbRedis.NewRedis(*host, *port, *pwd)
+	if err := redisCli.Ping(context.Background()).Err(); err != nil {
+		logger.Fatal("Impossible to connect to redis", zap.Error(err))
+	}
+
+	report, err := repository.NewCounterMigrator(redisCli, hasher, logger).MigrateIdentity(context.Background())
+	logger.Info("Migration report",
+		zap.Int("scanned", report.Scanned),
+		zap.Int("migrated", report.Migrated),
+		zap.Int("merged", report.Merged),
+		zap.Int("invalid", report.Invalid))
+	if err != nil {
+		logger.Fatal("Migration failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	fbRedis "FizzBuzz/repository/redis"
+	"FizzBuzz/service"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// sync merges the counters of other replicas into the local redis, from their
+// snapshot endpoint or from exported files, and can export the local snapshot.
+// Merges are idempotent so it can be run again or in any order.
+func main() {
+	host := pflag.String("redis-host", "localhost", "host of redis")
+	port := pflag.String("redis-port", "6379", "port for redis")
+	pwd := pflag.String("redis-pwd", "", "redis password")
+	replica := pflag.String("replica", "", "name of the local replica, unique among the instances")
+	peers := pflag.StringSlice("peer", nil, "base URL of a peer instance to merge")
+	token := pflag.String("token", "", "admin token of the peers")
+	timeout := pflag.Duration("timeout", 30*time.Second, "timeout of each snapshot")
+	imports := pflag.StringSlice("import", nil, "exported snapshot file to merge")
+	hash := pflag.String("hash", "xxhash", "hash function of the counter keys: xxhash, sha256, blake2b")
+	export := pflag.String("export", "", "file to export the local snapshot to, after merging")
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		fmt.Printf("Impossible to init logger: %s\n", err)
+		os.Exit(1)
+	}
+	if *replica == "" {
+		logger.Fatal("A replica name is required")
+	}
+	hasher, err := usecase.NewHasher(*hash)
+	if err != nil {
+		logger.Fatal("Invalid hash function", zap.String("hash", *hash))
+	}
+
+	redisCli := fbRedis.NewRedis(*host, *port, *pwd)
+	if err := redisCli.Ping(context.Background()).Err(); err != nil {
+		logger.Fatal("Impossible to connect to redis", zap.Error(err))
+	}
+
+	replicationService := service.NewReplicationService(
+		repository.NewReplicationRepository(redisCli, hasher, *replica, logger),
+		service.ReplicationConfig{Peers: *peers, Token: *token, Timeout: *timeout}, logger)
+
+	failed := false
+	for _, file := range *imports {
+		payload, err := os.ReadFile(file)
+		if err != nil {
+			logger.Fatal("Impossible to read snapshot", zap.String("file", file), zap.Error(err))
+		}
+		var snapshot domain.ReplicaSnapshot
+		if err := json.Unmarshal(payload, &snapshot); err != nil {
+			logger.Fatal("Invalid snapshot", zap.String("file", file), zap.Error(err))
+		}
+		merged, err := replicationService.Merge(snapshot)
+		if err != nil {
+			logger.Error("Merge failed", zap.String("file", file), zap.Error(err))
+			failed = true
+			continue
+		}
+		logger.Info("Snapshot merged", zap.String("file", file),
+			zap.String("replica", snapshot.Replica), zap.Int("merged", merged))
+	}
+
+	merged, err := replicationService.Sync(context.Background())
+	logger.Info("Peers merged", zap.Int("peers", len(*peers)), zap.Int("merged", merged))
+	failed = failed || err != nil
+
+	if *export != "" {
+		snapshot, err := replicationService.Snapshot()
+		if err != nil {
+			logger.Fatal("Impossible to take snapshot", zap.Error(err))
+		}
+		payload, err := json.Marshal(snapshot)
+		if err != nil {
+			logger.Fatal("Impossible to marshal snapshot", zap.Error(err))
+		}
+		if err := os.WriteFile(*export, payload, 0o644); err != nil {
+			logger.Fatal("Impossible to write snapshot", zap.Error(err))
+		}
+		logger.Info("Snapshot exported", zap.String("file", *export), zap.Int("counters", len(snapshot.Counters)))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,48 @@
+package domain
+
+import "strconv"
+
+// Analysable requests are counted per field by the analytics
+type Analysable interface {
+	// Fields returns the value of each analysed field
+	Fields() map[string]string
+}
+
+func (fbr *FizzBuzzRequest) Fields() map[string]string {
+	return map[string]string{
+		"fst_mod": strconv.Itoa(fbr.FstModulo),
+		"snd_mod": strconv.Itoa(fbr.SndModulo),
+		"limit":   strconv.Itoa(fbr.Limit),
+		"fst_str": fbr.FstStr,
+		"snd_str": fbr.SndStr,
+	}
+}
+
+// AnalyticsSample is what a request adds to the analytics, the member counted
+// in each dimension and its bucket of the limit histogram.
+type AnalyticsSample struct {
+	Members map[string]string
+	Bucket  string
+}
+
+type AnalyticsValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type AnalyticsPair struct {
+	Values []string `json:"values"`
+	Count  int      `json:"count"`
+}
+
+// HistogramBucket counts the limits above the previous bucket and up to UpperBound
+type HistogramBucket struct {
+	UpperBound string `json:"le"`
+	Count      int    `json:"count"`
+}
+
+type AnalyticsReport struct {
+	Fields map[string][]AnalyticsValue `json:"fields"`
+	Pairs  map[string][]AnalyticsPair  `json:"pairs"`
+	Limits []HistogramBucket           `json:"limits"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "math/big"
+
+// MaxBigDigits is the maximum number of digits of the numerals of a big request
+const MaxBigDigits = 1000
+
+// BigFizzBuzzRequest computes the terms from Start to End included. Numbers are
+// decimal strings so they can go far beyond int64, like "1000000000000000000000000000000".
+type BigFizzBuzzRequest struct {
+	FstModulo string `json:"fst_mod" binding:"required,bigint"`
+	SndModulo string `json:"snd_mod" binding:"required,bigint"`
+	Start     string `json:"start" binding:"required,bigint"`
+	End       string `json:"end" binding:"required,bigint"`
+	FstStr    string `json:"fst_str" binding:"required,template"`
+	SndStr    string `json:"snd_str" binding:"required,template"`
+}
+
+// ParseBigInt parses a decimal numeral with an optional minus sign, at most
+// MaxBigDigits digits long.
+func ParseBigInt(s string) (*big.Int, bool) {
+	digits := s
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > MaxBigDigits {
+		return nil, false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return nil, false
+		}
+	}
+	return new(big.Int).SetString(s, 10)
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// CapturedRequest is a sampled incoming request, stored one per line in the
+// capture file so it can be replayed by load tests.
+type CapturedRequest struct {
+	RequestID string    `json:"request_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Timestamp time.Time `json:"timestamp"`
+	Client    string    `json:"client"`
+	Status    int       `json:"status"`
+}
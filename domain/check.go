@@ -0,0 +1,30 @@
+package domain
+
+// MaxCheckMismatches is the maximum number of mismatches listed by a check report
+const MaxCheckMismatches = 1000
+
+// CheckMismatch is a submitted term differing from the expected one, N is the
+// number of the term starting at 1.
+type CheckMismatch struct {
+	N        int    `json:"n"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// CheckReport grades a submitted sequence against the expected one. Missing
+// terms are expected ones which were not submitted, extra terms are submitted
+// after the limit. The score is the share of correct terms among the expected
+// and extra ones.
+type CheckReport struct {
+	Limit         int             `json:"limit"`
+	Submitted     int             `json:"submitted"`
+	Correct       int             `json:"correct"`
+	FirstMismatch *CheckMismatch  `json:"first_mismatch,omitempty"`
+	MismatchCount int             `json:"mismatch_count"`
+	Mismatches    []CheckMismatch `json:"mismatches"`
+	// Truncated tells if there are more mismatches than the listed ones
+	Truncated bool    `json:"truncated"`
+	Missing   int     `json:"missing"`
+	Extra     int     `json:"extra"`
+	Score     float64 `json:"score"`
+}
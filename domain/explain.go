@@ -0,0 +1,22 @@
+package domain
+
+// Names of the rules of a fizzbuzz request in the explained terms
+const (
+	RuleFst = "fst"
+	RuleSnd = "snd"
+)
+
+// Residues are the remainders of the number by the modulos of the rules
+type Residues struct {
+	Fst int `json:"fst"`
+	Snd int `json:"snd"`
+}
+
+// ExplainedTerm tells why the term of N is Value: the rules whose modulo
+// divides N, in the order their words are written.
+type ExplainedTerm struct {
+	N        int      `json:"n"`
+	Value    string   `json:"value"`
+	Matched  []string `json:"matched"`
+	Residues Residues `json:"residues"`
+}
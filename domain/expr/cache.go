@@ -0,0 +1,65 @@
+package expr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache shares the programs of identical expressions, the least recently used
+// ones are evicted. Invalid expressions are cached with their error, except the
+// ones too long to be compiled which would hold memory for nothing.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries *list.List
+	sources map[string]*list.Element
+}
+
+type cacheEntry struct {
+	source  string
+	program *Program
+	err     error
+}
+
+func NewCache(size int) *Cache {
+	return &Cache{size: size, entries: list.New(), sources: make(map[string]*list.Element, size)}
+}
+
+// Compile returns the cached program of src, compiling it on the first use
+func (c *Cache) Compile(src string) (*Program, error) {
+	if len(src) > MaxSourceLength {
+		return Compile(src)
+	}
+	c.mu.Lock()
+	if elem, ok := c.sources[src]; ok {
+		c.entries.MoveToFront(elem)
+		entry := elem.Value.(*cacheEntry)
+		c.mu.Unlock()
+		return entry.program, entry.err
+	}
+	c.mu.Unlock()
+
+	// Compiling outside the lock, two goroutines may compile the same source
+	program, err := Compile(src)
+	if c.size <= 0 {
+		return program, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sources[src]; !ok {
+		c.sources[src] = c.entries.PushFront(&cacheEntry{source: src, program: program, err: err})
+		if c.entries.Len() > c.size {
+			oldest := c.entries.Back()
+			c.entries.Remove(oldest)
+			delete(c.sources, oldest.Value.(*cacheEntry).source)
+		}
+	}
+	return program, err
+}
+
+// Len is the number of cached expressions
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
@@ -0,0 +1,128 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// matches runs the program for every number from 1 to 20
+func matches(t *testing.T, src string) []int64 {
+	program, err := Compile(src)
+	assert.NoError(t, err)
+	if err != nil {
+		return nil
+	}
+	budget := NewBudget(1_000_000, time.Second)
+	var res []int64
+	for n := int64(1); n <= 20; n++ {
+		ok, err := program.Run(n, budget)
+		assert.NoError(t, err)
+		if ok {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		src     string
+		matches []int64
+	}{
+		{src: "n % 3 == 0 && n > 10 || digits(n) contains 7", matches: []int64{7, 12, 15, 17, 18}},
+		{src: "n % 3 == 0 && (n > 10 || digits(n) contains 7)", matches: []int64{12, 15, 18}},
+		{src: "!(n <= 18) || n == 2 * 3 + 1", matches: []int64{7, 19, 20}},
+		{src: "[1, 4, 9 + 7] contains n", matches: []int64{1, 4, 16}},
+		{src: "sum(digits(n)) == 2 && len(digits(n)) >= 2", matches: []int64{11, 20}},
+		{src: "is_prime(n) != (n % 2 == 1)", matches: []int64{1, 2, 9, 15}},
+		{src: "sqrt(n) * sqrt(n) == n && abs(-n) / 4 >= 1", matches: []int64{4, 9, 16}},
+		{src: "true && -n < -19 || false", matches: []int64{20}},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			assert.Equal(t, test.matches, matches(t, test.src))
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err *Error
+	}{
+		{src: "n % 3", err: &Error{Pos: 3, Message: "Expression should be bool but is int"}},
+		{src: "n % 3 == 0 &&", err: &Error{Pos: 14, Message: "Unexpected end of expression"}},
+		{src: "n $ 3", err: &Error{Pos: 3, Message: "Unexpected character '$'"}},
+		{src: "(n == 1", err: &Error{Pos: 8, Message: "Expected \")\" but found end of expression"}},
+		{src: "x == 1", err: &Error{Pos: 1, Message: "Unknown name \"x\""}},
+		{src: "n == true", err: &Error{Pos: 3, Message: "Cannot compare int and bool"}},
+		{src: "digits(n) > 2", err: &Error{Pos: 1, Message: "Left of > should be int but is list"}},
+		{src: "n contains 2", err: &Error{Pos: 1, Message: "Left of contains should be list but is int"}},
+		{src: "sum(n) == 1", err: &Error{Pos: 5, Message: "Argument of sum should be list but is int"}},
+		{src: "n < 2 < 3", err: &Error{Pos: 7, Message: "Unexpected \"<\""}},
+		{src: "n == 99999999999999999999", err: &Error{Pos: 6, Message: "Integer 99999999999999999999 is too large"}},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			_, err := Compile(test.src)
+			assert.Equal(t, test.err, err)
+		})
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	program, err := Compile("n / (n - 3) > 0")
+	assert.NoError(t, err)
+	budget := NewBudget(100, time.Second)
+	_, err = program.Run(3, budget)
+	assert.Equal(t, &Error{Pos: 3, Message: "Division by zero"}, err)
+	// The budget keeps failing once an error occurred
+	_, err = program.Run(4, budget)
+	assert.Error(t, err)
+
+	program, err = Compile("n > 0 && n > 1")
+	assert.NoError(t, err)
+	budget = NewBudget(10, time.Second)
+	for n := int64(1); n <= 2 && err == nil; n++ {
+		_, err = program.Run(n, budget)
+	}
+	assert.ErrorIs(t, err, ErrStepBudget)
+
+	program, err = Compile("is_prime(n)")
+	assert.NoError(t, err)
+	_, err = program.Run(1_000_000_007, NewBudget(1000, time.Second))
+	assert.ErrorIs(t, err, ErrStepBudget)
+
+	budget = NewBudget(1_000_000, -time.Second)
+	for n := int64(1); err == nil || err == ErrStepBudget; n++ {
+		_, err = program.Run(n, budget)
+	}
+	assert.ErrorIs(t, err, ErrTimeBudget)
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+	a, err := cache.Compile("n == 1")
+	assert.NoError(t, err)
+	again, _ := cache.Compile("n == 1")
+	assert.Same(t, a, again)
+
+	_, err = cache.Compile("n ==")
+	assert.Error(t, err)
+	_, err = cache.Compile("n == 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cache.Len())
+
+	// n == 1 was the least recently used
+	again, _ = cache.Compile("n == 1")
+	assert.NotSame(t, a, again)
+
+	// Sources too long are rejected without being kept
+	cache = NewCache(2)
+	_, err = cache.Compile(strings.Repeat("n", MaxSourceLength+1))
+	assert.Error(t, err)
+	assert.Equal(t, 0, cache.Len())
+}
@@ -0,0 +1,102 @@
+// Package expr is a small sandboxed expression language for the conditions of
+// the rules, such as `n % 3 == 0 && n > 10 || digits(n) contains 7`.
+// Expressions are compiled once to a bytecode program run for every number
+// within a step and time budget.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MaxSourceLength is the maximum length of an expression
+const MaxSourceLength = 1024
+
+// Error is an error of an expression, Pos is the column where it occurred
+// starting at 1.
+type Error struct {
+	Pos     int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Message, e.Pos)
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokInt
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value int64
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are sorted longest first so the lexer is greedy
+var operators = []string{
+	"||", "&&", "==", "!=", "<=", ">=",
+	"<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ",",
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isLetter(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(src); {
+		c := src[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case isDigit(c):
+			start := pos
+			for pos < len(src) && isDigit(src[pos]) {
+				pos++
+			}
+			value, err := strconv.ParseInt(src[start:pos], 10, 64)
+			if err != nil {
+				return nil, errorf(start, "Integer %s is too large", src[start:pos])
+			}
+			tokens = append(tokens, token{kind: tokInt, text: src[start:pos], value: value, pos: start})
+		case isLetter(c):
+			start := pos
+			for pos < len(src) && (isLetter(src[pos]) || isDigit(src[pos])) {
+				pos++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:pos], pos: start})
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if len(src)-pos >= len(candidate) && src[pos:pos+len(candidate)] == candidate {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorf(pos, "Unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+			pos += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
@@ -0,0 +1,320 @@
+package expr
+
+// valueType is the static type of an expression, every expression has a single
+// type known at compile time so the program doesn't check them when running.
+type valueType int
+
+const (
+	typeInt valueType = iota
+	typeBool
+	typeList
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeBool:
+		return "bool"
+	case typeList:
+		return "list"
+	}
+	return "int"
+}
+
+type nodeKind int
+
+const (
+	nodeInt nodeKind = iota
+	nodeBool
+	nodeVar
+	nodeList
+	nodeUnary
+	nodeBinary
+	nodeCall
+)
+
+type node struct {
+	kind     nodeKind
+	typ      valueType
+	pos      int
+	op       string
+	value    int64
+	children []*node
+}
+
+// function is a builtin, args are the types of its arguments
+type function struct {
+	args   []valueType
+	result valueType
+}
+
+var functions = map[string]function{
+	"digits":   {args: []valueType{typeInt}, result: typeList},
+	"sum":      {args: []valueType{typeList}, result: typeInt},
+	"len":      {args: []valueType{typeList}, result: typeInt},
+	"abs":      {args: []valueType{typeInt}, result: typeInt},
+	"sqrt":     {args: []valueType{typeInt}, result: typeInt},
+	"is_prime": {args: []valueType{typeInt}, result: typeBool},
+}
+
+// maxNesting bounds the recursion of the parser
+const maxNesting = 64
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token when it is one of ops
+func (p *parser) accept(ops ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return t, false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return p.next(), true
+		}
+	}
+	return t, false
+}
+
+func (p *parser) expect(op string) error {
+	if t, ok := p.accept(op); !ok {
+		return errorf(t.pos, "Expected %q but found %s", op, t)
+	}
+	return nil
+}
+
+func expectType(n *node, typ valueType, what string) error {
+	if n.typ != typ {
+		return errorf(n.pos, "%s should be %s but is %s", what, typ, n.typ)
+	}
+	return nil
+}
+
+// parse returns the typed tree of a boolean expression
+func parse(src string) (*node, error) {
+	if len(src) > MaxSourceLength {
+		return nil, errorf(MaxSourceLength, "Expression is longer than %d characters", MaxSourceLength)
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.pos, "Unexpected %s", t)
+	}
+	if err := expectType(root, typeBool, "Expression"); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// binaryLevel parses operands separated by ops, left associative
+func (p *parser) binaryLevel(operand func() (*node, error),
+	check func(op token, left, right *node) (valueType, error),
+	ops ...string) (*node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		typ, err := check(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+		left = &node{kind: nodeBinary, typ: typ, pos: op.pos, op: op.text, children: []*node{left, right}}
+	}
+}
+
+func checkBools(op token, left, right *node) (valueType, error) {
+	if err := expectType(left, typeBool, "Left of "+op.text); err != nil {
+		return 0, err
+	}
+	return typeBool, expectType(right, typeBool, "Right of "+op.text)
+}
+
+func checkInts(op token, left, right *node) (valueType, error) {
+	if err := expectType(left, typeInt, "Left of "+op.text); err != nil {
+		return 0, err
+	}
+	return typeInt, expectType(right, typeInt, "Right of "+op.text)
+}
+
+func (p *parser) parseOr() (*node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNesting {
+		return nil, errorf(p.peek().pos, "Expression is nested more than %d times", maxNesting)
+	}
+	return p.binaryLevel(p.parseAnd, checkBools, "||")
+}
+
+func (p *parser) parseAnd() (*node, error) {
+	return p.binaryLevel(p.parseComparison, checkBools, "&&")
+}
+
+// parseComparison doesn't chain, `a < b < c` is an error
+func (p *parser) parseComparison() (*node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "contains")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case "==", "!=":
+		if left.typ == typeList || left.typ != right.typ {
+			return nil, errorf(op.pos, "Cannot compare %s and %s", left.typ, right.typ)
+		}
+	case "contains":
+		if err := expectType(left, typeList, "Left of contains"); err != nil {
+			return nil, err
+		}
+		if err := expectType(right, typeInt, "Right of contains"); err != nil {
+			return nil, err
+		}
+	default:
+		if _, err := checkInts(op, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return &node{kind: nodeBinary, typ: typeBool, pos: op.pos, op: op.text, children: []*node{left, right}}, nil
+}
+
+func (p *parser) parseSum() (*node, error) {
+	return p.binaryLevel(p.parseProduct, checkInts, "+", "-")
+}
+
+func (p *parser) parseProduct() (*node, error) {
+	return p.binaryLevel(p.parseUnary, checkInts, "*", "/", "%")
+}
+
+func (p *parser) parseUnary() (*node, error) {
+	op, ok := p.accept("!", "-")
+	if !ok {
+		return p.parsePrimary()
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNesting {
+		return nil, errorf(op.pos, "Expression is nested more than %d times", maxNesting)
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	typ := typeInt
+	if op.text == "!" {
+		typ = typeBool
+	}
+	if err := expectType(operand, typ, "Operand of "+op.text); err != nil {
+		return nil, err
+	}
+	return &node{kind: nodeUnary, typ: typ, pos: op.pos, op: op.text, children: []*node{operand}}, nil
+}
+
+func (p *parser) parsePrimary() (*node, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokInt:
+		return &node{kind: nodeInt, typ: typeInt, pos: t.pos, value: t.value}, nil
+	case t.kind == tokOp && t.text == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case t.kind == tokOp && t.text == "[":
+		list := &node{kind: nodeList, typ: typeList, pos: t.pos}
+		if _, ok := p.accept("]"); ok {
+			return list, nil
+		}
+		for {
+			item, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			if err := expectType(item, typeInt, "List item"); err != nil {
+				return nil, err
+			}
+			list.children = append(list.children, item)
+			if _, ok := p.accept(","); !ok {
+				return list, p.expect("]")
+			}
+		}
+	case t.kind == tokIdent:
+		return p.parseIdent(t)
+	}
+	return nil, errorf(t.pos, "Unexpected %s", t)
+}
+
+func (p *parser) parseIdent(t token) (*node, error) {
+	switch t.text {
+	case "n":
+		return &node{kind: nodeVar, typ: typeInt, pos: t.pos}, nil
+	case "true", "false":
+		value := int64(0)
+		if t.text == "true" {
+			value = 1
+		}
+		return &node{kind: nodeBool, typ: typeBool, pos: t.pos, value: value}, nil
+	}
+
+	fn, ok := functions[t.text]
+	if !ok {
+		return nil, errorf(t.pos, "Unknown name %s", t)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	call := &node{kind: nodeCall, typ: fn.result, pos: t.pos, op: t.text}
+	for i, typ := range fn.args {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectType(arg, typ, "Argument of "+t.text); err != nil {
+			return nil, err
+		}
+		call.children = append(call.children, arg)
+	}
+	return call, p.expect(")")
+}
@@ -0,0 +1,366 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	ErrStepBudget = errors.New("expression step budget exceeded")
+	ErrTimeBudget = errors.New("expression time budget exceeded")
+)
+
+type opcode uint8
+
+const (
+	opInt opcode = iota
+	opVar
+	opList
+	opNeg
+	opNot
+	opAdd
+	opSub
+	opMul
+	opDiv
+	opMod
+	opEq
+	opNe
+	opLt
+	opLe
+	opGt
+	opGe
+	opContains
+	// opJumpFalse jumps keeping the false operand, or pops it, to short-circuit &&
+	opJumpFalse
+	// opJumpTrue jumps keeping the true operand, or pops it, to short-circuit ||
+	opJumpTrue
+	opDigits
+	opSum
+	opLen
+	opAbs
+	opSqrt
+	opIsPrime
+)
+
+var binaryOps = map[string]opcode{
+	"+": opAdd, "-": opSub, "*": opMul, "/": opDiv, "%": opMod,
+	"==": opEq, "!=": opNe, "<": opLt, "<=": opLe, ">": opGt, ">=": opGe,
+	"contains": opContains,
+}
+
+var callOps = map[string]opcode{
+	"digits": opDigits, "sum": opSum, "len": opLen, "abs": opAbs, "sqrt": opSqrt, "is_prime": opIsPrime,
+}
+
+type instruction struct {
+	op  opcode
+	arg int64
+	pos int
+}
+
+// Program is a compiled expression, it can be shared and run concurrently
+type Program struct {
+	source string
+	code   []instruction
+	// stack is the maximum depth of the stack
+	stack int
+}
+
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Compile parses and compiles a boolean expression of n
+func Compile(src string) (*Program, error) {
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{program: &Program{source: src}}
+	c.compile(root)
+	return c.program, nil
+}
+
+type compiler struct {
+	program *Program
+	depth   int
+}
+
+func (c *compiler) emit(op opcode, arg int64, pos int, effect int) int {
+	c.program.code = append(c.program.code, instruction{op: op, arg: arg, pos: pos})
+	c.depth += effect
+	if c.depth > c.program.stack {
+		c.program.stack = c.depth
+	}
+	return len(c.program.code) - 1
+}
+
+func (c *compiler) compile(n *node) {
+	switch n.kind {
+	case nodeInt, nodeBool:
+		c.emit(opInt, n.value, n.pos, 1)
+	case nodeVar:
+		c.emit(opVar, 0, n.pos, 1)
+	case nodeList:
+		for _, item := range n.children {
+			c.compile(item)
+		}
+		c.emit(opList, int64(len(n.children)), n.pos, 1-len(n.children))
+	case nodeUnary:
+		c.compile(n.children[0])
+		if n.op == "!" {
+			c.emit(opNot, 0, n.pos, 0)
+		} else {
+			c.emit(opNeg, 0, n.pos, 0)
+		}
+	case nodeBinary:
+		if n.op == "&&" || n.op == "||" {
+			c.compile(n.children[0])
+			op := opJumpFalse
+			if n.op == "||" {
+				op = opJumpTrue
+			}
+			jump := c.emit(op, 0, n.pos, -1)
+			c.compile(n.children[1])
+			c.program.code[jump].arg = int64(len(c.program.code))
+			return
+		}
+		c.compile(n.children[0])
+		c.compile(n.children[1])
+		c.emit(binaryOps[n.op], 0, n.pos, -1)
+	case nodeCall:
+		for _, arg := range n.children {
+			c.compile(arg)
+		}
+		c.emit(callOps[n.op], 0, n.pos, 1-len(n.children))
+	}
+}
+
+// Budget bounds the steps and the time of every run sharing it, the first
+// error is kept and fails the next runs. It must not be shared between goroutines.
+type Budget struct {
+	steps    int64
+	deadline time.Time
+	err      error
+}
+
+// timeCheckSteps is the number of steps between two checks of the deadline
+const timeCheckSteps = 1024
+
+func NewBudget(steps int64, timeout time.Duration) *Budget {
+	return &Budget{steps: steps, deadline: time.Now().Add(timeout)}
+}
+
+// Err is the error which stopped a run, if any
+func (b *Budget) Err() error {
+	return b.err
+}
+
+// Spend uses count steps for work done outside of the expressions, such as
+// built-in rules, it returns false once the budget is exhausted.
+func (b *Budget) Spend(count int64) bool {
+	return b.err == nil && b.spend(count)
+}
+
+// spend uses count steps, it checks the deadline from time to time
+func (b *Budget) spend(count int64) bool {
+	before := b.steps
+	b.steps -= count
+	if b.steps < 0 {
+		b.err = ErrStepBudget
+		return false
+	}
+	if before/timeCheckSteps != b.steps/timeCheckSteps && time.Now().After(b.deadline) {
+		b.err = ErrTimeBudget
+		return false
+	}
+	return true
+}
+
+// value is an int, a bool as 0 or 1, or a list
+type value struct {
+	i    int64
+	list []int64
+}
+
+func boolValue(b bool) value {
+	if b {
+		return value{i: 1}
+	}
+	return value{}
+}
+
+// Run evaluates the program for n, it returns false with the error of the budget
+// when it is exhausted or when the expression fails, like a division by zero.
+func (p *Program) Run(n int64, budget *Budget) (bool, error) {
+	if budget.err != nil {
+		return false, budget.err
+	}
+	stack := make([]value, 0, p.stack)
+	for pc := 0; pc < len(p.code); pc++ {
+		if !budget.spend(1) {
+			return false, budget.err
+		}
+		in := p.code[pc]
+		top := len(stack) - 1
+		switch in.op {
+		case opInt:
+			stack = append(stack, value{i: in.arg})
+		case opVar:
+			stack = append(stack, value{i: n})
+		case opList:
+			count := int(in.arg)
+			list := make([]int64, count)
+			for i := range list {
+				list[i] = stack[len(stack)-count+i].i
+			}
+			stack = append(stack[:len(stack)-count], value{list: list})
+		case opNeg:
+			stack[top].i = -stack[top].i
+		case opNot:
+			stack[top].i = 1 - stack[top].i
+		case opJumpFalse, opJumpTrue:
+			if (stack[top].i == 1) == (in.op == opJumpTrue) {
+				pc = int(in.arg) - 1
+			} else {
+				stack = stack[:top]
+			}
+		case opDigits:
+			stack[top] = value{list: digits(stack[top].i)}
+		case opSum:
+			if !budget.spend(int64(len(stack[top].list))) {
+				return false, budget.err
+			}
+			sum := int64(0)
+			for _, item := range stack[top].list {
+				sum += item
+			}
+			stack[top] = value{i: sum}
+		case opLen:
+			stack[top] = value{i: int64(len(stack[top].list))}
+		case opAbs:
+			if stack[top].i < 0 {
+				stack[top].i = -stack[top].i
+			}
+		case opSqrt:
+			if stack[top].i < 0 {
+				budget.err = errorf(in.pos, "Square root of negative number %d", stack[top].i)
+				return false, budget.err
+			}
+			stack[top].i = isqrt(stack[top].i)
+		case opIsPrime:
+			x := stack[top].i
+			if !budget.spend(isqrt(x) / 2) {
+				return false, budget.err
+			}
+			stack[top] = boolValue(isPrime(x))
+		default:
+			a, b := stack[top-1], stack[top]
+			stack = stack[:top]
+			result, err := binary(in, a, b, budget)
+			if err != nil {
+				return false, err
+			}
+			stack[top-1] = result
+		}
+	}
+	return stack[0].i == 1, nil
+}
+
+func binary(in instruction, a, b value, budget *Budget) (value, error) {
+	switch in.op {
+	case opAdd:
+		return value{i: a.i + b.i}, nil
+	case opSub:
+		return value{i: a.i - b.i}, nil
+	case opMul:
+		return value{i: a.i * b.i}, nil
+	case opDiv, opMod:
+		if b.i == 0 {
+			budget.err = errorf(in.pos, "Division by zero")
+			return value{}, budget.err
+		}
+		if in.op == opDiv {
+			return value{i: a.i / b.i}, nil
+		}
+		return value{i: a.i % b.i}, nil
+	case opEq:
+		return boolValue(a.i == b.i), nil
+	case opNe:
+		return boolValue(a.i != b.i), nil
+	case opLt:
+		return boolValue(a.i < b.i), nil
+	case opLe:
+		return boolValue(a.i <= b.i), nil
+	case opGt:
+		return boolValue(a.i > b.i), nil
+	case opGe:
+		return boolValue(a.i >= b.i), nil
+	case opContains:
+		if !budget.spend(int64(len(a.list))) {
+			return value{}, budget.err
+		}
+		for _, item := range a.list {
+			if item == b.i {
+				return boolValue(true), nil
+			}
+		}
+		return boolValue(false), nil
+	}
+	return value{}, errorf(in.pos, "Unknown operation")
+}
+
+// digits returns the decimal digits of x, most significant first
+func digits(x int64) []int64 {
+	u := uint64(x)
+	if x < 0 {
+		u = uint64(-x)
+	}
+	var buf [20]int64
+	i := len(buf)
+	for {
+		i--
+		buf[i] = int64(u % 10)
+		u /= 10
+		if u == 0 {
+			break
+		}
+	}
+	return append([]int64(nil), buf[i:]...)
+}
+
+// maxRoot is the square root of math.MaxInt64
+const maxRoot = 3037000499
+
+func isqrt(x int64) int64 {
+	if x <= 0 {
+		return 0
+	}
+	root := int64(math.Sqrt(float64(x)))
+	if root > maxRoot {
+		root = maxRoot
+	}
+	for root*root > x {
+		root--
+	}
+	for root < maxRoot && (root+1)*(root+1) <= x {
+		root++
+	}
+	return root
+}
+
+func isPrime(x int64) bool {
+	if x < 2 {
+		return false
+	}
+	if x%2 == 0 {
+		return x == 2
+	}
+	for d := int64(3); d <= x/d; d += 2 {
+		if x%d == 0 {
+			return false
+		}
+	}
+	return true
+}
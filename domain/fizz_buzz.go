@@ -1,21 +1,68 @@
 package domain
 
-import "encoding/json"
+import (
+	"FizzBuzz/domain/numfmt"
+	"encoding/json"
+	"fmt"
+)
 
+// FizzBuzzRequest words are templates, `{n}` or `{n:<formatter>}` is replaced by the number.
+// The other numbers are written with NumberFormat, see numfmt.ParseNumberFormat.
 type FizzBuzzRequest struct {
-	FstModulo int    `json:"fst_mod" binding:"required,gte=1"`
-	SndModulo int    `json:"snd_mod" binding:"required,gte=1"`
-	Limit     int    `json:"limit" binding:"required,gte=1"`
-	FstStr    string `json:"fst_str" binding:"required"`
-	SndStr    string `json:"snd_str" binding:"required"`
+	FstModulo    int    `json:"fst_mod" form:"fst_mod" binding:"required,gte=1"`
+	SndModulo    int    `json:"snd_mod" form:"snd_mod" binding:"required,gte=1"`
+	Limit        int    `json:"limit" form:"limit" binding:"required,gte=1"`
+	FstStr       string `json:"fst_str" form:"fst_str" binding:"required,template"`
+	SndStr       string `json:"snd_str" form:"snd_str" binding:"required,template"`
+	NumberFormat string `json:"number_format,omitempty" form:"number_format" binding:"omitempty,numberformat"`
 }
 
-func (fbr *FizzBuzzRequest) ToBytes() []byte {
+func (fbr *FizzBuzzRequest) ToBytes() ([]byte, error) {
 	data, err := json.Marshal(fbr)
 	if err != nil {
-		return []byte{}
+		return nil, fmt.Errorf("impossible to marshal fizzbuzz request: %w", err)
 	}
-	return data
+	return data, nil
+}
+
+// Normalize writes the number format in its canonical form once the request is
+// validated, so that equivalent requests are stored and served alike.
+func (fbr *FizzBuzzRequest) Normalize() {
+	fbr.NumberFormat = canonicalFormat(fbr.NumberFormat)
+}
+
+// canonicalFormat is the canonical form of a valid number format, an invalid
+// one is kept as is.
+func canonicalFormat(spec string) string {
+	if spec == "" {
+		return spec
+	}
+	format, err := numfmt.ParseNumberFormat(spec)
+	if err != nil {
+		return spec
+	}
+	return format.String()
+}
+
+// Identity of the requests without a number format is unchanged, equivalent
+// formats share the same identity.
+func (fbr *FizzBuzzRequest) Identity() ([]byte, error) {
+	encoder := NewIdentityEncoder("fizzbuzz").
+		Int("fst_mod", fbr.FstModulo).
+		Int("snd_mod", fbr.SndModulo).
+		Int("limit", fbr.Limit).
+		String("fst_str", fbr.FstStr).
+		String("snd_str", fbr.SndStr)
+	if fbr.NumberFormat != "" {
+		format, err := numfmt.ParseNumberFormat(fbr.NumberFormat)
+		if err != nil {
+			return nil, fmt.Errorf("impossible to identify fizzbuzz request: %w", err)
+		}
+		if canonical := format.String(); canonical != "" {
+			encoder = encoder.String("number_format", canonical)
+		}
+	}
+	return encoder.Bytes(), nil
 }
 
 func FromStrToRequestFB(payload string) *FizzBuzzRequest {
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Identities are hashed to key the counters, changing them splits every counter
+func TestFizzBuzzRequestIdentity(t *testing.T) {
+	request := FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 100, FstStr: "fizz", SndStr: "buzz;x=i:1"}
+	identity, err := request.Identity()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"fizzbuzz/v1;fizzbuzz;fst_mod=i:3;snd_mod=i:5;limit=i:100;fst_str=s4:fizz;snd_str=s10:buzz;x=i:1",
+		string(identity))
+}
+
+func TestFizzBuzzRequestIdentityNumberFormat(t *testing.T) {
+	request := FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 100, FstStr: "fizz", SndStr: "buzz"}
+	plain, err := request.Identity()
+	assert.NoError(t, err)
+
+	// The decimal format is the default one
+	request.NumberFormat = "base=10"
+	identity, err := request.Identity()
+	assert.NoError(t, err)
+	assert.Equal(t, string(plain), string(identity))
+
+	request.NumberFormat = "pad=3,base=2"
+	identity, err = request.Identity()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"fizzbuzz/v1;fizzbuzz;fst_mod=i:3;snd_mod=i:5;limit=i:100;fst_str=s4:fizz;snd_str=s4:buzz;number_format=s12:base=2,pad=3",
+		string(identity))
+
+	request.NumberFormat = "base=1"
+	_, err = request.Identity()
+	assert.Error(t, err)
+}
+
+func TestNormalizeNumberFormat(t *testing.T) {
+	request := FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 100, FstStr: "fizz", SndStr: "buzz", NumberFormat: "pad=3,base=2"}
+	request.Normalize()
+	assert.Equal(t, "base=2,pad=3", request.NumberFormat)
+	// Equivalent formats share the stored payload as well as the identity
+	request.NumberFormat = "base=10"
+	request.Normalize()
+	data, err := request.ToBytes()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "number_format")
+
+	term := TermRequest{FstModulo: 3, SndModulo: 5, FstStr: "fizz", SndStr: "buzz", N: []int{1}, NumberFormat: "digits=latin"}
+	term.Normalize()
+	assert.Equal(t, "", term.NumberFormat)
+}
+
+func TestIdentityUnambiguous(t *testing.T) {
+	a := FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 100, FstStr: "a;snd_str=s1:b", SndStr: "c"}
+	b := FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 100, FstStr: "a", SndStr: "b;snd_str=s1:c"}
+	identityA, err := a.Identity()
+	assert.NoError(t, err)
+	identityB, err := b.Identity()
+	assert.NoError(t, err)
+	assert.NotEqual(t, string(identityA), string(identityB))
+}
+
+func TestTermRequestIdentity(t *testing.T) {
+	request := TermRequest{FstModulo: 3, SndModulo: 5, FstStr: "fizz", SndStr: "buzz", N: []int{15, 7}}
+	identity, err := request.Identity()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"fizzbuzz/v1;term;fst_mod=i:3;snd_mod=i:5;fst_str=s4:fizz;snd_str=s4:buzz;n=i:15;n=i:7",
+		string(identity))
+
+	data, err := request.ToBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, &request, FromStrToRequest(string(data)))
+	fbr := FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: "fizz", SndStr: "buzz"}
+	data, err = fbr.ToBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, &fbr, FromStrToRequest(string(data)))
+}
+
+func TestCountedRequestJSON(t *testing.T) {
+	term := CountedRequest{Identifiable: &TermRequest{FstModulo: 3, SndModulo: 5, FstStr: "fizz", SndStr: "buzz", N: []int{15}}}
+	data, err := json.Marshal(term)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"kind":"term"`)
+	var decoded CountedRequest
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, term, decoded)
+
+	// Fizzbuzz requests are written as before
+	fbr := &FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: "fizz", SndStr: "buzz"}
+	data, err = json.Marshal(CountedRequest{Identifiable: fbr})
+	assert.NoError(t, err)
+	expected, err := json.Marshal(fbr)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(data))
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, fbr, decoded.FizzBuzz())
+}
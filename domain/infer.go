@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxInferLength is the maximum length of a sequence to infer the rules of
+	MaxInferLength = 10_000
+	// MaxInferCandidates is the maximum number of candidates of an inference report
+	MaxInferCandidates = 20
+)
+
+// InferRequest holds the terms of a sequence starting at 1, the numbers which
+// are not replaced are written in decimal.
+type InferRequest struct {
+	Sequence []string `json:"sequence" binding:"required,min=1,max=10000"`
+}
+
+// InferCandidate is a rule set producing the sequence. Unobserved rules never
+// match a number of the sequence: their modulo is only known to be greater than
+// its length and their word is unknown, they are given the length plus one and
+// an empty word.
+type InferCandidate struct {
+	FstModulo  int      `json:"fst_mod"`
+	SndModulo  int      `json:"snd_mod"`
+	FstStr     string   `json:"fst_str"`
+	SndStr     string   `json:"snd_str"`
+	Unobserved []string `json:"unobserved,omitempty"`
+}
+
+// InferReport lists the rule sets consistent with a sequence, the simplest first:
+// with the fewest unobserved rules, then the smallest modulos. Reasons explain
+// why the sequence is ambiguous or inconsistent.
+type InferReport struct {
+	Length     int              `json:"length"`
+	Consistent bool             `json:"consistent"`
+	Ambiguous  bool             `json:"ambiguous"`
+	Total      int              `json:"total"`
+	Candidates []InferCandidate `json:"candidates"`
+	Reasons    []string         `json:"reasons,omitempty"`
+}
+
+// InferRules finds the rule sets producing the sequence. Non plain terms are the
+// multiples of the modulos, so the first of them is one of the modulos and the
+// first one which is not its multiple is the other, when there is one.
+func InferRules(request InferRequest) *InferReport {
+	sequence := request.Sequence
+	length := len(sequence)
+	unobserved := length + 1
+	report := &InferReport{Length: length, Candidates: []InferCandidate{}}
+
+	var words []int
+	for i, term := range sequence {
+		if term != strconv.Itoa(i+1) {
+			words = append(words, i+1)
+		}
+	}
+	if len(words) == 0 {
+		report.Consistent, report.Ambiguous, report.Total = true, true, 1
+		report.Candidates = append(report.Candidates, InferCandidate{
+			FstModulo:  unobserved,
+			SndModulo:  unobserved,
+			Unobserved: []string{RuleFst, RuleSnd},
+		})
+		report.Reasons = append(report.Reasons, fmt.Sprintf(
+			"No term is a word, both modulos are greater than %d and the words are unknown", length))
+		return report
+	}
+
+	first := words[0]
+	others := []int{unobserved}
+	if second := firstNotMultiple(words, first); second > 0 {
+		others = []int{second}
+	} else {
+		others = append(others, first)
+		for m := 2 * first; m <= length; m += first {
+			// Both words are written at the other modulo, it can't be the word of first alone
+			if sequence[m-1] != sequence[first-1] {
+				others = append(others, m)
+			}
+		}
+	}
+
+	var firstErr error
+	for _, other := range others {
+		for _, mods := range [][2]int{{first, other}, {other, first}} {
+			candidate, err := checkRules(sequence, mods[0], mods[1])
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			report.Candidates = append(report.Candidates, *candidate)
+			if mods[0] == mods[1] {
+				break
+			}
+		}
+	}
+
+	if len(report.Candidates) == 0 {
+		report.Reasons = append(report.Reasons, "No two-rule set fits: "+firstErr.Error())
+		return report
+	}
+	sort.SliceStable(report.Candidates, func(i, j int) bool {
+		a, b := report.Candidates[i], report.Candidates[j]
+		if len(a.Unobserved) != len(b.Unobserved) {
+			return len(a.Unobserved) < len(b.Unobserved)
+		}
+		if a.FstModulo+a.SndModulo != b.FstModulo+b.SndModulo {
+			return a.FstModulo+a.SndModulo < b.FstModulo+b.SndModulo
+		}
+		return a.FstModulo < b.FstModulo
+	})
+	report.Consistent = true
+	report.Total = len(report.Candidates)
+	report.Ambiguous = report.Total > 1 || len(report.Candidates[0].Unobserved) > 0
+	if report.Total > 1 {
+		report.Reasons = append(report.Reasons, fmt.Sprintf(
+			"%d rule sets fit, a longer sequence is needed to tell them apart", report.Total))
+	}
+	for _, rule := range report.Candidates[0].Unobserved {
+		report.Reasons = append(report.Reasons, fmt.Sprintf(
+			"The %s rule matches no number up to %d, its modulo is greater and its word is unknown", rule, length))
+	}
+	if len(report.Candidates) > MaxInferCandidates {
+		report.Candidates = report.Candidates[:MaxInferCandidates]
+	}
+	return report
+}
+
+// firstNotMultiple is the first word position which is not a multiple of mod, 0 if none
+func firstNotMultiple(words []int, mod int) int {
+	for _, n := range words {
+		if n%mod != 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// checkRules returns the candidate with the modulos fst and snd, an error telling
+// the first term it doesn't produce otherwise.
+func checkRules(sequence []string, fst, snd int) (*InferCandidate, error) {
+	length := len(sequence)
+	candidate := &InferCandidate{FstModulo: fst, SndModulo: snd}
+	if fst > length {
+		candidate.Unobserved = append(candidate.Unobserved, RuleFst)
+	}
+	if snd > length {
+		candidate.Unobserved = append(candidate.Unobserved, RuleSnd)
+	}
+
+	// Words matched by a single rule are taken as they are
+	word := func(mod, other int) (string, error) {
+		found := ""
+		for n := mod; n <= length; n += mod {
+			if n%other == 0 {
+				continue
+			}
+			term := sequence[n-1]
+			if term == "" {
+				return "", fmt.Errorf("term %d is empty", n)
+			}
+			if found == "" {
+				found = term
+			} else if term != found {
+				return "", fmt.Errorf("term %d %q should be %q like the other multiples of %d", n, term, found, mod)
+			}
+		}
+		return found, nil
+	}
+	var err error
+	if candidate.FstStr, err = word(fst, snd); err != nil {
+		return nil, err
+	}
+	if candidate.SndStr, err = word(snd, fst); err != nil {
+		return nil, err
+	}
+
+	for n := 1; n <= length; n++ {
+		term := sequence[n-1]
+		switch {
+		case n%fst == 0 && n%snd == 0:
+			// The word of a rule only matched along the other one is what remains
+			if candidate.FstStr == "" && candidate.SndStr != "" && strings.HasSuffix(term, candidate.SndStr) {
+				candidate.FstStr = strings.TrimSuffix(term, candidate.SndStr)
+			} else if candidate.SndStr == "" && candidate.FstStr != "" && strings.HasPrefix(term, candidate.FstStr) {
+				candidate.SndStr = strings.TrimPrefix(term, candidate.FstStr)
+			}
+			if candidate.FstStr == "" || candidate.SndStr == "" {
+				return nil, fmt.Errorf("term %d %q can't be split between the words of %d and %d", n, term, fst, snd)
+			}
+			if expected := candidate.FstStr + candidate.SndStr; term != expected {
+				return nil, fmt.Errorf("term %d %q should be %q", n, term, expected)
+			}
+		case n%fst == 0 || n%snd == 0:
+			if term == strconv.Itoa(n) {
+				return nil, fmt.Errorf("term %d should be a word", n)
+			}
+		default:
+			if term != strconv.Itoa(n) {
+				return nil, fmt.Errorf("term %d %q should be %d", n, term, n)
+			}
+		}
+	}
+	return candidate, nil
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sequence(limit, fstMod, sndMod int, fstStr, sndStr string) []string {
+	res := make([]string, limit)
+	for n := 1; n <= limit; n++ {
+		switch {
+		case n%fstMod == 0 && n%sndMod == 0:
+			res[n-1] = fstStr + sndStr
+		case n%fstMod == 0:
+			res[n-1] = fstStr
+		case n%sndMod == 0:
+			res[n-1] = sndStr
+		default:
+			res[n-1] = strconv.Itoa(n)
+		}
+	}
+	return res
+}
+
+func TestInferRules(t *testing.T) {
+	report := InferRules(InferRequest{Sequence: sequence(15, 3, 5, "fizz", "buzz")})
+	assert.Equal(t, &InferReport{
+		Length:     15,
+		Consistent: true,
+		Total:      1,
+		Candidates: []InferCandidate{{FstModulo: 3, SndModulo: 5, FstStr: "fizz", SndStr: "buzz"}},
+	}, report)
+
+	// Modulos sharing factors
+	report = InferRules(InferRequest{Sequence: sequence(40, 4, 6, "a", "b")})
+	assert.Equal(t, []InferCandidate{{FstModulo: 4, SndModulo: 6, FstStr: "a", SndStr: "b"}}, report.Candidates)
+
+	// The second modulo is a multiple of the first
+	report = InferRules(InferRequest{Sequence: sequence(12, 2, 4, "a", "b")})
+	assert.Equal(t, []InferCandidate{{FstModulo: 2, SndModulo: 4, FstStr: "a", SndStr: "b"}}, report.Candidates)
+}
+
+func TestInferRulesAmbiguous(t *testing.T) {
+	// Without 15 the order of the words is unknown
+	report := InferRules(InferRequest{Sequence: sequence(10, 3, 5, "fizz", "buzz")})
+	assert.True(t, report.Consistent)
+	assert.True(t, report.Ambiguous)
+	assert.Equal(t, []InferCandidate{
+		{FstModulo: 3, SndModulo: 5, FstStr: "fizz", SndStr: "buzz"},
+		{FstModulo: 5, SndModulo: 3, FstStr: "buzz", SndStr: "fizz"},
+	}, report.Candidates)
+
+	report = InferRules(InferRequest{Sequence: []string{"1", "2", "fizz", "4"}})
+	assert.True(t, report.Ambiguous)
+	assert.Equal(t, InferCandidate{FstModulo: 3, SndModulo: 5, FstStr: "fizz", Unobserved: []string{RuleSnd}}, report.Candidates[0])
+	assert.Len(t, report.Reasons, 2)
+
+	report = InferRules(InferRequest{Sequence: []string{"1", "2"}})
+	assert.True(t, report.Ambiguous)
+	assert.Equal(t, []string{RuleFst, RuleSnd}, report.Candidates[0].Unobserved)
+}
+
+func TestInferRulesInconsistent(t *testing.T) {
+	report := InferRules(InferRequest{Sequence: []string{"1", "fizz", "fizz", "buzz"}})
+	assert.False(t, report.Consistent)
+	assert.Empty(t, report.Candidates)
+	assert.Equal(t, []string{`No two-rule set fits: term 4 "buzz" should be "fizz" like the other multiples of 2`}, report.Reasons)
+
+	report = InferRules(InferRequest{Sequence: []string{"1", "fizz", "3", "fizz", "5", "fizz", "7", "8"}})
+	assert.False(t, report.Consistent)
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is an asynchronous fizzbuzz computation, its output is written on disk
+type Job struct {
+	ID      string          `json:"id"`
+	Status  JobStatus       `json:"status"`
+	Request FizzBuzzRequest `json:"request"`
+	// Explain writes the result as explained terms, one JSON object per line
+	Explain   bool      `json:"explain,omitempty"`
+	Progress  int       `json:"progress"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished tells if the job won't change anymore
+func (j *Job) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// Percent is the progress of the job between 0 and 100
+func (j *Job) Percent() float64 {
+	if j.Request.Limit <= 0 {
+		return 0
+	}
+	return float64(j.Progress) * 100 / float64(j.Request.Limit)
+}
@@ -1,5 +1,44 @@
 package domain
 
+import "time"
+
+// Ranking orders the counters
+type Ranking string
+
+const (
+	RankByHits    Ranking = "hits"
+	RankByClients Ranking = "clients"
+	// Costs rankings sum the output items, the time spent and the response bytes
+	RankByItems Ranking = "items"
+	RankByTime  Ranking = "time"
+	RankByBytes Ranking = "bytes"
+)
+
+var Rankings = []Ranking{RankByHits, RankByClients, RankByItems, RankByTime, RankByBytes}
+
+func (r Ranking) Valid() bool {
+	for _, ranking := range Rankings {
+		if r == ranking {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestCost is what serving a request once took
+type RequestCost struct {
+	Items    int
+	Duration time.Duration
+	Bytes    int
+}
+
+// MetricCost sums the costs of every time a request was served
+type MetricCost struct {
+	Items       int     `json:"items"`
+	TimeSeconds float64 `json:"time_seconds"`
+	Bytes       int     `json:"bytes"`
+}
+
 type MetricCounterScore struct {
 	Key          string
 	ScoreCounter int
@@ -24,7 +63,35 @@ func (mcs MetricCountersScores) Counters() []int {
 }
 
 type MetricCountFizzBuzz struct {
-	Key     string          `json:"-"`
-	Score   int             `json:"counter"`
-	Request FizzBuzzRequest `json:"request"`
+	Key           string         `json:"-"`
+	Score         int            `json:"counter"`
+	UniqueClients int            `json:"unique_clients"`
+	Cost          MetricCost     `json:"cost"`
+	Request       CountedRequest `json:"request"`
+}
+
+// CounterChange is published each time a counter is incremented
+type CounterChange struct {
+	Key   string `json:"key"`
+	Score int    `json:"score"`
+}
+
+// LeaderboardEvent holds the top requests when their ranking changed
+type LeaderboardEvent struct {
+	ID      string                `json:"-"`
+	Ranking []MetricCountFizzBuzz `json:"ranking"`
+}
+
+// MetricLookup is the counter of a given request and its position among all
+// the requests for a ranking. Rank 1 is the first one, the percentile is the
+// share of requests ranked at or below it.
+type MetricLookup struct {
+	Key           string          `json:"key"`
+	Score         int             `json:"counter"`
+	UniqueClients int             `json:"unique_clients"`
+	Cost          MetricCost      `json:"cost"`
+	Rank          int             `json:"rank"`
+	Percentile    float64         `json:"percentile"`
+	Total         int             `json:"total"`
+	Request       FizzBuzzRequest `json:"request"`
 }
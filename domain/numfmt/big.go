@@ -0,0 +1,56 @@
+package numfmt
+
+import (
+	"math/big"
+)
+
+// BigFormatter appends the rendering of n to buf, for numbers beyond int64
+type BigFormatter func(buf []byte, n *big.Int) []byte
+
+// BigFormatters render the same placeholders as Formatters
+var BigFormatters = map[string]BigFormatter{
+	"dec":     appendBigBase(10),
+	"hex":     appendBigBase(16),
+	"oct":     appendBigBase(8),
+	"bin":     appendBigBase(2),
+	"roman":   appendBigRoman,
+	"ordinal": appendBigOrdinal,
+}
+
+func appendBigBase(base int) BigFormatter {
+	return func(buf []byte, n *big.Int) []byte {
+		return n.Append(buf, base)
+	}
+}
+
+func appendBigRoman(buf []byte, n *big.Int) []byte {
+	if n.IsInt64() {
+		return AppendRoman(buf, n.Int64())
+	}
+	return n.Append(buf, 10)
+}
+
+func appendBigOrdinal(buf []byte, n *big.Int) []byte {
+	start := len(buf)
+	buf = n.Append(buf, 10)
+	// The suffix only depends on the last two decimal digits
+	var lastTwo int64
+	for i, weight := len(buf)-1, int64(1); i >= start && weight <= 10 && buf[i] != '-'; i, weight = i-1, weight*10 {
+		lastTwo += int64(buf[i]-'0') * weight
+	}
+	return appendOrdinalSuffix(buf, lastTwo)
+}
+
+// AppendBigTo writes the word of n to buf
+func (t *Template) AppendBigTo(buf []byte, n *big.Int) []byte {
+	if t.static {
+		return append(buf, t.literal...)
+	}
+	for _, p := range t.parts {
+		buf = append(buf, p.literal...)
+		if p.bigFormat != nil {
+			buf = p.bigFormat(buf, n)
+		}
+	}
+	return buf
+}
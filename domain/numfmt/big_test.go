@@ -0,0 +1,32 @@
+package numfmt
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendBigTo(t *testing.T) {
+	huge, _ := new(big.Int).SetString("1000000000000000000000000000012", 10)
+	tests := []struct {
+		src      string
+		n        *big.Int
+		expected string
+	}{
+		{src: "fizz", n: huge, expected: "fizz"},
+		{src: "fizz({n})", n: huge, expected: "fizz(1000000000000000000000000000012)"},
+		{src: "{n:ordinal}", n: huge, expected: "1000000000000000000000000000012th"},
+		{src: "{n:ordinal}", n: big.NewInt(-22), expected: "-22nd"},
+		{src: "{n:hex}", n: huge, expected: huge.Text(16)},
+		{src: "{n:roman}", n: big.NewInt(14), expected: "XIV"},
+		{src: "{n:roman}", n: huge, expected: huge.String()},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			template, err := Compile(test.src)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(template.AppendBigTo(nil, test.n)))
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package numfmt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Speller writes a number in the words of a language
+type Speller func(buf []byte, n int64) []byte
+
+var (
+	spellersMu sync.RWMutex
+	spellers   = map[string]Speller{"en": AppendEnglish}
+)
+
+// RegisterSpeller adds the number words of a locale, usable as `words=<locale>`
+func RegisterSpeller(locale string, speller Speller) {
+	spellersMu.Lock()
+	defer spellersMu.Unlock()
+	spellers[locale] = speller
+}
+
+func speller(locale string) (Speller, bool) {
+	spellersMu.RLock()
+	defer spellersMu.RUnlock()
+	s, ok := spellers[locale]
+	return s, ok
+}
+
+// DigitSystems are the digits of the locales, from zero to nine
+var DigitSystems = map[string][10]rune{
+	"latin":                 {'0', '1', '2', '3', '4', '5', '6', '7', '8', '9'},
+	"arabic-indic":          {'٠', '١', '٢', '٣', '٤', '٥', '٦', '٧', '٨', '٩'},
+	"extended-arabic-indic": {'۰', '۱', '۲', '۳', '۴', '۵', '۶', '۷', '۸', '۹'},
+	"devanagari":            {'०', '१', '२', '३', '४', '५', '६', '७', '८', '९'},
+	"bengali":               {'০', '১', '২', '৩', '৪', '৫', '৬', '৭', '৮', '৯'},
+	"thai":                  {'๐', '๑', '๒', '๓', '๔', '๕', '๖', '๗', '๘', '๙'},
+	"fullwidth":             {'０', '１', '２', '３', '４', '５', '６', '７', '８', '９'},
+}
+
+// MaxPad is the maximum width numbers are padded to
+const MaxPad = 64
+
+// NumberFormat renders the numbers which are not replaced by a word. It is
+// written as comma separated options:
+//   - `base=<2..36>` writes the number in another base
+//   - `pad=<width>` pads the digits with zeros to the width
+//   - `roman` writes roman numerals, from 1 to 3999
+//   - `words=<locale>` writes the number in words, `en` is built in and the default
+//   - `digits=<system>` uses the digits of a locale such as `devanagari`
+type NumberFormat struct {
+	Base   int
+	Pad    int
+	Roman  bool
+	Words  string
+	Digits string
+
+	speller Speller
+	digits  *[10]rune
+}
+
+// ParseNumberFormat parses the options of a format, an empty one is decimal
+func ParseNumberFormat(spec string) (*NumberFormat, error) {
+	f := &NumberFormat{Base: 10}
+	if spec == "" {
+		return f, nil
+	}
+	seen := make(map[string]int)
+	pos := 1
+	for _, option := range strings.Split(spec, ",") {
+		name, value, hasValue := strings.Cut(option, "=")
+		if _, ok := seen[name]; ok {
+			return nil, &Error{Pos: pos, Message: fmt.Sprintf("Option %q is given twice", name)}
+		}
+		seen[name] = pos
+		valuePos := pos + len(name) + 1
+		switch name {
+		case "base", "pad":
+			number, err := strconv.Atoi(value)
+			switch {
+			case err != nil:
+				return nil, &Error{Pos: valuePos, Message: fmt.Sprintf("Option %q should be an integer", name)}
+			case name == "base" && (number < 2 || number > 36):
+				return nil, &Error{Pos: valuePos, Message: "Base should be between 2 and 36"}
+			case name == "pad" && (number < 1 || number > MaxPad):
+				return nil, &Error{Pos: valuePos, Message: fmt.Sprintf("Pad should be between 1 and %d", MaxPad)}
+			case name == "base":
+				f.Base = number
+			default:
+				f.Pad = number
+			}
+		case "roman":
+			if hasValue {
+				return nil, &Error{Pos: valuePos, Message: "Option \"roman\" takes no value"}
+			}
+			f.Roman = true
+		case "words":
+			if !hasValue {
+				value = "en"
+			}
+			s, ok := speller(value)
+			if !ok {
+				return nil, &Error{Pos: valuePos, Message: fmt.Sprintf("Unknown words locale %q", value)}
+			}
+			f.Words, f.speller = value, s
+		case "digits":
+			digits, ok := DigitSystems[value]
+			if !ok {
+				return nil, &Error{Pos: valuePos, Message: fmt.Sprintf("Unknown digit system %q", value)}
+			}
+			f.Digits, f.digits = value, &digits
+		default:
+			return nil, &Error{Pos: pos, Message: fmt.Sprintf("Unknown option %q", name)}
+		}
+		pos += len(option) + 1
+	}
+
+	_, hasBase := seen["base"]
+	switch {
+	case f.Roman && f.Words != "":
+		return nil, &Error{Pos: seen["words"], Message: "Roman and words can't be combined"}
+	case (f.Roman || f.Words != "") && (hasBase || f.Pad > 0 || f.Digits != ""):
+		return nil, &Error{Pos: 1, Message: "Roman and words can't be combined with base, pad or digits"}
+	case f.Digits != "" && f.Base > 10:
+		return nil, &Error{Pos: seen["digits"], Message: "Digits need a base of 10 or less"}
+	}
+	return f, nil
+}
+
+// Default tells if the format writes numbers like strconv.Itoa
+func (f *NumberFormat) Default() bool {
+	return f.Base == 10 && f.Pad == 0 && !f.Roman && f.Words == "" && (f.digits == nil || f.Digits == "latin")
+}
+
+// String is the canonical form of the format, the same for equivalent specs
+func (f *NumberFormat) String() string {
+	var options []string
+	if f.Base != 10 {
+		options = append(options, "base="+strconv.Itoa(f.Base))
+	}
+	if f.Pad > 0 {
+		options = append(options, "pad="+strconv.Itoa(f.Pad))
+	}
+	if f.Roman {
+		options = append(options, "roman")
+	}
+	if f.Words != "" {
+		options = append(options, "words="+f.Words)
+	}
+	if f.Digits != "" && f.Digits != "latin" {
+		options = append(options, "digits="+f.Digits)
+	}
+	sort.Strings(options)
+	return strings.Join(options, ",")
+}
+
+// AppendTo writes n to buf
+func (f *NumberFormat) AppendTo(buf []byte, n int64) []byte {
+	switch {
+	case f.Roman:
+		return AppendRoman(buf, n)
+	case f.speller != nil:
+		return f.speller(buf, n)
+	}
+
+	start := len(buf)
+	buf = strconv.AppendInt(buf, n, f.Base)
+	if f.Pad > 0 {
+		digitsStart := start
+		if n < 0 {
+			digitsStart++
+		}
+		if missing := f.Pad - (len(buf) - digitsStart); missing > 0 {
+			for i := 0; i < missing; i++ {
+				buf = append(buf, 0)
+			}
+			copy(buf[digitsStart+missing:], buf[digitsStart:len(buf)-missing])
+			for i := 0; i < missing; i++ {
+				buf[digitsStart+i] = '0'
+			}
+		}
+	}
+	if f.digits == nil || f.Digits == "latin" {
+		return buf
+	}
+
+	// Digits of other systems are longer, the ascii ones are copied apart so that
+	// rewriting them in place can't overwrite the ones not read yet
+	var scratch [72]byte
+	ascii := append(scratch[:0], buf[start:]...)
+	out := buf[:start]
+	for _, c := range ascii {
+		if c >= '0' && c <= '9' {
+			out = utf8.AppendRune(out, f.digits[c-'0'])
+		} else {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+var (
+	englishUnits = [...]string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	englishTens   = [...]string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	englishScales = [...]string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"}
+)
+
+// AppendEnglish writes n in english words, as in "one hundred twenty-three"
+func AppendEnglish(buf []byte, n int64) []byte {
+	if n == 0 {
+		return append(buf, englishUnits[0]...)
+	}
+	magnitude := uint64(n)
+	if n < 0 {
+		buf = append(buf, "minus "...)
+		magnitude = -magnitude
+	}
+
+	var groups [len(englishScales)]int
+	count := 0
+	for ; magnitude > 0; magnitude /= 1000 {
+		groups[count] = int(magnitude % 1000)
+		count++
+	}
+	first := true
+	for scale := count - 1; scale >= 0; scale-- {
+		group := groups[scale]
+		if group == 0 {
+			continue
+		}
+		if !first {
+			buf = append(buf, ' ')
+		}
+		first = false
+		buf = appendEnglishGroup(buf, group)
+		if scale > 0 {
+			buf = append(buf, ' ')
+			buf = append(buf, englishScales[scale]...)
+		}
+	}
+	return buf
+}
+
+// appendEnglishGroup writes a number from 1 to 999
+func appendEnglishGroup(buf []byte, n int) []byte {
+	if hundreds := n / 100; hundreds > 0 {
+		buf = append(buf, englishUnits[hundreds]...)
+		buf = append(buf, " hundred"...)
+		if n%100 == 0 {
+			return buf
+		}
+		buf = append(buf, ' ')
+	}
+	switch rest := n % 100; {
+	case rest < 20:
+		buf = append(buf, englishUnits[rest]...)
+	default:
+		buf = append(buf, englishTens[rest/10]...)
+		if rest%10 > 0 {
+			buf = append(buf, '-')
+			buf = append(buf, englishUnits[rest%10]...)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,73 @@
+package numfmt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberFormat(t *testing.T) {
+	tests := []struct {
+		spec      string
+		n         int64
+		expected  string
+		canonical string
+	}{
+		{spec: "", n: 42, expected: "42", canonical: ""},
+		{spec: "base=10", n: 42, expected: "42", canonical: ""},
+		{spec: "base=2", n: 5, expected: "101", canonical: "base=2"},
+		{spec: "base=36", n: 35, expected: "z", canonical: "base=36"},
+		{spec: "pad=4", n: 42, expected: "0042", canonical: "pad=4"},
+		{spec: "pad=4", n: -42, expected: "-0042", canonical: "pad=4"},
+		{spec: "pad=2", n: 1234, expected: "1234", canonical: "pad=2"},
+		{spec: "pad=8,base=2", n: 5, expected: "00000101", canonical: "base=2,pad=8"},
+		{spec: "roman", n: 14, expected: "XIV", canonical: "roman"},
+		{spec: "words", n: 0, expected: "zero", canonical: "words=en"},
+		{spec: "words=en", n: 115, expected: "one hundred fifteen", canonical: "words=en"},
+		{spec: "words=en", n: -21, expected: "minus twenty-one", canonical: "words=en"},
+		{spec: "words=en", n: 1_000_017, expected: "one million seventeen", canonical: "words=en"},
+		{spec: "digits=devanagari", n: 2024, expected: "२०२४", canonical: "digits=devanagari"},
+		{spec: "digits=arabic-indic,pad=3", n: 7, expected: "٠٠٧", canonical: "digits=arabic-indic,pad=3"},
+		{spec: "digits=latin", n: 7, expected: "7", canonical: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			format, err := ParseNumberFormat(test.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(format.AppendTo(nil, test.n)))
+			// Appended in place after other bytes, with room to spare
+			buf := append(make([]byte, 0, 64), "n="...)
+			assert.Equal(t, "n="+test.expected, string(format.AppendTo(buf, test.n)))
+			assert.Equal(t, test.canonical, format.String())
+		})
+	}
+}
+
+func TestParseNumberFormatErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  *Error
+	}{
+		{spec: "base=1", err: &Error{Pos: 6, Message: "Base should be between 2 and 36"}},
+		{spec: "pad=x", err: &Error{Pos: 5, Message: "Option \"pad\" should be an integer"}},
+		{spec: "pad=2,pad=3", err: &Error{Pos: 7, Message: "Option \"pad\" is given twice"}},
+		{spec: "roman,words", err: &Error{Pos: 7, Message: "Roman and words can't be combined"}},
+		{spec: "roman,base=2", err: &Error{Pos: 1, Message: "Roman and words can't be combined with base, pad or digits"}},
+		{spec: "words=fr", err: &Error{Pos: 7, Message: "Unknown words locale \"fr\""}},
+		{spec: "base=16,digits=thai", err: &Error{Pos: 9, Message: "Digits need a base of 10 or less"}},
+		{spec: "upper", err: &Error{Pos: 1, Message: "Unknown option \"upper\""}},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			_, err := ParseNumberFormat(test.spec)
+			assert.Equal(t, test.err, err)
+		})
+	}
+}
+
+func TestRegisterSpeller(t *testing.T) {
+	RegisterSpeller("test", func(buf []byte, n int64) []byte { return append(buf, "n"...) })
+	format, err := ParseNumberFormat("words=test")
+	assert.NoError(t, err)
+	assert.Equal(t, "n", string(format.AppendTo(nil, 3)))
+}
@@ -0,0 +1,212 @@
+// Package numfmt renders the numbers of the sequence in the words of the
+// requests, like `fizz({n})` or `{n:roman}`.
+package numfmt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Formatter appends the rendering of n to buf
+type Formatter func(buf []byte, n int64) []byte
+
+// Formatters are the names usable after the colon of a placeholder
+var Formatters = map[string]Formatter{
+	"dec":     appendBase(10),
+	"hex":     appendBase(16),
+	"oct":     appendBase(8),
+	"bin":     appendBase(2),
+	"roman":   AppendRoman,
+	"ordinal": AppendOrdinal,
+}
+
+// Names are the sorted names of the formatters
+func Names() []string {
+	names := make([]string, 0, len(Formatters))
+	for name := range Formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func appendBase(base int) Formatter {
+	return func(buf []byte, n int64) []byte {
+		return strconv.AppendInt(buf, n, base)
+	}
+}
+
+var romanNumerals = []struct {
+	value  int64
+	symbol string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+	{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+// AppendRoman writes n in roman numerals, numbers out of 1 to 3999 have none and
+// are written in decimal.
+func AppendRoman(buf []byte, n int64) []byte {
+	if n < 1 || n > 3999 {
+		return strconv.AppendInt(buf, n, 10)
+	}
+	for _, numeral := range romanNumerals {
+		for n >= numeral.value {
+			buf = append(buf, numeral.symbol...)
+			n -= numeral.value
+		}
+	}
+	return buf
+}
+
+// AppendOrdinal writes n with its english suffix: 1st, 2nd, 3rd, 11th...
+func AppendOrdinal(buf []byte, n int64) []byte {
+	buf = strconv.AppendInt(buf, n, 10)
+	lastTwo := n % 100
+	if lastTwo < 0 {
+		lastTwo = -lastTwo
+	}
+	return appendOrdinalSuffix(buf, lastTwo)
+}
+
+// appendOrdinalSuffix writes the suffix of a number ending by lastTwo
+func appendOrdinalSuffix(buf []byte, lastTwo int64) []byte {
+	switch {
+	case lastTwo >= 11 && lastTwo <= 13:
+		return append(buf, "th"...)
+	case lastTwo%10 == 1:
+		return append(buf, "st"...)
+	case lastTwo%10 == 2:
+		return append(buf, "nd"...)
+	case lastTwo%10 == 3:
+		return append(buf, "rd"...)
+	}
+	return append(buf, "th"...)
+}
+
+// Error is an invalid template or number format, Pos is the column of the error starting at 1
+type Error struct {
+	Pos     int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Message, e.Pos)
+}
+
+type part struct {
+	literal   string
+	format    Formatter
+	bigFormat BigFormatter
+}
+
+// Template is a compiled word, `{n}` or `{n:<formatter>}` is replaced by the
+// number and braces are written `{{` and `}}`.
+type Template struct {
+	parts []part
+	// literal is the whole word when it has no placeholder
+	literal string
+	static  bool
+}
+
+// Literal is a template writing s as is
+func Literal(s string) *Template {
+	return &Template{static: true, literal: s}
+}
+
+// Compile parses the template, it can then be rendered concurrently
+func Compile(src string) (*Template, error) {
+	t := &Template{}
+	literal := make([]byte, 0, len(src))
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '}':
+			if i+1 < len(src) && src[i+1] == '}' {
+				literal = append(literal, '}')
+				i++
+				continue
+			}
+			return nil, &Error{Pos: i + 1, Message: "Unexpected '}', write '}}' for a brace"}
+		case '{':
+			if i+1 < len(src) && src[i+1] == '{' {
+				literal = append(literal, '{')
+				i++
+				continue
+			}
+			end := i + 1
+			for end < len(src) && src[end] != '}' {
+				end++
+			}
+			if end == len(src) {
+				return nil, &Error{Pos: i + 1, Message: "Unclosed '{', write '{{' for a brace"}
+			}
+			name, err := placeholder(src[i+1:end], i+2)
+			if err != nil {
+				return nil, err
+			}
+			t.parts = append(t.parts, part{
+				literal:   string(literal),
+				format:    Formatters[name],
+				bigFormat: BigFormatters[name],
+			})
+			literal = literal[:0]
+			i = end
+		default:
+			literal = append(literal, src[i])
+		}
+	}
+	if len(t.parts) == 0 {
+		t.static = true
+		t.literal = string(literal)
+		return t, nil
+	}
+	t.parts = append(t.parts, part{literal: string(literal)})
+	return t, nil
+}
+
+// placeholder returns the formatter name of `n` or `n:<name>`, pos is its column
+func placeholder(content string, pos int) (string, error) {
+	name := "dec"
+	variable := content
+	for i := 0; i < len(content); i++ {
+		if content[i] == ':' {
+			variable, name = content[:i], content[i+1:]
+			break
+		}
+	}
+	if variable != "n" {
+		return "", &Error{Pos: pos, Message: fmt.Sprintf("Unknown placeholder %q, only n is available, write '{{' for a brace", variable)}
+	}
+	if _, ok := Formatters[name]; !ok {
+		return "", &Error{Pos: pos + 2, Message: fmt.Sprintf("Unknown formatter %q", name)}
+	}
+	return name, nil
+}
+
+// Static tells if the word doesn't depend on the number
+func (t *Template) Static() bool {
+	return t.static
+}
+
+// AppendTo writes the word of n to buf
+func (t *Template) AppendTo(buf []byte, n int64) []byte {
+	if t.static {
+		return append(buf, t.literal...)
+	}
+	for _, p := range t.parts {
+		buf = append(buf, p.literal...)
+		if p.format != nil {
+			buf = p.format(buf, n)
+		}
+	}
+	return buf
+}
+
+// Render returns the word of n
+func (t *Template) Render(n int64) string {
+	if t.static {
+		return t.literal
+	}
+	return string(t.AppendTo(make([]byte, 0, 32), n))
+}
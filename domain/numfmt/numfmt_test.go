@@ -0,0 +1,61 @@
+package numfmt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		src      string
+		n        int64
+		expected string
+	}{
+		{src: "fizz", n: 3, expected: "fizz"},
+		{src: "fizz({n})", n: 3, expected: "fizz(3)"},
+		{src: "{n:hex}-buzz", n: 255, expected: "ff-buzz"},
+		{src: "{n:roman}", n: 1994, expected: "MCMXCIV"},
+		{src: "{n:roman}", n: 4000, expected: "4000"},
+		{src: "{n:bin}/{n:oct}/{n:dec}", n: 9, expected: "1001/11/9"},
+		{src: "{n:ordinal}", n: 1, expected: "1st"},
+		{src: "{n:ordinal} {n:ordinal} {n:ordinal}", n: 112, expected: "112th 112th 112th"},
+		{src: "{n:ordinal}", n: 23, expected: "23rd"},
+		{src: "{{n}} {{{n}}}", n: 7, expected: "{n} {7}"},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			template, err := Compile(test.src)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, template.Render(test.n))
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err *Error
+	}{
+		{src: "fizz{", err: &Error{Pos: 5, Message: "Unclosed '{', write '{{' for a brace"}},
+		{src: "fizz}", err: &Error{Pos: 5, Message: "Unexpected '}', write '}}' for a brace"}},
+		{src: "a{x}", err: &Error{Pos: 3, Message: "Unknown placeholder \"x\", only n is available, write '{{' for a brace"}},
+		{src: "a{n:words}", err: &Error{Pos: 5, Message: "Unknown formatter \"words\""}},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			_, err := Compile(test.src)
+			assert.Equal(t, test.err, err)
+		})
+	}
+}
+
+func TestAppendToAllocations(t *testing.T) {
+	template, err := Compile("fizz({n:roman})")
+	assert.NoError(t, err)
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = template.AppendTo(buf[:0], 1994)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
@@ -0,0 +1,51 @@
+package domain
+
+// GCounter is a grow-only counter replicated between instances, each replica
+// only increments its own component. Merging keeps the highest value of every
+// component, so merges can be repeated and applied in any order.
+type GCounter map[string]int
+
+// Merge returns the counter holding the highest value of each component
+func (g GCounter) Merge(other GCounter) GCounter {
+	merged := make(GCounter, len(g)+len(other))
+	for replica, count := range g {
+		merged[replica] = count
+	}
+	for replica, count := range other {
+		if count > merged[replica] {
+			merged[replica] = count
+		}
+	}
+	return merged
+}
+
+// Value is the sum of every component
+func (g GCounter) Value() int {
+	value := 0
+	for _, count := range g {
+		value += count
+	}
+	return value
+}
+
+// ReplicaCounter is the replicated counter of a request
+type ReplicaCounter struct {
+	Key        string   `json:"key"`
+	Data       string   `json:"data"`
+	Components GCounter `json:"components"`
+}
+
+// ReplicaSnapshot holds every counter known by a replica, its own component
+// and the ones it merged from its peers.
+type ReplicaSnapshot struct {
+	Replica  string           `json:"replica"`
+	Counters []ReplicaCounter `json:"counters"`
+}
+
+// GlobalCounter is a counter summed over every replica
+type GlobalCounter struct {
+	Key        string         `json:"key"`
+	Score      int            `json:"counter"`
+	Components GCounter       `json:"components"`
+	Request    CountedRequest `json:"request"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCounterMerge(t *testing.T) {
+	a := GCounter{"eu": 5, "us": 2}
+	b := GCounter{"us": 4, "asia": 1}
+	c := GCounter{"eu": 3, "asia": 2}
+
+	ab := a.Merge(b)
+	assert.Equal(t, GCounter{"eu": 5, "us": 4, "asia": 1}, ab)
+	// Commutative, associative and idempotent
+	assert.Equal(t, ab, b.Merge(a))
+	assert.Equal(t, ab.Merge(c), a.Merge(b.Merge(c)))
+	assert.Equal(t, ab, ab.Merge(a).Merge(b))
+	assert.Equal(t, 11, ab.Merge(c).Value())
+	// Merging doesn't change its operands
+	assert.Equal(t, GCounter{"eu": 5, "us": 2}, a)
+}
@@ -0,0 +1,429 @@
+package domain
+
+import (
+	"FizzBuzz/domain/expr"
+	"FizzBuzz/domain/numfmt"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rule tells if a number of the sequence is replaced by the word of the rule
+type Rule interface {
+	Match(nb int) bool
+	// Cost is the number of steps taken by Match for nb, charged to the budget of
+	// the expressions. Expressions charge their own steps.
+	Cost(nb int) int64
+	// Validate reports every invalid field of the rule, nested rules included
+	Validate() RuleErrors
+}
+
+const (
+	// MaxRules is the maximum number of rules, nested ones included
+	MaxRules = 32
+	// MaxRuleDepth is the maximum nesting of combined rules
+	MaxRuleDepth = 4
+)
+
+// RuleError is an invalid field of a rule, Field is its path from the request
+// such as `rules[1].rules[0].digit`. Position is the column of the error in an
+// expression.
+type RuleError struct {
+	Field    string
+	Message  string
+	Position int
+}
+
+type RuleErrors []RuleError
+
+func (re RuleErrors) Error() string {
+	msgs := make([]string, len(re))
+	for i, e := range re {
+		msgs[i] = e.Field + ": " + e.Message
+	}
+	return "invalid rules: " + strings.Join(msgs, ", ")
+}
+
+// prefix moves the errors of a nested rule under path
+func (re RuleErrors) prefix(path string) RuleErrors {
+	for i := range re {
+		if re[i].Field == "" {
+			re[i].Field = path
+		} else {
+			re[i].Field = path + "." + re[i].Field
+		}
+	}
+	return re
+}
+
+type DivisibleRule struct{ Mod int }
+
+func (r DivisibleRule) Match(nb int) bool { return nb%r.Mod == 0 }
+
+func (DivisibleRule) Cost(int) int64 { return 1 }
+
+func (r DivisibleRule) Validate() RuleErrors {
+	if r.Mod < 1 {
+		return RuleErrors{{Field: "mod", Message: "Should be greater than or equal to 1"}}
+	}
+	return nil
+}
+
+type ContainsDigitRule struct{ Digit int }
+
+func (r ContainsDigitRule) Match(nb int) bool {
+	for ; nb > 0; nb /= 10 {
+		if nb%10 == r.Digit {
+			return true
+		}
+	}
+	return false
+}
+
+func (ContainsDigitRule) Cost(nb int) int64 { return digits(nb) }
+
+func (r ContainsDigitRule) Validate() RuleErrors {
+	if r.Digit < 0 || r.Digit > 9 {
+		return RuleErrors{{Field: "digit", Message: "Should be between 0 and 9"}}
+	}
+	return nil
+}
+
+type PrimeRule struct{}
+
+func (PrimeRule) Match(nb int) bool {
+	if nb < 2 {
+		return false
+	}
+	if nb%2 == 0 {
+		return nb == 2
+	}
+	for d := 3; d*d <= nb; d += 2 {
+		if nb%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Cost counts the odd divisors tried at most
+func (PrimeRule) Cost(nb int) int64 { return int64(math.Sqrt(float64(nb)))/2 + 1 }
+
+func (PrimeRule) Validate() RuleErrors { return nil }
+
+type PerfectSquareRule struct{}
+
+func (PerfectSquareRule) Match(nb int) bool {
+	root := int(math.Sqrt(float64(nb)))
+	// The float root may be one off for big numbers
+	for root*root > nb {
+		root--
+	}
+	for (root+1)*(root+1) <= nb {
+		root++
+	}
+	return root*root == nb
+}
+
+func (PerfectSquareRule) Cost(int) int64 { return 1 }
+
+func (PerfectSquareRule) Validate() RuleErrors { return nil }
+
+// RangeRule matches numbers from Min to Max included
+type RangeRule struct{ Min, Max int }
+
+func (r RangeRule) Match(nb int) bool { return nb >= r.Min && nb <= r.Max }
+
+func (RangeRule) Cost(int) int64 { return 1 }
+
+func (r RangeRule) Validate() RuleErrors {
+	if r.Min > r.Max {
+		return RuleErrors{{Field: "max", Message: "Should be greater than or equal to min"}}
+	}
+	return nil
+}
+
+// DigitSumRule matches numbers whose sum of digits is divisible by Mod
+type DigitSumRule struct{ Mod int }
+
+func (r DigitSumRule) Match(nb int) bool {
+	sum := 0
+	for ; nb > 0; nb /= 10 {
+		sum += nb % 10
+	}
+	return sum%r.Mod == 0
+}
+
+func (DigitSumRule) Cost(nb int) int64 { return digits(nb) }
+
+func (r DigitSumRule) Validate() RuleErrors {
+	if r.Mod < 1 {
+		return RuleErrors{{Field: "mod", Message: "Should be greater than or equal to 1"}}
+	}
+	return nil
+}
+
+type NotRule struct{ Rule Rule }
+
+func (r NotRule) Match(nb int) bool { return !r.Rule.Match(nb) }
+
+func (r NotRule) Cost(nb int) int64 { return 1 + r.Rule.Cost(nb) }
+
+func (r NotRule) Validate() RuleErrors { return r.Rule.Validate().prefix("rule") }
+
+// AllRule matches when every rule matches
+type AllRule struct{ Rules []Rule }
+
+func (r AllRule) Match(nb int) bool {
+	for _, rule := range r.Rules {
+		if !rule.Match(nb) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r AllRule) Cost(nb int) int64 { return costs(r.Rules, nb) }
+
+func (r AllRule) Validate() RuleErrors { return validateRules(r.Rules) }
+
+// AnyRule matches when one of the rules matches
+type AnyRule struct{ Rules []Rule }
+
+func (r AnyRule) Match(nb int) bool {
+	for _, rule := range r.Rules {
+		if rule.Match(nb) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r AnyRule) Cost(nb int) int64 { return costs(r.Rules, nb) }
+
+func (r AnyRule) Validate() RuleErrors { return validateRules(r.Rules) }
+
+// costs is the cost of matching every rule, combinations may stop before
+func costs(rules []Rule, nb int) int64 {
+	cost := int64(1)
+	for _, rule := range rules {
+		cost += rule.Cost(nb)
+	}
+	return cost
+}
+
+// digits is the number of digits of nb
+func digits(nb int) int64 {
+	n := int64(1)
+	for ; nb >= 10; nb /= 10 {
+		n++
+	}
+	return n
+}
+
+func validateRules(rules []Rule) RuleErrors {
+	if len(rules) == 0 {
+		return RuleErrors{{Field: "rules", Message: "Should combine at least one rule"}}
+	}
+	var errs RuleErrors
+	for i, rule := range rules {
+		errs = append(errs, rule.Validate().prefix(fmt.Sprintf("rules[%d]", i))...)
+	}
+	return errs
+}
+
+// ExprRule matches the numbers for which its expression is true, it doesn't
+// match once its budget is exhausted or when the expression fails.
+type ExprRule struct {
+	Program *expr.Program
+	Budget  *expr.Budget
+}
+
+func (r ExprRule) Match(nb int) bool {
+	ok, err := r.Program.Run(int64(nb), r.Budget)
+	return ok && err == nil
+}
+
+func (ExprRule) Cost(int) int64 { return 0 }
+
+func (r ExprRule) Validate() RuleErrors { return nil }
+
+// RuleEnv compiles the expressions of the rules and bounds their evaluation
+type RuleEnv struct {
+	// Compile defaults to expr.Compile
+	Compile func(src string) (*expr.Program, error)
+	Budget  *expr.Budget
+}
+
+// RuleSpec is the JSON representation of a rule, such as
+// `{"type":"contains_digit","digit":3,"str":"fizz"}`. Only the rules of the
+// request have a word, nested rules are combined by not, all and any.
+type RuleSpec struct {
+	Type  string     `json:"type"`
+	Str   string     `json:"str,omitempty"`
+	Expr  string     `json:"expr,omitempty"`
+	Mod   int        `json:"mod,omitempty"`
+	Digit *int       `json:"digit,omitempty"`
+	Min   *int       `json:"min,omitempty"`
+	Max   *int       `json:"max,omitempty"`
+	Rule  *RuleSpec  `json:"rule,omitempty"`
+	Rules []RuleSpec `json:"rules,omitempty"`
+}
+
+// RuleTypes are the types of RuleSpec
+var RuleTypes = []string{
+	"divisible", "contains_digit", "prime", "perfect_square", "range", "digit_sum", "expr", "not", "all", "any",
+}
+
+// count is the number of rules of the spec, nested ones included
+func (s RuleSpec) count() int {
+	n := 1
+	if s.Rule != nil {
+		n += s.Rule.count()
+	}
+	for _, rule := range s.Rules {
+		n += rule.count()
+	}
+	return n
+}
+
+// required reports a missing field of the spec
+func required(field string) RuleErrors {
+	return RuleErrors{{Field: field, Message: "Is required"}}
+}
+
+// Parse builds the rule of the spec, the errors are relative to the spec
+func (s RuleSpec) Parse(depth int, env RuleEnv) (Rule, RuleErrors) {
+	if depth > MaxRuleDepth {
+		return nil, RuleErrors{{Message: "Should not nest more than " + strconv.Itoa(MaxRuleDepth) + " rules"}}
+	}
+	var rule Rule
+	var errs RuleErrors
+	switch s.Type {
+	case "divisible":
+		rule = DivisibleRule{Mod: s.Mod}
+	case "digit_sum":
+		rule = DigitSumRule{Mod: s.Mod}
+	case "contains_digit":
+		if s.Digit == nil {
+			return nil, required("digit")
+		}
+		rule = ContainsDigitRule{Digit: *s.Digit}
+	case "expr":
+		if s.Expr == "" {
+			return nil, required("expr")
+		}
+		compile := env.Compile
+		if compile == nil {
+			compile = expr.Compile
+		}
+		program, err := compile(s.Expr)
+		var exprErr *expr.Error
+		if errors.As(err, &exprErr) {
+			return nil, RuleErrors{{Field: "expr", Message: exprErr.Message, Position: exprErr.Pos}}
+		} else if err != nil {
+			return nil, RuleErrors{{Field: "expr", Message: err.Error()}}
+		}
+		rule = ExprRule{Program: program, Budget: env.Budget}
+	case "prime":
+		rule = PrimeRule{}
+	case "perfect_square":
+		rule = PerfectSquareRule{}
+	case "range":
+		if s.Min == nil {
+			errs = append(errs, required("min")...)
+		}
+		if s.Max == nil {
+			errs = append(errs, required("max")...)
+		}
+		if errs != nil {
+			return nil, errs
+		}
+		rule = RangeRule{Min: *s.Min, Max: *s.Max}
+	case "not":
+		if s.Rule == nil {
+			return nil, required("rule")
+		}
+		nested, errs := s.Rule.Parse(depth+1, env)
+		if errs != nil {
+			return nil, errs.prefix("rule")
+		}
+		rule = NotRule{Rule: nested}
+	case "all", "any":
+		rules := make([]Rule, len(s.Rules))
+		for i, spec := range s.Rules {
+			nested, nestedErrs := spec.Parse(depth+1, env)
+			errs = append(errs, nestedErrs.prefix(fmt.Sprintf("rules[%d]", i))...)
+			rules[i] = nested
+		}
+		if errs != nil {
+			return nil, errs
+		}
+		if s.Type == "all" {
+			rule = AllRule{Rules: rules}
+		} else {
+			rule = AnyRule{Rules: rules}
+		}
+	default:
+		return nil, RuleErrors{{Field: "type", Message: "Should be one of " + strings.Join(RuleTypes, " ")}}
+	}
+
+	if depth > 0 && s.Str != "" {
+		errs = append(errs, RuleError{Field: "str", Message: "Only the rules of the request have a word"})
+	}
+	errs = append(errs, rule.Validate()...)
+	if errs != nil {
+		return nil, errs
+	}
+	return rule, nil
+}
+
+// WordRule replaces the numbers matching its rule by its word
+type WordRule struct {
+	Rule Rule
+	Word *numfmt.Template
+}
+
+// RulesRequest computes a sequence where each number is replaced by the words of
+// every matching rule, in order, or kept when none matches.
+type RulesRequest struct {
+	Limit int        `json:"limit" binding:"required,gte=1,lte=1000000"`
+	Rules []RuleSpec `json:"rules" binding:"required"`
+}
+
+// Parse builds the rules of the request, reporting every invalid rule
+func (rr *RulesRequest) Parse(env RuleEnv) ([]WordRule, error) {
+	count := 0
+	for _, spec := range rr.Rules {
+		count += spec.count()
+	}
+	if len(rr.Rules) == 0 || count > MaxRules {
+		return nil, RuleErrors{{Field: "rules", Message: "Should have between 1 and " + strconv.Itoa(MaxRules) + " rules"}}
+	}
+
+	rules := make([]WordRule, len(rr.Rules))
+	var errs RuleErrors
+	for i, spec := range rr.Rules {
+		path := fmt.Sprintf("rules[%d]", i)
+		word, err := numfmt.Compile(spec.Str)
+		var templateErr *numfmt.Error
+		if spec.Str == "" {
+			errs = append(errs, required("str").prefix(path)...)
+		} else if errors.As(err, &templateErr) {
+			errs = append(errs, RuleError{
+				Field:    path + ".str",
+				Message:  "Invalid template: " + templateErr.Message,
+				Position: templateErr.Pos,
+			})
+		}
+		rule, ruleErrs := spec.Parse(0, env)
+		errs = append(errs, ruleErrs.prefix(path)...)
+		rules[i] = WordRule{Rule: rule, Word: word}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return rules, nil
+}
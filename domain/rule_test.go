@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulesMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		matches []int
+	}{
+		{name: "divisible", rule: DivisibleRule{Mod: 4}, matches: []int{4, 8, 12, 16, 20}},
+		{name: "contains digit", rule: ContainsDigitRule{Digit: 3}, matches: []int{3, 13}},
+		{name: "contains zero", rule: ContainsDigitRule{Digit: 0}, matches: []int{10, 20}},
+		{name: "prime", rule: PrimeRule{}, matches: []int{2, 3, 5, 7, 11, 13, 17, 19}},
+		{name: "perfect square", rule: PerfectSquareRule{}, matches: []int{1, 4, 9, 16}},
+		{name: "range", rule: RangeRule{Min: 5, Max: 7}, matches: []int{5, 6, 7}},
+		{name: "digit sum", rule: DigitSumRule{Mod: 5}, matches: []int{5, 14, 19}},
+		{name: "not", rule: NotRule{Rule: RangeRule{Min: 2, Max: 20}}, matches: []int{1}},
+		{name: "all", rule: AllRule{Rules: []Rule{PrimeRule{}, ContainsDigitRule{Digit: 1}}}, matches: []int{11, 13, 17, 19}},
+		{name: "any", rule: AnyRule{Rules: []Rule{DivisibleRule{Mod: 7}, PerfectSquareRule{}}}, matches: []int{1, 4, 7, 9, 14, 16}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var matches []int
+			for nb := 1; nb <= 20; nb++ {
+				if test.rule.Match(nb) {
+					matches = append(matches, nb)
+				}
+			}
+			assert.Equal(t, test.matches, matches)
+		})
+	}
+}
+
+func TestRulesRequestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		errs RuleErrors
+	}{
+		{
+			name: "valid",
+			body: `{"limit": 10, "rules": [
+				{"type": "contains_digit", "digit": 3, "str": "fizz"},
+				{"type": "not", "str": "odd", "rule": {"type": "divisible", "mod": 2}},
+				{"type": "any", "str": "x", "rules": [{"type": "prime"}, {"type": "range", "min": 1, "max": 1}]}
+			]}`,
+		},
+		{
+			name: "errors per rule",
+			body: `{"limit": 10, "rules": [
+				{"type": "contains_digit", "digit": 12, "str": "fizz"},
+				{"type": "divisible", "mod": 3, "str": "ok{"},
+				{"type": "all", "str": "x", "rules": [{"type": "prime"}, {"type": "range", "min": 1}, {"type": "cube"}]},
+				{"type": "not", "rule": {"type": "digit_sum", "mod": 0, "str": "nested"}},
+				{"type": "expr", "expr": "n % 3 ==", "str": "fizz"}
+			]}`,
+			errs: RuleErrors{
+				{Field: "rules[0].digit", Message: "Should be between 0 and 9"},
+				{Field: "rules[1].str", Message: "Invalid template: Unclosed '{', write '{{' for a brace", Position: 3},
+				{Field: "rules[2].rules[1].max", Message: "Is required"},
+				{Field: "rules[2].rules[2].type", Message: "Should be one of divisible contains_digit prime perfect_square range digit_sum expr not all any"},
+				{Field: "rules[3].str", Message: "Is required"},
+				{Field: "rules[3].rule.str", Message: "Only the rules of the request have a word"},
+				{Field: "rules[3].rule.mod", Message: "Should be greater than or equal to 1"},
+				{Field: "rules[4].expr", Message: "Unexpected end of expression", Position: 9},
+			},
+		},
+		{
+			name: "too deep",
+			body: `{"limit": 10, "rules": [{"type": "not", "str": "x", "rule": {"type": "not", "rule": {"type": "not",
+				"rule": {"type": "not", "rule": {"type": "not", "rule": {"type": "prime"}}}}}}]}`,
+			errs: RuleErrors{{Field: "rules[0].rule.rule.rule.rule.rule", Message: "Should not nest more than 4 rules"}},
+		},
+		{
+			name: "empty",
+			body: `{"limit": 10, "rules": []}`,
+			errs: RuleErrors{{Field: "rules", Message: "Should have between 1 and 32 rules"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var request RulesRequest
+			assert.NoError(t, json.Unmarshal([]byte(test.body), &request))
+			rules, err := request.Parse(RuleEnv{})
+			if test.errs == nil {
+				assert.NoError(t, err)
+				assert.Len(t, rules, len(request.Rules))
+				return
+			}
+			assert.Equal(t, test.errs, err)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// SeriesResolution is the width of the buckets of a time series. Buckets are
+// stored by chunks which expire together after the retention.
+type SeriesResolution struct {
+	Name      string
+	Step      time.Duration
+	Chunk     time.Duration
+	Retention time.Duration
+}
+
+var (
+	SeriesMinute = SeriesResolution{Name: "1m", Step: time.Minute, Chunk: time.Hour}
+	SeriesHour   = SeriesResolution{Name: "1h", Step: time.Hour, Chunk: 24 * time.Hour}
+	SeriesDay    = SeriesResolution{Name: "1d", Step: 24 * time.Hour, Chunk: 30 * 24 * time.Hour}
+)
+
+// Bucket is the start of the bucket holding t
+func (r SeriesResolution) Bucket(t time.Time) time.Time {
+	return t.Truncate(r.Step)
+}
+
+// ChunkOf is the start of the chunk holding t
+func (r SeriesResolution) ChunkOf(t time.Time) time.Time {
+	return t.Truncate(r.Chunk)
+}
+
+type SeriesPoint struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+}
@@ -0,0 +1,130 @@
+package domain
+
+import "math/big"
+
+// StatsRule replaces the multiples of Mod by Str
+type StatsRule struct {
+	Mod int    `json:"mod" binding:"required,gte=1"`
+	Str string `json:"str" binding:"required"`
+}
+
+// StatsRequest counts the terms of the numbers 1 to Limit, the words of the
+// rules matching a number are written one after the other. Every combination of
+// rules is counted, so at most 12 rules are accepted.
+type StatsRequest struct {
+	Limit int         `json:"limit" binding:"required,gte=1"`
+	Rules []StatsRule `json:"rules" binding:"required,min=1,max=12,dive"`
+}
+
+// StatsRequest is the request counting the terms of fbr
+func (fbr *FizzBuzzRequest) StatsRequest() StatsRequest {
+	return StatsRequest{
+		Limit: fbr.Limit,
+		Rules: []StatsRule{{Mod: fbr.FstModulo, Str: fbr.FstStr}, {Mod: fbr.SndModulo, Str: fbr.SndStr}},
+	}
+}
+
+// WordStats counts the numbers matched by exactly Rules, given by their index.
+// Word is empty for the numbers matched by no rule. First and Last are 0 when
+// no number up to the limit is matched.
+type WordStats struct {
+	Word    string  `json:"word"`
+	Rules   []int   `json:"rules"`
+	Count   int64   `json:"count"`
+	First   int64   `json:"first"`
+	Last    int64   `json:"last"`
+	Density float64 `json:"density"`
+}
+
+// Stats of a sequence, Period is the LCM of the modulos after which the sequence
+// repeats itself, the density is the share of a combination over a period.
+type Stats struct {
+	Limit  int64       `json:"limit"`
+	Period *big.Int    `json:"period"`
+	Words  []WordStats `json:"words"`
+}
+
+// ComputeStats counts every combination of rules without generating the
+// sequence. The numbers divisible by the modulos of a set of rules are counted
+// by the LCM of the set, exact combinations then come from inclusion-exclusion
+// over the sets holding them.
+func ComputeStats(request StatsRequest) *Stats {
+	rules := request.Rules
+	limit := int64(request.Limit)
+	combinations := 1 << len(rules)
+
+	// lcms[mask] is the LCM of the modulos of the rules in mask
+	lcms := make([]*big.Int, combinations)
+	lcms[0] = big.NewInt(1)
+	gcd := new(big.Int)
+	for mask := 1; mask < combinations; mask++ {
+		low := lowestRule(mask)
+		mod := big.NewInt(int64(rules[low].Mod))
+		rest := lcms[mask&^(1<<low)]
+		gcd.GCD(nil, nil, rest, mod)
+		lcms[mask] = new(big.Int).Mul(rest, new(big.Int).Quo(mod, gcd))
+	}
+	period := lcms[combinations-1]
+
+	// Multiples up to the limit and over a period, made exact from the larger sets
+	counts := make([]int64, combinations)
+	perPeriod := make([]*big.Int, combinations)
+	bigLimit := big.NewInt(limit)
+	for mask := range lcms {
+		if lcms[mask].Cmp(bigLimit) <= 0 {
+			counts[mask] = limit / lcms[mask].Int64()
+		}
+		perPeriod[mask] = new(big.Int).Quo(period, lcms[mask])
+	}
+	for i := range rules {
+		for mask := 0; mask < combinations; mask++ {
+			if mask&(1<<i) == 0 {
+				counts[mask] -= counts[mask|1<<i]
+				perPeriod[mask].Sub(perPeriod[mask], perPeriod[mask|1<<i])
+			}
+		}
+	}
+
+	stats := &Stats{Limit: limit, Period: period, Words: make([]WordStats, combinations)}
+	for mask := range stats.Words {
+		ws := WordStats{Rules: []int{}, Count: counts[mask]}
+		for i, rule := range rules {
+			if mask&(1<<i) != 0 {
+				ws.Word += rule.Str
+				ws.Rules = append(ws.Rules, i)
+			}
+		}
+		ws.Density, _ = new(big.Rat).SetFrac(perPeriod[mask], period).Float64()
+		if ws.Count > 0 {
+			ws.First, ws.Last = occurrences(rules, mask, lcms[mask].Int64(), limit)
+		}
+		stats.Words[mask] = ws
+	}
+	return stats
+}
+
+func lowestRule(mask int) int {
+	i := 0
+	for mask&(1<<i) == 0 {
+		i++
+	}
+	return i
+}
+
+// occurrences returns the first and last multiples of lcm up to limit matched by
+// none of the rules out of mask. There is at least one of them.
+func occurrences(rules []StatsRule, mask int, lcm, limit int64) (first, last int64) {
+	matched := func(n int64) bool {
+		for i, rule := range rules {
+			if mask&(1<<i) == 0 && n%int64(rule.Mod) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	for first = lcm; !matched(first); first += lcm {
+	}
+	for last = limit / lcm * lcm; !matched(last); last -= lcm {
+	}
+	return first, last
+}
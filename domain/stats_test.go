@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	request := FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 1_000_000_000_000, FstStr: "fizz", SndStr: "buzz"}
+	stats := ComputeStats(request.StatsRequest())
+	assert.Equal(t, big.NewInt(15), stats.Period)
+	assert.Equal(t, []WordStats{
+		{Word: "", Rules: []int{}, Count: 533_333_333_333, First: 1, Last: 999_999_999_998, Density: 8.0 / 15},
+		{Word: "fizz", Rules: []int{0}, Count: 266_666_666_667, First: 3, Last: 999_999_999_999, Density: 4.0 / 15},
+		{Word: "buzz", Rules: []int{1}, Count: 133_333_333_334, First: 5, Last: 1_000_000_000_000, Density: 2.0 / 15},
+		{Word: "fizzbuzz", Rules: []int{0, 1}, Count: 66_666_666_666, First: 15, Last: 999_999_999_990, Density: 1.0 / 15},
+	}, stats.Words)
+}
+
+// Counts of many rules match the ones of the generated sequence
+func TestComputeStatsBruteForce(t *testing.T) {
+	request := StatsRequest{Limit: 1000, Rules: []StatsRule{{Mod: 4, Str: "a"}, {Mod: 6, Str: "b"}, {Mod: 10, Str: "c"}, {Mod: 700, Str: "d"}}}
+	stats := ComputeStats(request)
+	assert.Equal(t, big.NewInt(2100), stats.Period)
+
+	for mask, ws := range stats.Words {
+		var count, first, last int64
+		for n := 1; n <= request.Limit; n++ {
+			matched := 0
+			for i, rule := range request.Rules {
+				if n%rule.Mod == 0 {
+					matched |= 1 << i
+				}
+			}
+			if matched == mask {
+				count++
+				if first == 0 {
+					first = int64(n)
+				}
+				last = int64(n)
+			}
+		}
+		assert.Equal(t, count, ws.Count, ws.Word)
+		assert.Equal(t, first, ws.First, ws.Word)
+		assert.Equal(t, last, ws.Last, ws.Word)
+	}
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"FizzBuzz/domain/numfmt"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// TermRequest asks for the terms at the indexes N of the sequence of its rules,
+// without computing the terms before them. At most 1000 terms are asked at once.
+type TermRequest struct {
+	FstModulo    int    `json:"fst_mod" form:"fst_mod" binding:"required,gte=1"`
+	SndModulo    int    `json:"snd_mod" form:"snd_mod" binding:"required,gte=1"`
+	FstStr       string `json:"fst_str" form:"fst_str" binding:"required,template"`
+	SndStr       string `json:"snd_str" form:"snd_str" binding:"required,template"`
+	NumberFormat string `json:"number_format,omitempty" form:"number_format" binding:"omitempty,numberformat"`
+	N            []int  `json:"n" form:"n" binding:"required,min=1,max=1000,dive,gte=1"`
+}
+
+// Term is the value of the sequence at the index N
+type Term struct {
+	N     int    `json:"n"`
+	Value string `json:"value"`
+}
+
+// termPayload tells the stored term requests from the fizzbuzz ones
+type termPayload struct {
+	Kind string `json:"kind"`
+	*TermRequest
+}
+
+func (tr *TermRequest) ToBytes() ([]byte, error) {
+	data, err := json.Marshal(termPayload{Kind: "term", TermRequest: tr})
+	if err != nil {
+		return nil, fmt.Errorf("impossible to marshal term request: %w", err)
+	}
+	return data, nil
+}
+
+// Normalize writes the number format in its canonical form, see FizzBuzzRequest
+func (tr *TermRequest) Normalize() {
+	tr.NumberFormat = canonicalFormat(tr.NumberFormat)
+}
+
+func (tr *TermRequest) Identity() ([]byte, error) {
+	encoder := NewIdentityEncoder("term").
+		Int("fst_mod", tr.FstModulo).
+		Int("snd_mod", tr.SndModulo).
+		String("fst_str", tr.FstStr).
+		String("snd_str", tr.SndStr)
+	if tr.NumberFormat != "" {
+		format, err := numfmt.ParseNumberFormat(tr.NumberFormat)
+		if err != nil {
+			return nil, fmt.Errorf("impossible to identify term request: %w", err)
+		}
+		if canonical := format.String(); canonical != "" {
+			encoder = encoder.String("number_format", canonical)
+		}
+	}
+	for _, n := range tr.N {
+		encoder = encoder.Int("n", n)
+	}
+	return encoder.Bytes(), nil
+}
+
+// FromStrToRequest decodes a stored request whatever its kind
+func FromStrToRequest(payload string) Identifiable {
+	var kind struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal([]byte(payload), &kind); err != nil {
+		return nil
+	}
+	if kind.Kind == "term" {
+		var tr TermRequest
+		if err := json.Unmarshal([]byte(payload), &tr); err != nil {
+			return nil
+		}
+		return &tr
+	}
+	if fbr := FromStrToRequestFB(payload); fbr != nil {
+		return fbr
+	}
+	return nil
+}
+
+// ErrInvalidCountedRequest is returned when decoding a request of an unknown kind
+var ErrInvalidCountedRequest = errors.New("invalid counted request")
+
+// CountedRequest is a counted request whatever its kind, fizzbuzz and term
+// requests share the counters. It is written as stored, term requests with
+// their kind.
+type CountedRequest struct {
+	Identifiable
+}
+
+func (cr CountedRequest) MarshalJSON() ([]byte, error) {
+	if cr.Identifiable == nil {
+		return []byte("null"), nil
+	}
+	return cr.ToBytes()
+}
+
+func (cr *CountedRequest) UnmarshalJSON(data []byte) error {
+	request := FromStrToRequest(string(data))
+	if request == nil {
+		return ErrInvalidCountedRequest
+	}
+	cr.Identifiable = request
+	return nil
+}
+
+// FizzBuzz is the counted request when it is a fizzbuzz one, nil otherwise
+func (cr CountedRequest) FizzBuzz() *FizzBuzzRequest {
+	fbr, _ := cr.Identifiable.(*FizzBuzzRequest)
+	return fbr
+}
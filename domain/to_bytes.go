@@ -1,5 +1,13 @@
 package domain
 
 type ToBytes interface {
-	ToBytes() []byte
+	ToBytes() ([]byte, error)
+}
+
+// Identifiable is a request which can be counted in metrics
+type Identifiable interface {
+	ToBytes
+	// Identity is the canonical encoding hashed to count the request,
+	// two requests with the same identity share the same counter.
+	Identity() ([]byte, error)
 }
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"FizzBuzz/domain"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	ErrUnknownHasher = errors.New("unknown hash function")
+)
+
+// Hasher computes the keys of the counters, changing it requires a migration
+type Hasher interface {
+	Name() string
+	Hash(data []byte) (string, error)
+}
+
+// NewHasher returns the hasher named xxhash, sha256 or blake2b
+func NewHasher(name string) (Hasher, error) {
+	switch name {
+	case "xxhash":
+		return XXHasher{}, nil
+	case "sha256":
+		return SHA256Hasher{}, nil
+	case "blake2b":
+		return Blake2bHasher{}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownHasher, name)
+}
+
+// XXHasher is fast but only 64 bits long, collisions are detected by the repository
+type XXHasher struct{}
+
+func (XXHasher) Name() string { return "xxhash" }
+
+func (XXHasher) Hash(data []byte) (string, error) {
+	return GetHash(data)
+}
+
+type SHA256Hasher struct{}
+
+func (SHA256Hasher) Name() string { return "sha256" }
+
+func (SHA256Hasher) Hash(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Blake2bHasher uses a 256 bits digest
+type Blake2bHasher struct{}
+
+func (Blake2bHasher) Name() string { return "blake2b" }
+
+func (Blake2bHasher) Hash(data []byte) (string, error) {
+	sum := blake2b.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GetIdentityHash hashes the canonical identity of the request
+func GetIdentityHash(hasher Hasher, request domain.Identifiable) (string, error) {
+	identity, err := request.Identity()
+	if err != nil {
+		return "", err
+	}
+	return hasher.Hash(identity)
+}
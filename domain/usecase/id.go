@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RandomID returns a random hexadecimal identifier of 2*size characters
+func RandomID(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"errors"
+	"math"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+var (
+	ErrInvalidSketchBounds = errors.New("sketch error bounds must be between 0 and 1")
+)
+
+// CountMinSketch locates the counters of a Count-Min Sketch, they are kept by the
+// repositories. An estimate exceeds the real count by at most Epsilon times the
+// total of counts with a probability of 1 - Delta, it is never below it.
+type CountMinSketch struct {
+	Width int
+	Depth int
+}
+
+func NewCountMinSketch(epsilon, delta float64) (CountMinSketch, error) {
+	if epsilon <= 0 || epsilon >= 1 || delta <= 0 || delta >= 1 {
+		return CountMinSketch{}, ErrInvalidSketchBounds
+	}
+	return CountMinSketch{
+		Width: int(math.Ceil(math.E / epsilon)),
+		Depth: int(math.Ceil(math.Log(1 / delta))),
+	}, nil
+}
+
+// Size is the number of counters of the sketch
+func (cms CountMinSketch) Size() int {
+	return cms.Width * cms.Depth
+}
+
+// Cells returns the index of the counter of each row for data, rows being laid
+// out one after the other. Rows use the double hashing of a single xxhash.
+func (cms CountMinSketch) Cells(data []byte) []int {
+	sum := xxhash.Sum64(data)
+	h1, h2 := uint32(sum), uint32(sum>>32)|1
+	cells := make([]int, cms.Depth)
+	for row := range cells {
+		col := (uint64(h1) + uint64(row)*uint64(h2)) % uint64(cms.Width)
+		cells[row] = row*cms.Width + int(col)
+	}
+	return cells
+}
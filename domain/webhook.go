@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookLeaderChanged    WebhookEvent = "leader_changed"
+	WebhookThresholdCrossed WebhookEvent = "threshold_crossed"
+)
+
+type WebhookSubscription struct {
+	ID        string         `json:"id"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"secret,omitempty"`
+	Events    []WebhookEvent `json:"events"`
+	Threshold int            `json:"threshold,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Wants tells if the subscription listens to the event
+func (ws *WebhookSubscription) Wants(event WebhookEvent) bool {
+	for _, e := range ws.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the signed body sent to subscribers
+type WebhookPayload struct {
+	Event       WebhookEvent    `json:"event"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Key         string          `json:"key"`
+	Score       int             `json:"counter"`
+	Request     *CountedRequest `json:"request,omitempty"`
+	PreviousKey string          `json:"previous_key,omitempty"`
+	Threshold   int             `json:"threshold,omitempty"`
+}
+
+// WebhookDelivery is a payload to deliver to one subscription
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	URL            string          `json:"url"`
+	Event          WebhookEvent    `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error,omitempty"`
+	FailedAt       time.Time       `json:"failed_at,omitempty"`
+}
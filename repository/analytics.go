@@ -0,0 +1,120 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/analytics.go -source analytics.go
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type AnalyticsRepository interface {
+	Add(ctx context.Context, samples []domain.AnalyticsSample) error
+	// Top returns the count most frequent members of a dimension
+	Top(ctx context.Context, dimension string, count int64) ([]domain.AnalyticsValue, error)
+	// Histogram returns the count of each bucket of the limits
+	Histogram(ctx context.Context) (map[string]int, error)
+}
+
+// analyticsScript counts a member in each dimension, at most ARGV[1] members are
+// kept per dimension. A new member replaces the lightest one and inherits its
+// count (Space-Saving), so the counts of values seen late are upper bounds.
+// KEYS dimensions, ARGV: cap, members in the order of KEYS
+var analyticsScript = redis.NewScript(`
+local cap = tonumber(ARGV[1])
+for i, key in ipairs(KEYS) do
+	local member = ARGV[i + 1]
+	if redis.call('ZSCORE', key, member) or redis.call('ZCARD', key) < cap then
+		redis.call('ZINCRBY', key, 1, member)
+	else
+		local lightest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+		-- Nothing is kept with a cap below 1
+		if lightest[1] then
+			redis.call('ZREM', key, lightest[1])
+			redis.call('ZADD', key, tonumber(lightest[2]) + 1, member)
+		end
+	end
+end
+return 0
+`)
+
+type analyticsRepository struct {
+	maxValues int
+	client    *redis.Client
+	logger    *zap.Logger
+}
+
+// NewAnalyticsRepository keeps at most maxValues distinct values per dimension
+func NewAnalyticsRepository(redisCli *redis.Client, maxValues int, logger *zap.Logger) AnalyticsRepository {
+	return &analyticsRepository{maxValues: maxValues, client: redisCli, logger: logger}
+}
+
+func (a *analyticsRepository) Add(ctx context.Context, samples []domain.AnalyticsSample) error {
+	if err := analyticsScript.Load(ctx, a.client).Err(); err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	_, err := a.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, sample := range samples {
+			dimensions := make([]string, 0, len(sample.Members))
+			for dimension := range sample.Members {
+				dimensions = append(dimensions, dimension)
+			}
+			sort.Strings(dimensions)
+
+			keys := make([]string, len(dimensions))
+			args := make([]interface{}, 0, len(dimensions)+1)
+			args = append(args, a.maxValues)
+			for i, dimension := range dimensions {
+				keys[i] = fbRedis.KeyAnalytics(dimension)
+				args = append(args, sample.Members[dimension])
+			}
+			analyticsScript.EvalSha(ctx, pipe, keys, args...)
+			if sample.Bucket != "" {
+				pipe.HIncrBy(ctx, fbRedis.KeyAnalyticsLimits(), sample.Bucket, 1)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+func (a *analyticsRepository) Top(ctx context.Context, dimension string, count int64) ([]domain.AnalyticsValue, error) {
+	scores := a.client.ZRevRangeWithScores(ctx, fbRedis.KeyAnalytics(dimension), 0, count-1)
+	if scores.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, scores.Err()
+	}
+	values := make([]domain.AnalyticsValue, len(scores.Val()))
+	for i, score := range scores.Val() {
+		values[i] = domain.AnalyticsValue{Value: score.Member.(string), Count: int(score.Score)}
+	}
+	return values, nil
+}
+
+func (a *analyticsRepository) Histogram(ctx context.Context) (map[string]int, error) {
+	res := a.client.HGetAll(ctx, fbRedis.KeyAnalyticsLimits())
+	if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, res.Err()
+	}
+	buckets := make(map[string]int, len(res.Val()))
+	for bucket, count := range res.Val() {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			a.logger.Error("Invalid histogram bucket", zap.String("bucket", bucket), zap.Error(err))
+			continue
+		}
+		buckets[bucket] = n
+	}
+	return buckets, nil
+}
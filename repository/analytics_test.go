@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type AnalyticsRepositorySuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	repo        AnalyticsRepository
+}
+
+func (suite *AnalyticsRepositorySuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	suite.repo = NewAnalyticsRepository(fbRedis.NewRedis(host[0], host[1], ""), 2, zap.NewExample())
+}
+
+func (suite *AnalyticsRepositorySuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+func (suite *AnalyticsRepositorySuite) TestCappedValues() {
+	ctx := context.Background()
+	var samples []domain.AnalyticsSample
+	for _, value := range []string{"3", "3", "3", "5", "7"} {
+		samples = append(samples, domain.AnalyticsSample{
+			Members: map[string]string{"fst_mod": value, "snd_str": "buzz"},
+			Bucket:  "100",
+		})
+	}
+	suite.Require().NoError(suite.repo.Add(ctx, samples))
+
+	values, err := suite.repo.Top(ctx, "fst_mod", 10)
+	suite.Require().NoError(err)
+	// 7 took the place of 5 with its count
+	suite.Equal([]domain.AnalyticsValue{{Value: "3", Count: 3}, {Value: "7", Count: 2}}, values)
+
+	values, err = suite.repo.Top(ctx, "snd_str", 10)
+	suite.Require().NoError(err)
+	suite.Equal([]domain.AnalyticsValue{{Value: "buzz", Count: 5}}, values)
+
+	histogram, err := suite.repo.Histogram(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(map[string]int{"100": 5}, histogram)
+}
+
+func (suite *AnalyticsRepositorySuite) TestNoValueKept() {
+	ctx := context.Background()
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	repo := NewAnalyticsRepository(fbRedis.NewRedis(host[0], host[1], ""), 0, zap.NewExample())
+	suite.Require().NoError(repo.Add(ctx, []domain.AnalyticsSample{{Members: map[string]string{"fst_mod": "3"}}}))
+
+	values, err := repo.Top(ctx, "fst_mod", 10)
+	suite.Require().NoError(err)
+	suite.Empty(values)
+}
+
+func TestAnalyticsRepositorySuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsRepositorySuite))
+}
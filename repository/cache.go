@@ -7,7 +7,9 @@ import (
 	"FizzBuzz/domain/usecase"
 	fbRedis "FizzBuzz/repository/redis"
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
@@ -15,85 +17,275 @@ import (
 
 var (
 	ErrCacheKeyNotFound = errors.New("key requested not found")
-	ErrMaxRetryTx       = errors.New("maximum retry reach for transaction")
 )
 
-type TxFunc = func(tx *redis.Tx) error
-
 type CacheCounterRepository interface {
-	IncrementRequest(ctx context.Context, request domain.ToBytes) error
+	// IncrementRequest counts the request, client is added to its unique clients when not empty
+	IncrementRequest(ctx context.Context, request domain.Identifiable, client string) error
+	IncrementRequests(ctx context.Context, requests []domain.Identifiable, client string) error
 	GetCounters(ctx context.Context, from, to int64) (domain.MetricCountersScores, error)
+	// GetRankedCounters is GetCounters with the scores of another ranking
+	GetRankedCounters(ctx context.Context, ranking domain.Ranking, from, to int64) (domain.MetricCountersScores, error)
+	GetUniqueClients(ctx context.Context, key string) (int, error)
+	GetScore(ctx context.Context, key string) (int, error)
+	// AddCosts sums what serving each request took, requests never counted are skipped
+	AddCosts(ctx context.Context, requests []domain.Identifiable, costs []domain.RequestCost) error
+	GetCost(ctx context.Context, key string) (domain.MetricCost, error)
+	// Lookup returns the counter of request and its rank, ErrCacheKeyNotFound when
+	// it was never requested
+	Lookup(ctx context.Context, request domain.Identifiable, ranking domain.Ranking) (*domain.MetricLookup, error)
 	GetData(ctx context.Context, key string) (string, error)
+	// SubscribeChanges streams the counters incremented by any instance until ctx is done
+	SubscribeChanges(ctx context.Context) (<-chan []domain.CounterChange, error)
 }
 
 type cacheCounterRepository struct {
-	maxRetry int
-	client   *redis.Client
-	logger   *zap.Logger
+	client *redis.Client
+	hasher usecase.Hasher
+	series SeriesRepository
+	// global ranks the counters by their sum over every replica as well
+	global bool
+	logger *zap.Logger
+}
+
+// CacheOption enables an optional feature of the counters
+type CacheOption func(*cacheCounterRepository)
+
+// WithSeries counts every increment in the time series of its counter
+func WithSeries(series SeriesRepository) CacheOption {
+	return func(c *cacheCounterRepository) {
+		c.series = series
+	}
+}
+
+// WithGlobalRanking adds every increment to the ranking summed over replicas
+func WithGlobalRanking() CacheOption {
+	return func(c *cacheCounterRepository) {
+		c.global = true
+	}
+}
+
+func NewCacheCounterRepository(redisCli *redis.Client,
+	hasher usecase.Hasher,
+	logger *zap.Logger,
+	opts ...CacheOption) CacheCounterRepository {
+	c := &cacheCounterRepository{logger: logger, client: redisCli, hasher: hasher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// keyAndPayload returns the hash of the request identity, the identity and the
+// payload stored to display it
+func keyAndPayload(hasher usecase.Hasher, request domain.Identifiable) (string, []byte, []byte, error) {
+	identity, err := request.Identity()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	hash, err := hasher.Hash(identity)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	data, err := request.ToBytes()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return hash, identity, data, nil
 }
 
-func NewCacheCounterRepository(redisCli *redis.Client, logger *zap.Logger) CacheCounterRepository {
-	return &cacheCounterRepository{logger: logger, client: redisCli, maxRetry: 1000}
+// lastSeen is the member of the last seen set for a counter incremented now
+func lastSeen(hash string) redis.Z {
+	return redis.Z{Score: float64(time.Now().Unix()), Member: hash}
 }
 
-func (c *cacheCounterRepository) retryTx(ctx context.Context, tx TxFunc, keyObs string) error {
-	// Retry if the key has been changed.
-	for i := 0; i < c.maxRetry; i++ {
-		err := c.client.Watch(ctx, tx, keyObs)
-		if err == nil {
-			c.logger.Debug("Done exec tx")
-			return nil
+func (c *cacheCounterRepository) IncrementRequest(ctx context.Context,
+	request domain.Identifiable,
+	client string) error {
+	return c.IncrementRequests(ctx, []domain.Identifiable{request}, client)
+}
+
+// clientsScript adds the client to the unique clients of a counter, the counter
+// is ranked again only when the estimate changed. KEYS[1] clients of the counter,
+// KEYS[2] clients ranking, ARGV[1] client, ARGV[2] counter key.
+var clientsScript = redis.NewScript(`
+if redis.call('PFADD', KEYS[1], ARGV[1]) == 1 then
+	redis.call('ZADD', KEYS[2], redis.call('PFCOUNT', KEYS[1]), ARGV[2])
+end
+return 0
+`)
+
+// afterIncrement adds the client to the counters, counts them in the time series
+// and notifies every instance in a single pipeline. A failure doesn't fail the
+// increment.
+func (c *cacheCounterRepository) afterIncrement(ctx context.Context, changes []domain.CounterChange, client string) {
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		c.logger.Error("Failed to marshal counter changes", zap.Error(err))
+		return
+	}
+	hashes := make([]string, len(changes))
+	for i, change := range changes {
+		hashes[i] = change.Key
+	}
+
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if client != "" {
+			for _, hash := range hashes {
+				// Short enough to be sent along, a pipeline can't fall back from EVALSHA
+				clientsScript.Eval(ctx, pipe, []string{fbRedis.KeyClients(hash), fbRedis.KeyCountersClients()}, client, hash)
+			}
 		}
-		if err == redis.TxFailedErr {
-			// Optimistic lock lost. Retry.
-			continue
+		if c.series != nil {
+			c.series.Pipe(ctx, pipe, hashes, time.Now())
 		}
-
+		pipe.Publish(ctx, fbRedis.ChannelCounters(), payload)
+		return nil
+	})
+	if err != nil {
 		fbRedis.ErrorCounter.Inc()
-		return err
+		c.logger.Error("Failed to update counters after increment", zap.Error(err))
 	}
-
-	c.logger.Error("Try to execute transaction but failed after reaching max retry",
-		zap.String("KeyObserved", keyObs))
-	return ErrMaxRetryTx
 }
 
-func (c *cacheCounterRepository) IncrementRequest(ctx context.Context, request domain.ToBytes) error {
-	data := request.ToBytes()
-	hash, err := usecase.GetHash(data)
+func updateClientCount(ctx context.Context, cmd redis.Cmdable, hash string) error {
+	count, err := cmd.PFCount(ctx, fbRedis.KeyClients(hash)).Result()
 	if err != nil {
 		return err
 	}
+	return cmd.ZAdd(ctx, fbRedis.KeyCountersClients(), redis.Z{Score: float64(count), Member: hash}).Err()
+}
 
-	// Set if not exist data counter, and add counter to priorityQ
-	tx := func(tx *redis.Tx) error {
-		exist := tx.SetNX(ctx, fbRedis.KeyData(hash), data, 0)
-		if exist.Err() != nil {
-			fbRedis.ErrorCounter.Inc()
-			return exist.Err()
+func (c *cacheCounterRepository) SubscribeChanges(ctx context.Context) (<-chan []domain.CounterChange, error) {
+	pubsub := c.client.Subscribe(ctx, fbRedis.ChannelCounters())
+	// Wait for the subscription to be effective
+	if _, err := pubsub.Receive(ctx); err != nil {
+		fbRedis.ErrorCounter.Inc()
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	changes := make(chan []domain.CounterChange)
+	go func() {
+		defer close(changes)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var change []domain.CounterChange
+				if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+					c.logger.Error("Invalid counter changes received", zap.Error(err))
+					continue
+				}
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// incrementScript counts each request whose key holds its identity, or is free,
+// along with its data so that a compaction can't leave a counter without data.
+// KEYS[1] counters, KEYS[2] last seen, KEYS[3] identities, KEYS[4] optional
+// global ranking, ARGV: data key prefix, now, then key, identity and data of
+// each request. The score is -1 for
+// the requests whose key holds another request.
+var incrementScript = redis.NewScript(`
+local scores = {}
+for i = 3, #ARGV, 3 do
+	local key, identity = ARGV[i], ARGV[i + 1]
+	local stored = redis.call('HGET', KEYS[3], key)
+	local score = -1
+	if stored == identity or (not stored and redis.call('EXISTS', ARGV[1] .. key) == 0) then
+		redis.call('SET', ARGV[1] .. key, ARGV[i + 2], 'NX')
+		redis.call('HSET', KEYS[3], key, identity)
+		score = redis.call('ZINCRBY', KEYS[1], 1, key)
+		redis.call('ZADD', KEYS[2], ARGV[2], key)
+		if KEYS[4] then
+			redis.call('ZINCRBY', KEYS[4], 1, key)
+		end
+	end
+	scores[#scores + 1] = tonumber(score)
+end
+return scores
+`)
+
+// IncrementRequests counts every request at once with a script, requests whose
+// hash is taken by another one are counted again once their key is found.
+func (c *cacheCounterRepository) IncrementRequests(ctx context.Context,
+	requests []domain.Identifiable,
+	client string) error {
+	identityHashes := make([]string, len(requests))
+	hashes := make([]string, len(requests))
+	identities := make([][]byte, len(requests))
+	payloads := make([][]byte, len(requests))
+	for i, request := range requests {
+		hash, identity, data, err := keyAndPayload(c.hasher, request)
+		if err != nil {
+			return err
 		}
+		identityHashes[i], hashes[i], identities[i], payloads[i] = hash, hash, identity, data
+	}
 
-		c.logger.Debug("Redis metric", zap.Any("setnx", exist))
-
-		var countersErr error
-		if exist.Val() {
-			c.logger.Debug("ZAdd metric")
-			countersErr = tx.ZAdd(ctx, fbRedis.KeyCounters(), redis.Z{
-				Score:  1,
-				Member: hash,
-			}).Err()
-		} else {
-			c.logger.Debug("ZIncrBy metric")
-			countersErr = tx.ZIncrBy(ctx, fbRedis.KeyCounters(), 1, hash).Err()
+	keys := []string{fbRedis.KeyCounters(), fbRedis.KeyCountersSeen(), fbRedis.KeyIdentities()}
+	if c.global {
+		keys = append(keys, fbRedis.KeyCountersGlobal())
+	}
+	scores := make([]int64, len(requests))
+	pending := make([]int, len(requests))
+	for i := range pending {
+		pending[i] = i
+	}
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == maxProbes {
+			return ErrTooManyCollisions
+		}
+		args := make([]interface{}, 0, 2+3*len(pending))
+		args = append(args, fbRedis.KeyData(""), time.Now().Unix())
+		for _, i := range pending {
+			args = append(args, hashes[i], identities[i], payloads[i])
+		}
+		res, err := incrementScript.Run(ctx, c.client, keys, args...).Int64Slice()
+		if err != nil {
+			fbRedis.ErrorCounter.Inc()
+			return err
 		}
 
-		if countersErr != nil {
-			return countersErr
+		// Taken keys are solved one by one, data stored before identities is decoded
+		next := pending[:0]
+		for j, i := range pending {
+			if res[j] >= 0 {
+				scores[i] = res[j]
+				continue
+			}
+			key, _, err := findKey(ctx, c.client, identityHashes[i], identities[i])
+			if err != nil {
+				fbRedis.ErrorCounter.Inc()
+				return err
+			}
+			countCollision(identityHashes[i], hashes[i], key)
+			hashes[i] = key
+			next = append(next, i)
 		}
-		return nil
+		pending = next
 	}
 
-	return c.retryTx(ctx, tx, fbRedis.KeyCounters())
+	changes := make([]domain.CounterChange, len(requests))
+	for i := range requests {
+		changes[i] = domain.CounterChange{Key: hashes[i], Score: int(scores[i])}
+	}
+	c.afterIncrement(ctx, changes, client)
+	return nil
 }
 
 func (c *cacheCounterRepository) GetCounters(ctx context.Context,
@@ -108,6 +300,205 @@ func (c *cacheCounterRepository) GetCounters(ctx context.Context,
 	return usecase.FromRedisZScoreToMetric(scores.Val()), nil
 }
 
+// rankingKey is the sorted set of a ranking
+func rankingKey(ranking domain.Ranking) string {
+	switch ranking {
+	case domain.RankByClients:
+		return fbRedis.KeyCountersClients()
+	case domain.RankByItems, domain.RankByTime, domain.RankByBytes:
+		return fbRedis.KeyCountersCost(string(ranking))
+	}
+	return fbRedis.KeyCounters()
+}
+
+func (c *cacheCounterRepository) GetRankedCounters(ctx context.Context,
+	ranking domain.Ranking,
+	from, to int64) (domain.MetricCountersScores, error) {
+	scores := c.client.ZRangeWithScores(ctx, rankingKey(ranking), from, to)
+	if scores.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return domain.MetricCountersScores{}, scores.Err()
+	}
+	return usecase.FromRedisZScoreToMetric(scores.Val()), nil
+}
+
+func (c *cacheCounterRepository) GetUniqueClients(ctx context.Context, key string) (int, error) {
+	res := c.client.PFCount(ctx, fbRedis.KeyClients(key))
+	if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return 0, res.Err()
+	}
+	return int(res.Val()), nil
+}
+
+func (c *cacheCounterRepository) GetScore(ctx context.Context, key string) (int, error) {
+	res := c.client.ZScore(ctx, fbRedis.KeyCounters(), key)
+	if res.Err() == redis.Nil {
+		return 0, ErrCacheKeyNotFound
+	} else if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return 0, res.Err()
+	}
+	return int(res.Val()), nil
+}
+
+// costScript adds the costs of each request whose key holds its identity.
+// KEYS[1] items, KEYS[2] time, KEYS[3] bytes rankings, KEYS[4] identities, ARGV:
+// data key prefix, then key, identity, items, seconds and bytes of each request.
+// The result is 1 when added, 0 when the request was never counted and -1 when
+// the key holds another request or data stored before identities.
+var costScript = redis.NewScript(`
+local added = {}
+for i = 2, #ARGV, 5 do
+	local key, identity = ARGV[i], ARGV[i + 1]
+	local stored = redis.call('HGET', KEYS[4], key)
+	local result = -1
+	if stored == identity then
+		redis.call('ZINCRBY', KEYS[1], ARGV[i + 2], key)
+		redis.call('ZINCRBY', KEYS[2], ARGV[i + 3], key)
+		redis.call('ZINCRBY', KEYS[3], ARGV[i + 4], key)
+		result = 1
+	elseif not stored and redis.call('EXISTS', ARGV[1] .. key) == 0 then
+		result = 0
+	end
+	added[#added + 1] = result
+end
+return added
+`)
+
+// AddCosts adds every cost at once with a script, requests whose hash is taken
+// by another one are added again once their key is found.
+func (c *cacheCounterRepository) AddCosts(ctx context.Context,
+	requests []domain.Identifiable,
+	costs []domain.RequestCost) error {
+	identityHashes := make([]string, len(requests))
+	hashes := make([]string, len(requests))
+	identities := make([][]byte, len(requests))
+	for i, request := range requests {
+		hash, identity, _, err := keyAndPayload(c.hasher, request)
+		if err != nil {
+			return err
+		}
+		identityHashes[i], hashes[i], identities[i] = hash, hash, identity
+	}
+
+	keys := []string{
+		rankingKey(domain.RankByItems),
+		rankingKey(domain.RankByTime),
+		rankingKey(domain.RankByBytes),
+		fbRedis.KeyIdentities(),
+	}
+	pending := make([]int, len(requests))
+	for i := range pending {
+		pending[i] = i
+	}
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == maxProbes {
+			return ErrTooManyCollisions
+		}
+		args := make([]interface{}, 0, 1+5*len(pending))
+		args = append(args, fbRedis.KeyData(""))
+		for _, i := range pending {
+			args = append(args, hashes[i], identities[i], costs[i].Items, costs[i].Duration.Seconds(), costs[i].Bytes)
+		}
+		res, err := costScript.Run(ctx, c.client, keys, args...).Int64Slice()
+		if err != nil {
+			fbRedis.ErrorCounter.Inc()
+			return err
+		}
+
+		// Requests never counted are skipped, the others go on with their own key
+		next := pending[:0]
+		for j, i := range pending {
+			if res[j] >= 0 {
+				continue
+			}
+			key, exists, err := findKey(ctx, c.client, identityHashes[i], identities[i])
+			if err != nil {
+				fbRedis.ErrorCounter.Inc()
+				return err
+			}
+			countCollision(identityHashes[i], hashes[i], key)
+			hashes[i] = key
+			if exists {
+				next = append(next, i)
+			}
+		}
+		pending = next
+	}
+	return nil
+}
+
+func (c *cacheCounterRepository) GetCost(ctx context.Context, key string) (domain.MetricCost, error) {
+	var items, seconds, bytes *redis.FloatCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		items = pipe.ZScore(ctx, rankingKey(domain.RankByItems), key)
+		seconds = pipe.ZScore(ctx, rankingKey(domain.RankByTime), key)
+		bytes = pipe.ZScore(ctx, rankingKey(domain.RankByBytes), key)
+		return nil
+	})
+	// No cost recorded yet
+	if err != nil && err != redis.Nil {
+		fbRedis.ErrorCounter.Inc()
+		return domain.MetricCost{}, err
+	}
+	return domain.MetricCost{
+		Items:       int(items.Val()),
+		TimeSeconds: seconds.Val(),
+		Bytes:       int(bytes.Val()),
+	}, nil
+}
+
+func (c *cacheCounterRepository) Lookup(ctx context.Context,
+	request domain.Identifiable,
+	ranking domain.Ranking) (*domain.MetricLookup, error) {
+	hash, identity, _, err := keyAndPayload(c.hasher, request)
+	if err != nil {
+		return nil, err
+	}
+	key, exists, err := findKey(ctx, c.client, hash, identity)
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrCacheKeyNotFound
+	}
+
+	var score *redis.FloatCmd
+	var rank, total, clients *redis.IntCmd
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		score = pipe.ZScore(ctx, fbRedis.KeyCounters(), key)
+		rank = pipe.ZRevRank(ctx, rankingKey(ranking), key)
+		total = pipe.ZCard(ctx, rankingKey(ranking))
+		clients = pipe.PFCount(ctx, fbRedis.KeyClients(key))
+		return nil
+	})
+	// The data may outlive its counter for a moment while compacting, or it may
+	// not be ranked yet
+	if err == redis.Nil {
+		return nil, ErrCacheKeyNotFound
+	} else if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+
+	cost, err := c.GetCost(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.MetricLookup{
+		Key:           key,
+		Score:         int(score.Val()),
+		Cost:          cost,
+		UniqueClients: int(clients.Val()),
+		Rank:          int(rank.Val()) + 1,
+		Percentile:    100 * float64(total.Val()-rank.Val()) / float64(total.Val()),
+		Total:         int(total.Val()),
+	}, nil
+}
+
 func (c *cacheCounterRepository) GetData(ctx context.Context,
 	key string) (string, error) {
 	res := c.client.Get(ctx, fbRedis.KeyData(key))
@@ -7,8 +7,10 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
@@ -34,7 +36,7 @@ func (suite *CacheCounterRepositorySuite) SetupTest() {
 	suite.Require().NoError(suite.redisClient.Ping(context.Background()).Err())
 
 	suite.logger = zap.NewExample()
-	suite.ccRepo = NewCacheCounterRepository(suite.redisClient, suite.logger)
+	suite.ccRepo = NewCacheCounterRepository(suite.redisClient, usecase.XXHasher{}, suite.logger)
 
 }
 
@@ -47,7 +49,7 @@ func (suite *CacheCounterRepositorySuite) TestIncrementRequest() {
 	tests := []struct {
 		name      string
 		nbRequest int
-		request   domain.ToBytes
+		request   domain.Identifiable
 	}{
 		{
 			name:      "Simple Increment",
@@ -76,11 +78,11 @@ func (suite *CacheCounterRepositorySuite) TestIncrementRequest() {
 	for _, test := range tests {
 		suite.Run(test.name, func() {
 			for i := 0; i < test.nbRequest; i++ {
-				err := suite.ccRepo.IncrementRequest(context.Background(), test.request)
+				err := suite.ccRepo.IncrementRequest(context.Background(), test.request, "")
 				suite.Require().NoError(err)
 			}
 
-			hash, err := usecase.GetHash(test.request.ToBytes())
+			hash, err := usecase.GetIdentityHash(usecase.XXHasher{}, test.request)
 			suite.Require().NoError(err)
 			val, err := suite.redisServer.ZScore(fbRedis.KeyCounters(), hash)
 			suite.Require().NoError(err)
@@ -92,10 +94,70 @@ func (suite *CacheCounterRepositorySuite) TestIncrementRequest() {
 	}
 }
 
+func (suite *CacheCounterRepositorySuite) TestIncrementRequests() {
+	three := &domain.FizzBuzzRequest{
+		FstModulo: 3,
+		SndModulo: 5,
+		Limit:     10,
+		FstStr:    "three",
+		SndStr:    "five",
+	}
+	four := &domain.FizzBuzzRequest{
+		FstModulo: 4,
+		SndModulo: 5,
+		Limit:     10,
+		FstStr:    "four",
+		SndStr:    "five",
+	}
+
+	// Existing counters must be incremented along the new ones
+	suite.Require().NoError(suite.ccRepo.IncrementRequest(context.Background(), three, ""))
+	err := suite.ccRepo.IncrementRequests(context.Background(), []domain.Identifiable{three, four, three}, "")
+	suite.Require().NoError(err)
+
+	for request, expected := range map[*domain.FizzBuzzRequest]float64{three: 3, four: 1} {
+		hash, err := usecase.GetIdentityHash(usecase.XXHasher{}, request)
+		suite.Require().NoError(err)
+		val, err := suite.redisServer.ZScore(fbRedis.KeyCounters(), hash)
+		suite.Require().NoError(err)
+		suite.EqualValues(expected, val)
+
+		payload, err := suite.ccRepo.GetData(context.Background(), hash)
+		suite.Require().NoError(err)
+		data, err := request.ToBytes()
+		suite.Require().NoError(err)
+		suite.Equal(string(data), payload)
+	}
+	suite.cleanRedis("IncrementRequests")
+}
+
+func (suite *CacheCounterRepositorySuite) TestSubscribeChanges() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := suite.ccRepo.SubscribeChanges(ctx)
+	suite.Require().NoError(err)
+
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "three", SndStr: "five"}
+	hash, err := usecase.GetIdentityHash(usecase.XXHasher{}, request)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.ccRepo.IncrementRequest(context.Background(), request, ""))
+	suite.Equal([]domain.CounterChange{{Key: hash, Score: 1}}, <-changes)
+
+	err = suite.ccRepo.IncrementRequests(context.Background(), []domain.Identifiable{request, request}, "")
+	suite.Require().NoError(err)
+	suite.Equal([]domain.CounterChange{{Key: hash, Score: 2}, {Key: hash, Score: 3}}, <-changes)
+
+	cancel()
+	for range changes {
+	}
+	suite.cleanRedis("SubscribeChanges")
+}
+
 func (suite *CacheCounterRepositorySuite) TestValidData() {
 	tests := []struct {
 		name    string
-		request domain.ToBytes
+		request domain.Identifiable
 	}{
 		{
 			name: "Get payload and check validity",
@@ -112,10 +174,10 @@ func (suite *CacheCounterRepositorySuite) TestValidData() {
 	for _, test := range tests {
 		suite.Run(test.name, func() {
 			// Should not call increment request
-			err := suite.ccRepo.IncrementRequest(context.Background(), test.request)
+			err := suite.ccRepo.IncrementRequest(context.Background(), test.request, "")
 			suite.Require().NoError(err)
 
-			hash, err := usecase.GetHash(test.request.ToBytes())
+			hash, err := usecase.GetIdentityHash(usecase.XXHasher{}, test.request)
 			suite.Require().NoError(err)
 
 			payload, err := suite.ccRepo.GetData(context.Background(), hash)
@@ -141,7 +203,7 @@ func (suite *CacheCounterRepositorySuite) TestCounters() {
 		name             string
 		from             int64
 		to               int64
-		requests         []domain.ToBytes
+		requests         []domain.Identifiable
 		expectedCounters int
 		ranking          []int
 	}{
@@ -149,7 +211,7 @@ func (suite *CacheCounterRepositorySuite) TestCounters() {
 			name: "Top 2",
 			from: 0,
 			to:   1,
-			requests: []domain.ToBytes{
+			requests: []domain.Identifiable{
 				&domain.FizzBuzzRequest{
 					FstModulo: 3,
 					SndModulo: 5,
@@ -179,7 +241,7 @@ func (suite *CacheCounterRepositorySuite) TestCounters() {
 			name: "Top 1",
 			from: -1,
 			to:   -1,
-			requests: []domain.ToBytes{
+			requests: []domain.Identifiable{
 				&domain.FizzBuzzRequest{
 					FstModulo: 3,
 					SndModulo: 5,
@@ -210,7 +272,7 @@ func (suite *CacheCounterRepositorySuite) TestCounters() {
 	for _, test := range tests {
 		suite.Run(test.name, func() {
 			for i := 0; i < len(test.requests); i++ {
-				err := suite.ccRepo.IncrementRequest(context.Background(), test.requests[i])
+				err := suite.ccRepo.IncrementRequest(context.Background(), test.requests[i], "")
 				suite.Require().NoError(err)
 			}
 
@@ -226,6 +288,185 @@ func (suite *CacheCounterRepositorySuite) TestCounters() {
 	}
 }
 
+func (suite *CacheCounterRepositorySuite) TestUniqueClients() {
+	defer suite.cleanRedis("TestUniqueClients")
+	ctx := context.Background()
+	looped := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "fizz", SndStr: "buzz"}
+	shared := &domain.FizzBuzzRequest{FstModulo: 2, SndModulo: 7, Limit: 10, FstStr: "fizz", SndStr: "buzz"}
+
+	for i := 0; i < 5; i++ {
+		suite.Require().NoError(suite.ccRepo.IncrementRequest(ctx, looped, "script"))
+	}
+	suite.Require().NoError(suite.ccRepo.IncrementRequest(ctx, shared, "alice"))
+	suite.Require().NoError(suite.ccRepo.IncrementRequests(ctx, []domain.Identifiable{shared, shared}, "bob"))
+	// Anonymous increments are not counted as clients
+	suite.Require().NoError(suite.ccRepo.IncrementRequest(ctx, shared, ""))
+
+	loopedHash, err := usecase.GetIdentityHash(usecase.XXHasher{}, looped)
+	suite.Require().NoError(err)
+	sharedHash, err := usecase.GetIdentityHash(usecase.XXHasher{}, shared)
+	suite.Require().NoError(err)
+
+	clients, err := suite.ccRepo.GetUniqueClients(ctx, loopedHash)
+	suite.Require().NoError(err)
+	suite.Equal(1, clients)
+	clients, err = suite.ccRepo.GetUniqueClients(ctx, sharedHash)
+	suite.Require().NoError(err)
+	suite.Equal(2, clients)
+
+	top, err := suite.ccRepo.GetCounters(ctx, -1, -1)
+	suite.Require().NoError(err)
+	suite.Equal(loopedHash, top[0].Key)
+	top, err = suite.ccRepo.GetRankedCounters(ctx, domain.RankByClients, -1, -1)
+	suite.Require().NoError(err)
+	suite.Equal(domain.MetricCountersScores{{Key: sharedHash, ScoreCounter: 2}}, top)
+
+	score, err := suite.ccRepo.GetScore(ctx, sharedHash)
+	suite.Require().NoError(err)
+	suite.Equal(4, score)
+}
+
+func (suite *CacheCounterRepositorySuite) TestLookup() {
+	defer suite.cleanRedis("TestLookup")
+	ctx := context.Background()
+	requests := []*domain.FizzBuzzRequest{
+		{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "a", SndStr: "b"},
+		{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "c", SndStr: "d"},
+		{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "e", SndStr: "f"},
+		{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "g", SndStr: "h"},
+	}
+	for i, request := range requests {
+		for n := 0; n <= i; n++ {
+			suite.Require().NoError(suite.ccRepo.IncrementRequest(ctx, request, "client"))
+		}
+	}
+
+	hash, err := usecase.GetIdentityHash(usecase.XXHasher{}, requests[2])
+	suite.Require().NoError(err)
+	lookup, err := suite.ccRepo.Lookup(ctx, requests[2], domain.RankByHits)
+	suite.Require().NoError(err)
+	suite.Equal(&domain.MetricLookup{Key: hash, Score: 3, UniqueClients: 1, Rank: 2, Percentile: 75, Total: 4}, lookup)
+
+	lookup, err = suite.ccRepo.Lookup(ctx, requests[3], domain.RankByHits)
+	suite.Require().NoError(err)
+	suite.Equal(1, lookup.Rank)
+	suite.Equal(float64(100), lookup.Percentile)
+
+	_, err = suite.ccRepo.Lookup(ctx, &domain.FizzBuzzRequest{FstModulo: 2, SndModulo: 5, Limit: 10, FstStr: "a", SndStr: "b"}, domain.RankByHits)
+	suite.ErrorIs(err, ErrCacheKeyNotFound)
+}
+
+func (suite *CacheCounterRepositorySuite) TestCosts() {
+	defer suite.cleanRedis("TestCosts")
+	ctx := context.Background()
+	small := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: "fizz", SndStr: "buzz"}
+	big := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10_000, FstStr: "fizz", SndStr: "buzz"}
+	never := &domain.FizzBuzzRequest{FstModulo: 2, SndModulo: 5, Limit: 10, FstStr: "fizz", SndStr: "buzz"}
+	for i := 0; i < 3; i++ {
+		suite.Require().NoError(suite.ccRepo.IncrementRequest(ctx, small, ""))
+	}
+	suite.Require().NoError(suite.ccRepo.IncrementRequest(ctx, big, ""))
+
+	suite.Require().NoError(suite.ccRepo.AddCosts(ctx, []domain.Identifiable{small, small, big, never}, []domain.RequestCost{
+		{Items: 15, Duration: time.Millisecond, Bytes: 100},
+		{Items: 15, Duration: time.Millisecond, Bytes: 100},
+		{Items: 10_000, Duration: time.Second, Bytes: 80_000},
+		{Items: 10, Duration: time.Millisecond, Bytes: 50},
+	}))
+
+	bigHash, err := usecase.GetIdentityHash(usecase.XXHasher{}, big)
+	suite.Require().NoError(err)
+	smallHash, err := usecase.GetIdentityHash(usecase.XXHasher{}, small)
+	suite.Require().NoError(err)
+
+	top, err := suite.ccRepo.GetCounters(ctx, -1, -1)
+	suite.Require().NoError(err)
+	suite.Equal(smallHash, top[0].Key)
+	for _, ranking := range []domain.Ranking{domain.RankByItems, domain.RankByTime, domain.RankByBytes} {
+		top, err = suite.ccRepo.GetRankedCounters(ctx, ranking, 0, -1)
+		suite.Require().NoError(err)
+		// Requests never counted have no cost
+		suite.Len(top, 2)
+		suite.Equal(bigHash, top[1].Key, ranking)
+	}
+
+	cost, err := suite.ccRepo.GetCost(ctx, smallHash)
+	suite.Require().NoError(err)
+	suite.Equal(domain.MetricCost{Items: 30, TimeSeconds: 0.002, Bytes: 200}, cost)
+
+	lookup, err := suite.ccRepo.Lookup(ctx, small, domain.RankByBytes)
+	suite.Require().NoError(err)
+	suite.Equal(3, lookup.Score)
+	suite.Equal(2, lookup.Rank)
+}
+
+// constantHasher makes every request collide
+type constantHasher struct{}
+
+func (constantHasher) Name() string { return "constant" }
+
+func (constantHasher) Hash([]byte) (string, error) { return "abc", nil }
+
+func (suite *CacheCounterRepositorySuite) TestHashCollision() {
+	defer suite.cleanRedis("TestHashCollision")
+	repo := NewCacheCounterRepository(suite.redisClient, constantHasher{}, suite.logger)
+	fst := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "fizz", SndStr: "buzz"}
+	snd := &domain.FizzBuzzRequest{FstModulo: 2, SndModulo: 7, Limit: 10, FstStr: "fizz", SndStr: "buzz"}
+	thd := &domain.FizzBuzzRequest{FstModulo: 4, SndModulo: 9, Limit: 10, FstStr: "fizz", SndStr: "buzz"}
+
+	ctx := context.Background()
+	collisions := testutil.ToFloat64(HashCollisionCounter)
+	suite.Require().NoError(repo.IncrementRequest(ctx, fst, ""))
+	suite.Require().NoError(repo.IncrementRequest(ctx, snd, ""))
+	suite.Require().NoError(repo.IncrementRequest(ctx, fst, ""))
+	suite.Require().NoError(repo.IncrementRequests(ctx, []domain.Identifiable{snd, thd, fst}, ""))
+	// Each request moved away from its hash is counted, snd twice and thd once
+	suite.Equal(collisions+3, testutil.ToFloat64(HashCollisionCounter))
+	suite.Require().NoError(repo.AddCosts(ctx, []domain.Identifiable{thd}, []domain.RequestCost{{Items: 10}}))
+	suite.Equal(collisions+4, testutil.ToFloat64(HashCollisionCounter))
+
+	for key, expected := range map[string]struct {
+		request *domain.FizzBuzzRequest
+		score   float64
+	}{
+		"abc":   {fst, 3},
+		"abc-1": {snd, 2},
+		"abc-2": {thd, 1},
+	} {
+		data, err := repo.GetData(ctx, key)
+		suite.Require().NoError(err)
+		suite.Equal(expected.request, domain.FromStrToRequestFB(data), key)
+		score, err := suite.redisClient.ZScore(ctx, fbRedis.KeyCounters(), key).Result()
+		suite.Require().NoError(err)
+		suite.Equal(expected.score, score, key)
+	}
+}
+
+// Counters are shared by identity, whatever the layout the payload was stored with
+func (suite *CacheCounterRepositorySuite) TestPayloadLayoutChange() {
+	defer suite.cleanRedis("TestPayloadLayoutChange")
+	repo := NewCacheCounterRepository(suite.redisClient, constantHasher{}, suite.logger)
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "fizz", SndStr: "buzz"}
+
+	ctx := context.Background()
+	legacy := `{"snd_str":"buzz","fst_str":"fizz","limit":10,"snd_mod":5,"fst_mod":3}`
+	suite.Require().NoError(suite.redisClient.Set(ctx, fbRedis.KeyData("abc"), legacy, 0).Err())
+	suite.Require().NoError(suite.redisClient.ZAdd(ctx, fbRedis.KeyCounters(), redis.Z{Score: 2, Member: "abc"}).Err())
+
+	suite.Require().NoError(repo.IncrementRequests(ctx, []domain.Identifiable{request}, ""))
+	suite.Require().NoError(repo.IncrementRequest(ctx, request, ""))
+	score, err := suite.redisClient.ZScore(ctx, fbRedis.KeyCounters(), "abc").Result()
+	suite.Require().NoError(err)
+	suite.Equal(float64(4), score)
+	suite.False(suite.redisServer.Exists(fbRedis.KeyData("abc-1")))
+
+	identity, err := request.Identity()
+	suite.Require().NoError(err)
+	stored, err := suite.redisClient.HGet(ctx, fbRedis.KeyIdentities(), "abc").Result()
+	suite.Require().NoError(err)
+	suite.Equal(string(identity), stored)
+}
+
 func TestCacheCounterRepositorySuite(t *testing.T) {
 	suite.Run(t, new(CacheCounterRepositorySuite))
 }
@@ -0,0 +1,128 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/capture.go -source capture.go
+
+import (
+	"FizzBuzz/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrCaptureClosed = errors.New("capture file is closed")
+)
+
+type CaptureRepository interface {
+	Append(record domain.CapturedRequest) error
+	Close() error
+}
+
+type fileCaptureRepository struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	logger     *zap.Logger
+}
+
+// NewFileCaptureRepository appends records as JSON lines to path. Once the file
+// would grow over maxSize bytes it is rotated to path.1, path.2, ... keeping at
+// most maxBackups old files.
+func NewFileCaptureRepository(path string, maxSize int64, maxBackups int,
+	logger *zap.Logger) (CaptureRepository, error) {
+	fcr := &fileCaptureRepository{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		logger:     logger,
+	}
+	if err := fcr.open(); err != nil {
+		return nil, err
+	}
+	return fcr, nil
+}
+
+func (f *fileCaptureRepository) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("impossible to open capture file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *fileCaptureRepository) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.maxBackups < 1 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return f.open()
+	}
+
+	// Shift path.N-1 to path.N, the oldest one being overwritten.
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", f.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+
+	f.logger.Debug("Capture file rotated", zap.String("path", f.path))
+	return f.open()
+}
+
+func (f *fileCaptureRepository) Append(record domain.CapturedRequest) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return ErrCaptureClosed
+	}
+
+	if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+func (f *fileCaptureRepository) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type FileCaptureRepositorySuite struct {
+	suite.Suite
+	dir    string
+	logger *zap.Logger
+}
+
+func (suite *FileCaptureRepositorySuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.logger = zap.NewExample()
+}
+
+func (suite *FileCaptureRepositorySuite) readLines(path string) []domain.CapturedRequest {
+	file, err := os.Open(path)
+	suite.Require().NoError(err)
+	defer file.Close()
+
+	var records []domain.CapturedRequest
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record domain.CapturedRequest
+		suite.Require().NoError(json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	suite.Require().NoError(scanner.Err())
+	return records
+}
+
+func (suite *FileCaptureRepositorySuite) TestAppend() {
+	path := filepath.Join(suite.dir, "capture.jsonl")
+	repo, err := NewFileCaptureRepository(path, 1<<20, 2, suite.logger)
+	suite.Require().NoError(err)
+
+	record := domain.CapturedRequest{
+		RequestID: "abc",
+		Title:     "POST /fizzbuzz",
+		Body:      `{"limit":15}`,
+		Timestamp: time.Now().UTC(),
+		Client:    "hash",
+		Status:    200,
+	}
+	suite.Require().NoError(repo.Append(record))
+	suite.Require().NoError(repo.Close())
+
+	records := suite.readLines(path)
+	suite.Require().Len(records, 1)
+	suite.Equal(record.RequestID, records[0].RequestID)
+	suite.Equal(record.Body, records[0].Body)
+	suite.Equal(record.Status, records[0].Status)
+
+	suite.ErrorIs(repo.Append(record), ErrCaptureClosed)
+}
+
+func (suite *FileCaptureRepositorySuite) TestRotation() {
+	path := filepath.Join(suite.dir, "capture.jsonl")
+	// Small enough so each record lands in its own file
+	repo, err := NewFileCaptureRepository(path, 10, 2, suite.logger)
+	suite.Require().NoError(err)
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		suite.Require().NoError(repo.Append(domain.CapturedRequest{RequestID: id}))
+	}
+	suite.Require().NoError(repo.Close())
+
+	suite.Equal("4", suite.readLines(path)[0].RequestID)
+	suite.Equal("3", suite.readLines(path + ".1")[0].RequestID)
+	suite.Equal("2", suite.readLines(path + ".2")[0].RequestID)
+	_, err = os.Stat(path + ".3")
+	suite.True(os.IsNotExist(err))
+}
+
+func TestFileCaptureRepositorySuite(t *testing.T) {
+	suite.Run(t, new(FileCaptureRepositorySuite))
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"FizzBuzz"
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrTooManyCollisions = errors.New("too many requests share the same hash")
+
+	HashCollisionCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "counters",
+		Name:      "hash_collisions",
+		Help:      "count requests whose hash was already used by another request",
+	})
+)
+
+// maxProbes is the number of keys tried for requests sharing the same hash
+const maxProbes = 16
+
+// collisionKey is the key of the n-th request colliding on hash, hashes being
+// hexadecimal the suffix can't be mistaken for another hash.
+func collisionKey(hash string, n int) string {
+	if n == 0 {
+		return hash
+	}
+	return hash + "-" + strconv.Itoa(n)
+}
+
+// countCollision counts a request moved away from its hash once, when the key
+// tried is its hash and the key found is another one. Data stored before
+// identities is found at the key tried and isn't a collision.
+func countCollision(hash, tried, found string) {
+	if tried == hash && found != hash {
+		HashCollisionCounter.Inc()
+	}
+}
+
+// storedIdentity returns the identity of the request stored at key. Data stored
+// before identities were kept, or merged from a peer, is decoded to compute it.
+func storedIdentity(ctx context.Context, cmd redis.Cmdable, key string) (identity string, exists bool, err error) {
+	identity, err = cmd.HGet(ctx, fbRedis.KeyIdentities(), key).Result()
+	if err == nil {
+		return identity, true, nil
+	} else if err != redis.Nil {
+		return "", false, err
+	}
+
+	payload, err := cmd.Get(ctx, fbRedis.KeyData(key)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	request := domain.FromStrToRequest(payload)
+	if request == nil {
+		// Can't be shared with any valid request
+		return "", true, nil
+	}
+	decoded, err := request.Identity()
+	if err != nil {
+		return "", true, nil
+	}
+	if err := cmd.HSetNX(ctx, fbRedis.KeyIdentities(), key, decoded).Err(); err != nil {
+		return "", false, err
+	}
+	return string(decoded), true, nil
+}
+
+// findKey returns the key holding the request of identity without creating it,
+// or the key it would be created at when exists is false.
+func findKey(ctx context.Context, cmd redis.Cmdable, hash string, identity []byte) (key string, exists bool, err error) {
+	for n := 0; n < maxProbes; n++ {
+		key = collisionKey(hash, n)
+		stored, exists, err := storedIdentity(ctx, cmd, key)
+		if err != nil {
+			return "", false, err
+		}
+		if !exists {
+			return key, false, nil
+		}
+		if stored == string(identity) {
+			return key, true, nil
+		}
+	}
+	return "", false, ErrTooManyCollisions
+}
@@ -0,0 +1,160 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/compaction.go -source compaction.go
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// compactionBatch is the number of counters checked by a single script call
+const compactionBatch = 500
+
+type CompactionRepository interface {
+	// AcquireLock returns true when the lock was free, it is held for ttl at most
+	AcquireLock(ctx context.Context, token string, ttl time.Duration) (bool, error)
+	// ReleaseLock frees the lock only if it is still held with token
+	ReleaseLock(ctx context.Context, token string) error
+	// Compact removes the counters with a score below minScore or not incremented
+	// since before, along with their data. A zero minScore or before is ignored.
+	Compact(ctx context.Context, minScore int, before time.Time) (int, error)
+}
+
+// releaseScript deletes the lock only when it was not taken by someone else
+// after its expiration. KEYS[1] lock, ARGV[1] token
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// compactScript checks again each candidate, it may have been incremented since
+// it was selected. Increments write the data and the counter together, in a
+// script or a transaction watching the counters, so none is left without data. KEYS[1] counters, KEYS[2] last seen, KEYS[3...] other rankings,
+// ARGV: min score, before, data key prefix, clients key prefix, identities key, replicas key prefix, members...
+// The peer components go too, a merge would bring the counter back otherwise. It returns the pruned
+// counters and the last seen entries cleaned because their counter is gone.
+var compactScript = redis.NewScript(`
+local pruned, cleaned = 0, 0
+for i = 7, #ARGV do
+	local member = ARGV[i]
+	local score = redis.call('ZSCORE', KEYS[1], member)
+	local seen = redis.call('ZSCORE', KEYS[2], member)
+	if not score then
+		-- Rekeyed or already pruned
+		redis.call('ZREM', KEYS[2], member)
+		cleaned = cleaned + 1
+	elseif tonumber(score) < tonumber(ARGV[1]) or (seen and tonumber(seen) < tonumber(ARGV[2])) then
+		redis.call('ZREM', KEYS[1], member)
+		for k = 2, #KEYS do
+			redis.call('ZREM', KEYS[k], member)
+		end
+		redis.call('DEL', ARGV[3] .. member, ARGV[4] .. member, ARGV[6] .. member)
+		redis.call('HDEL', ARGV[5], member)
+		pruned = pruned + 1
+	end
+end
+return {pruned, cleaned}
+`)
+
+type compactionRepository struct {
+	client *redis.Client
+	logger *zap.Logger
+}
+
+func NewCompactionRepository(redisCli *redis.Client, logger *zap.Logger) CompactionRepository {
+	return &compactionRepository{client: redisCli, logger: logger}
+}
+
+func (r *compactionRepository) AcquireLock(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	res := r.client.SetNX(ctx, fbRedis.KeyLock("compaction"), token, ttl)
+	if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return false, res.Err()
+	}
+	return res.Val(), nil
+}
+
+func (r *compactionRepository) ReleaseLock(ctx context.Context, token string) error {
+	err := releaseScript.Run(ctx, r.client, []string{fbRedis.KeyLock("compaction")}, token).Err()
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *compactionRepository) Compact(ctx context.Context, minScore int, before time.Time) (int, error) {
+	var seenBefore int64
+	if !before.IsZero() {
+		seenBefore = before.Unix()
+	}
+
+	pruned := 0
+	if minScore > 0 {
+		n, err := r.compactRange(ctx, fbRedis.KeyCounters(), "("+strconv.Itoa(minScore), minScore, seenBefore)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+	}
+	if seenBefore > 0 {
+		n, err := r.compactRange(ctx, fbRedis.KeyCountersSeen(), "("+strconv.FormatInt(seenBefore, 10), minScore, seenBefore)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+// compactRange prunes the members of key with a score below max by batches,
+// until no candidate is left or none of a batch could be pruned.
+func (r *compactionRepository) compactRange(ctx context.Context,
+	key, max string,
+	minScore int,
+	seenBefore int64) (int, error) {
+	keys := []string{fbRedis.KeyCounters(), fbRedis.KeyCountersSeen(), fbRedis.KeyCountersGlobal(), fbRedis.KeyCountersPeers()}
+	for _, ranking := range domain.Rankings {
+		if ranking != domain.RankByHits {
+			keys = append(keys, rankingKey(ranking))
+		}
+	}
+	pruned := 0
+	for {
+		members, err := r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   max,
+			Count: compactionBatch,
+		}).Result()
+		if err != nil {
+			fbRedis.ErrorCounter.Inc()
+			return pruned, err
+		}
+		if len(members) == 0 {
+			return pruned, nil
+		}
+
+		args := make([]interface{}, 0, 6+len(members))
+		args = append(args, minScore, seenBefore, fbRedis.KeyData(""), fbRedis.KeyClients(""), fbRedis.KeyIdentities(), fbRedis.KeyReplicas(""))
+		for _, member := range members {
+			args = append(args, member)
+		}
+		res, err := compactScript.Run(ctx, r.client, keys, args...).Int64Slice()
+		if err != nil {
+			fbRedis.ErrorCounter.Inc()
+			return pruned, err
+		}
+		pruned += int(res[0])
+		if res[0]+res[1] == 0 || len(members) < compactionBatch {
+			return pruned, nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type CompactionRepositorySuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	redisClient *redis.Client
+	ccRepo      CacheCounterRepository
+	repo        CompactionRepository
+}
+
+func (suite *CompactionRepositorySuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	suite.redisClient = fbRedis.NewRedis(host[0], host[1], "")
+	suite.ccRepo = NewCacheCounterRepository(suite.redisClient, usecase.XXHasher{}, zap.NewExample())
+	suite.repo = NewCompactionRepository(suite.redisClient, zap.NewExample())
+}
+
+func (suite *CompactionRepositorySuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+// increment counts request n times and returns its hash
+func (suite *CompactionRepositorySuite) increment(request *domain.FizzBuzzRequest, n int) string {
+	for i := 0; i < n; i++ {
+		suite.Require().NoError(suite.ccRepo.IncrementRequest(context.Background(), request, ""))
+	}
+	hash, err := usecase.GetIdentityHash(usecase.XXHasher{}, request)
+	suite.Require().NoError(err)
+	return hash
+}
+
+func (suite *CompactionRepositorySuite) TestCompact() {
+	ctx := context.Background()
+	rare := suite.increment(&domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "a", SndStr: "b"}, 1)
+	popular := suite.increment(&domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "c", SndStr: "d"}, 5)
+	stale := suite.increment(&domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "e", SndStr: "f"}, 5)
+	old := float64(time.Now().Add(-48 * time.Hour).Unix())
+	suite.Require().NoError(suite.redisClient.ZAdd(ctx, fbRedis.KeyCountersSeen(), redis.Z{Score: old, Member: stale}).Err())
+	// Components merged from a peer
+	for _, hash := range []string{rare, popular} {
+		suite.Require().NoError(suite.redisClient.HSet(ctx, fbRedis.KeyReplicas(hash), "peer", 1).Err())
+		suite.Require().NoError(suite.redisClient.ZAdd(ctx, fbRedis.KeyCountersPeers(), redis.Z{Score: 1, Member: hash}).Err())
+	}
+
+	pruned, err := suite.repo.Compact(ctx, 2, time.Now().Add(-24*time.Hour))
+	suite.Require().NoError(err)
+	suite.Equal(2, pruned)
+
+	suite.Equal([]string{popular}, suite.redisClient.ZRange(ctx, fbRedis.KeyCounters(), 0, -1).Val())
+	suite.Equal([]string{popular}, suite.redisClient.ZRange(ctx, fbRedis.KeyCountersSeen(), 0, -1).Val())
+	suite.False(suite.redisServer.Exists(fbRedis.KeyData(rare)))
+	suite.False(suite.redisServer.Exists(fbRedis.KeyData(stale)))
+	suite.True(suite.redisServer.Exists(fbRedis.KeyData(popular)))
+	suite.Equal([]string{popular}, suite.redisClient.HKeys(ctx, fbRedis.KeyIdentities()).Val())
+	suite.Equal([]string{popular}, suite.redisClient.ZRange(ctx, fbRedis.KeyCountersPeers(), 0, -1).Val())
+	suite.False(suite.redisServer.Exists(fbRedis.KeyReplicas(rare)))
+	suite.True(suite.redisServer.Exists(fbRedis.KeyReplicas(popular)))
+
+	// Nothing left to prune
+	pruned, err = suite.repo.Compact(ctx, 2, time.Now().Add(-24*time.Hour))
+	suite.Require().NoError(err)
+	suite.Zero(pruned)
+}
+
+// A pruned counter requested again comes back with its data, whatever the increment
+func (suite *CompactionRepositorySuite) TestIncrementAfterCompaction() {
+	ctx := context.Background()
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "a", SndStr: "b"}
+	rare := suite.increment(request, 1)
+	pruned, err := suite.repo.Compact(ctx, 2, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(1, pruned)
+
+	suite.Require().NoError(suite.ccRepo.IncrementRequests(ctx, []domain.Identifiable{request, request}, ""))
+	score, err := suite.ccRepo.GetScore(ctx, rare)
+	suite.Require().NoError(err)
+	suite.Equal(2, score)
+	data, err := suite.ccRepo.GetData(ctx, rare)
+	suite.Require().NoError(err)
+	suite.Equal(*request, *domain.FromStrToRequestFB(data))
+}
+
+func (suite *CompactionRepositorySuite) TestLock() {
+	ctx := context.Background()
+	acquired, err := suite.repo.AcquireLock(ctx, "first", time.Minute)
+	suite.Require().NoError(err)
+	suite.True(acquired)
+
+	acquired, err = suite.repo.AcquireLock(ctx, "second", time.Minute)
+	suite.Require().NoError(err)
+	suite.False(acquired)
+
+	// Only the owner releases the lock
+	suite.Require().NoError(suite.repo.ReleaseLock(ctx, "second"))
+	acquired, err = suite.repo.AcquireLock(ctx, "second", time.Minute)
+	suite.Require().NoError(err)
+	suite.False(acquired)
+
+	suite.Require().NoError(suite.repo.ReleaseLock(ctx, "first"))
+	acquired, err = suite.repo.AcquireLock(ctx, "second", time.Minute)
+	suite.Require().NoError(err)
+	suite.True(acquired)
+}
+
+func TestCompactionRepositorySuite(t *testing.T) {
+	suite.Run(t, new(CompactionRepositorySuite))
+}
@@ -0,0 +1,200 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/job.go -source job.go
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+)
+
+type JobRepository interface {
+	Save(ctx context.Context, job domain.Job) error
+	// SaveIf saves the job only while its stored status is still expected, it
+	// returns false without error when the status changed or the job is gone.
+	SaveIf(ctx context.Context, job domain.Job, expected domain.JobStatus) (bool, error)
+	Get(ctx context.Context, id string) (*domain.Job, error)
+	// Purge removes jobs created before the given time and returns their ids
+	Purge(ctx context.Context, before time.Time) ([]string, error)
+}
+
+type redisJobRepository struct {
+	ttl    time.Duration
+	client *redis.Client
+	logger *zap.Logger
+}
+
+// NewRedisJobRepository keeps jobs in redis, they expire after ttl on their own
+// but still have to be purged to clean their index and their files.
+func NewRedisJobRepository(redisCli *redis.Client, ttl time.Duration, logger *zap.Logger) JobRepository {
+	return &redisJobRepository{client: redisCli, ttl: ttl, logger: logger}
+}
+
+func (r *redisJobRepository) Save(ctx context.Context, job domain.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fbRedis.KeyJob(job.ID), data, r.ttl)
+		pipe.ZAdd(ctx, fbRedis.KeyJobs(), redis.Z{
+			Score:  float64(job.CreatedAt.Unix()),
+			Member: job.ID,
+		})
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *redisJobRepository) SaveIf(ctx context.Context, job domain.Job, expected domain.JobStatus) (bool, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+
+	key := fbRedis.KeyJob(job.ID)
+	saved := false
+	tx := func(tx *redis.Tx) error {
+		stored, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var current domain.Job
+		if err := json.Unmarshal(stored, &current); err != nil {
+			return err
+		}
+		if current.Status != expected {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, r.ttl)
+			return nil
+		})
+		saved = err == nil
+		return err
+	}
+	// Only the worker writes while the status is kept, a concurrent write is a cancel or a purge
+	err = r.client.Watch(ctx, tx, key)
+	if err == redis.TxFailedErr {
+		return false, nil
+	} else if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return false, err
+	}
+	return saved, nil
+}
+
+func (r *redisJobRepository) Get(ctx context.Context, id string) (*domain.Job, error) {
+	res := r.client.Get(ctx, fbRedis.KeyJob(id))
+	if res.Err() == redis.Nil {
+		return nil, ErrJobNotFound
+	} else if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, res.Err()
+	}
+
+	var job domain.Job
+	if err := json.Unmarshal([]byte(res.Val()), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *redisJobRepository) Purge(ctx context.Context, before time.Time) ([]string, error) {
+	ids, err := r.client.ZRangeByScore(ctx, fbRedis.KeyJobs(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(before.Unix(), 10),
+	}).Result()
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		members := make([]interface{}, len(ids))
+		for i, id := range ids {
+			pipe.Del(ctx, fbRedis.KeyJob(id))
+			members[i] = id
+		}
+		pipe.ZRem(ctx, fbRedis.KeyJobs(), members...)
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+	return ids, nil
+}
+
+type memoryJobRepository struct {
+	mu   sync.RWMutex
+	jobs map[string]domain.Job
+}
+
+// NewMemoryJobRepository keeps jobs in the process, they are lost on restart
+func NewMemoryJobRepository() JobRepository {
+	return &memoryJobRepository{jobs: make(map[string]domain.Job)}
+}
+
+func (m *memoryJobRepository) Save(_ context.Context, job domain.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID] = job
+	return nil
+}
+
+func (m *memoryJobRepository) SaveIf(_ context.Context, job domain.Job, expected domain.JobStatus) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.jobs[job.ID]
+	if !ok || current.Status != expected {
+		return false, nil
+	}
+	m.jobs[job.ID] = job
+	return true, nil
+}
+
+func (m *memoryJobRepository) Get(_ context.Context, id string) (*domain.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return &job, nil
+}
+
+func (m *memoryJobRepository) Purge(_ context.Context, before time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := []string{}
+	for id, job := range m.jobs {
+		if job.CreatedAt.Before(before) {
+			ids = append(ids, id)
+			delete(m.jobs, id)
+		}
+	}
+	return ids, nil
+}
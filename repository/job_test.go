@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type JobRepositorySuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	repos       map[string]JobRepository
+}
+
+func (suite *JobRepositorySuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	redisClient := fbRedis.NewRedis(host[0], host[1], "")
+	suite.repos = map[string]JobRepository{
+		"redis":  NewRedisJobRepository(redisClient, time.Hour, zap.NewExample()),
+		"memory": NewMemoryJobRepository(),
+	}
+}
+
+func (suite *JobRepositorySuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+func (suite *JobRepositorySuite) TestSaveGetPurge() {
+	now := time.Now().UTC()
+	old := domain.Job{ID: "old", Status: domain.JobDone, CreatedAt: now.Add(-2 * time.Hour)}
+	recent := domain.Job{ID: "recent", Status: domain.JobRunning, Progress: 10, CreatedAt: now}
+
+	for name, repo := range suite.repos {
+		suite.Run(name, func() {
+			ctx := context.Background()
+			suite.Require().NoError(repo.Save(ctx, old))
+			suite.Require().NoError(repo.Save(ctx, recent))
+
+			job, err := repo.Get(ctx, "recent")
+			suite.Require().NoError(err)
+			suite.Equal(domain.JobRunning, job.Status)
+			suite.Equal(10, job.Progress)
+
+			_, err = repo.Get(ctx, "unknown")
+			suite.ErrorIs(err, ErrJobNotFound)
+
+			ids, err := repo.Purge(ctx, now.Add(-time.Hour))
+			suite.Require().NoError(err)
+			suite.Equal([]string{"old"}, ids)
+
+			_, err = repo.Get(ctx, "old")
+			suite.ErrorIs(err, ErrJobNotFound)
+			_, err = repo.Get(ctx, "recent")
+			suite.NoError(err)
+		})
+	}
+}
+
+func (suite *JobRepositorySuite) TestSaveIf() {
+	running := domain.Job{ID: "job", Status: domain.JobRunning, CreatedAt: time.Now().UTC()}
+
+	for name, repo := range suite.repos {
+		suite.Run(name, func() {
+			ctx := context.Background()
+			saved, err := repo.SaveIf(ctx, running, domain.JobRunning)
+			suite.Require().NoError(err)
+			suite.False(saved, "unknown job")
+
+			suite.Require().NoError(repo.Save(ctx, running))
+			progress := running
+			progress.Progress = 5
+			saved, err = repo.SaveIf(ctx, progress, domain.JobRunning)
+			suite.Require().NoError(err)
+			suite.True(saved)
+
+			cancelled := running
+			cancelled.Status = domain.JobCancelled
+			suite.Require().NoError(repo.Save(ctx, cancelled))
+			done := progress
+			done.Status = domain.JobDone
+			saved, err = repo.SaveIf(ctx, done, domain.JobRunning)
+			suite.Require().NoError(err)
+			suite.False(saved)
+
+			job, err := repo.Get(ctx, "job")
+			suite.Require().NoError(err)
+			suite.Equal(domain.JobCancelled, job.Status)
+		})
+	}
+}
+
+func TestJobRepositorySuite(t *testing.T) {
+	suite.Run(t, new(JobRepositorySuite))
+}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrMigrationInvalidPayload = errors.New("stored payload can't be decoded")
+)
+
+// MigrationReport sums up what a migration of the counters did
+type MigrationReport struct {
+	Scanned  int `json:"scanned"`
+	Migrated int `json:"migrated"`
+	Merged   int `json:"merged"`
+	Invalid  int `json:"invalid"`
+}
+
+type CounterMigrator struct {
+	maxRetry int
+	client   *redis.Client
+	hasher   usecase.Hasher
+	logger   *zap.Logger
+}
+
+func NewCounterMigrator(redisCli *redis.Client, hasher usecase.Hasher, logger *zap.Logger) *CounterMigrator {
+	return &CounterMigrator{client: redisCli, hasher: hasher, logger: logger, maxRetry: 1000}
+}
+
+// MigrateIdentity rekeys every stored request with the hash of its current
+// identity computed by the hasher, moving its score along. Requests which end up
+// with the same identity have their scores summed. Running it again is a no-op.
+func (m *CounterMigrator) MigrateIdentity(ctx context.Context) (MigrationReport, error) {
+	var report MigrationReport
+	prefix := fbRedis.KeyData("")
+	iter := m.client.Scan(ctx, 0, prefix+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		report.Scanned++
+		oldHash := strings.TrimPrefix(iter.Val(), prefix)
+		migrated, merged, err := m.migrateKey(ctx, oldHash)
+		if errors.Is(err, ErrMigrationInvalidPayload) {
+			report.Invalid++
+			m.logger.Warn("Stored request can't be decoded", zap.String("key", iter.Val()))
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		if migrated {
+			report.Migrated++
+		}
+		if merged {
+			report.Merged++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return report, err
+	}
+	return report, nil
+}
+
+func (m *CounterMigrator) migrateKey(ctx context.Context, oldHash string) (migrated, merged bool, err error) {
+	oldKey := fbRedis.KeyData(oldHash)
+	tx := func(tx *redis.Tx) error {
+		migrated, merged = false, false
+		payload, err := tx.Get(ctx, oldKey).Result()
+		if err == redis.Nil {
+			// Migrated by someone else meanwhile
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		request := domain.FromStrToRequest(payload)
+		if request == nil {
+			return ErrMigrationInvalidPayload
+		}
+		hash, identity, data, err := keyAndPayload(m.hasher, request)
+		if err != nil {
+			return err
+		}
+		newHash, exists, err := findKey(ctx, tx, hash, identity)
+		if err != nil {
+			return err
+		}
+		if newHash == oldHash {
+			// The payload is rewritten in the current layout, its identity was maybe never stored
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if payload != string(data) {
+					pipe.Set(ctx, oldKey, data, 0)
+				}
+				pipe.HSet(ctx, fbRedis.KeyIdentities(), oldHash, identity)
+				return nil
+			})
+			return err
+		}
+
+		state, err := readCounterState(ctx, tx, oldHash, newHash)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetNX(ctx, fbRedis.KeyData(newHash), data, 0)
+			pipe.HSetNX(ctx, fbRedis.KeyIdentities(), newHash, identity)
+			pipe.HDel(ctx, fbRedis.KeyIdentities(), oldHash)
+			pipe.Del(ctx, oldKey)
+			state.move(ctx, pipe, oldHash, newHash)
+			return nil
+		})
+		migrated = err == nil
+		merged = migrated && exists
+		if migrated && state.clients {
+			err = updateClientCount(ctx, tx, newHash)
+		}
+		return err
+	}
+
+	for i := 0; i < m.maxRetry; i++ {
+		err = m.client.Watch(ctx, tx, oldKey, fbRedis.KeyCounters(), fbRedis.KeyCountersPeers())
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil && !errors.Is(err, ErrMigrationInvalidPayload) {
+		fbRedis.ErrorCounter.Inc()
+	}
+	return migrated, merged, err
+}
+
+// seriesChunk is a chunk of the time series of a counter
+type seriesChunk struct {
+	key     string
+	buckets map[string]string
+	ttl     time.Duration
+}
+
+// counterState is everything held by a counter apart from its data and identity
+type counterState struct {
+	score    *float64
+	seen     *float64
+	global   *float64
+	costs    map[domain.Ranking]float64
+	clients  bool
+	replicas map[string]int
+	series   []seriesChunk
+}
+
+// zscore is the score of member in key, nil when it has none
+func zscore(ctx context.Context, cmd redis.Cmdable, key, member string) (*float64, error) {
+	score, err := cmd.ZScore(ctx, key, member).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &score, nil
+}
+
+// readCounterState reads the state of the counter at oldHash to be moved to
+// newHash, which may already hold the same request.
+func readCounterState(ctx context.Context, cmd redis.Cmdable, oldHash, newHash string) (*counterState, error) {
+	state := &counterState{costs: make(map[domain.Ranking]float64, 3), replicas: make(map[string]int)}
+	var err error
+	if state.score, err = zscore(ctx, cmd, fbRedis.KeyCounters(), oldHash); err != nil {
+		return nil, err
+	}
+	if state.global, err = zscore(ctx, cmd, fbRedis.KeyCountersGlobal(), oldHash); err != nil {
+		return nil, err
+	}
+	// The last seen is the latest of both counters
+	if state.seen, err = zscore(ctx, cmd, fbRedis.KeyCountersSeen(), oldHash); err != nil {
+		return nil, err
+	}
+	newSeen, err := zscore(ctx, cmd, fbRedis.KeyCountersSeen(), newHash)
+	if err != nil {
+		return nil, err
+	}
+	if state.seen != nil && newSeen != nil && *newSeen > *state.seen {
+		state.seen = newSeen
+	}
+	for _, ranking := range []domain.Ranking{domain.RankByItems, domain.RankByTime, domain.RankByBytes} {
+		cost, err := zscore(ctx, cmd, rankingKey(ranking), oldHash)
+		if err != nil {
+			return nil, err
+		}
+		if cost != nil {
+			state.costs[ranking] = *cost
+		}
+	}
+	hasClients, err := cmd.Exists(ctx, fbRedis.KeyClients(oldHash)).Result()
+	if err != nil {
+		return nil, err
+	}
+	state.clients = hasClients == 1
+
+	// Components of a replica counted both requests apart, they are summed
+	for _, hash := range []string{oldHash, newHash} {
+		components, err := cmd.HGetAll(ctx, fbRedis.KeyReplicas(hash)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for replica, value := range components {
+			if count, err := strconv.Atoi(value); err == nil {
+				state.replicas[replica] += count
+			}
+		}
+	}
+
+	prefix := fbRedis.KeySeriesPrefix(oldHash)
+	iter := cmd.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		buckets, err := cmd.HGetAll(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, err
+		}
+		ttl, err := cmd.PTTL(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, err
+		}
+		state.series = append(state.series, seriesChunk{key: iter.Val(), buckets: buckets, ttl: ttl})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// move queues the commands moving the state from oldHash to newHash
+func (s *counterState) move(ctx context.Context, pipe redis.Pipeliner, oldHash, newHash string) {
+	if s.score != nil {
+		pipe.ZIncrBy(ctx, fbRedis.KeyCounters(), *s.score, newHash)
+		pipe.ZRem(ctx, fbRedis.KeyCounters(), oldHash)
+	}
+	if s.global != nil {
+		pipe.ZIncrBy(ctx, fbRedis.KeyCountersGlobal(), *s.global, newHash)
+		pipe.ZRem(ctx, fbRedis.KeyCountersGlobal(), oldHash)
+	}
+	if s.seen != nil {
+		pipe.ZAdd(ctx, fbRedis.KeyCountersSeen(), redis.Z{Score: *s.seen, Member: newHash})
+		pipe.ZRem(ctx, fbRedis.KeyCountersSeen(), oldHash)
+	}
+	for ranking, cost := range s.costs {
+		pipe.ZIncrBy(ctx, rankingKey(ranking), cost, newHash)
+		pipe.ZRem(ctx, rankingKey(ranking), oldHash)
+	}
+	if s.clients {
+		pipe.PFMerge(ctx, fbRedis.KeyClients(newHash), fbRedis.KeyClients(oldHash))
+		pipe.ZRem(ctx, fbRedis.KeyCountersClients(), oldHash)
+		pipe.Del(ctx, fbRedis.KeyClients(oldHash))
+	}
+	if len(s.replicas) > 0 {
+		sum := 0
+		values := make([]interface{}, 0, 2*len(s.replicas))
+		for replica, count := range s.replicas {
+			values = append(values, replica, count)
+			sum += count
+		}
+		pipe.HSet(ctx, fbRedis.KeyReplicas(newHash), values...)
+		pipe.ZAdd(ctx, fbRedis.KeyCountersPeers(), redis.Z{Score: float64(sum), Member: newHash})
+		pipe.ZRem(ctx, fbRedis.KeyCountersPeers(), oldHash)
+		pipe.Del(ctx, fbRedis.KeyReplicas(oldHash))
+	}
+	prefix := fbRedis.KeySeriesPrefix(oldHash)
+	for _, chunk := range s.series {
+		newKey := fbRedis.KeySeriesPrefix(newHash) + strings.TrimPrefix(chunk.key, prefix)
+		for bucket, value := range chunk.buckets {
+			if count, err := strconv.ParseInt(value, 10, 64); err == nil {
+				pipe.HIncrBy(ctx, newKey, bucket, count)
+			}
+		}
+		if chunk.ttl > 0 {
+			pipe.PExpire(ctx, newKey, chunk.ttl)
+		}
+		pipe.Del(ctx, chunk.key)
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type CounterMigratorSuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	migrator    *CounterMigrator
+}
+
+func (suite *CounterMigratorSuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	suite.migrator = NewCounterMigrator(fbRedis.NewRedis(host[0], host[1], ""), usecase.XXHasher{}, zap.NewExample())
+}
+
+func (suite *CounterMigratorSuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+// storeLegacy stores a request the way it was before identities, hashing its json payload
+func (suite *CounterMigratorSuite) storeLegacy(payload string, score float64) string {
+	hash, err := usecase.GetHash([]byte(payload))
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.redisServer.Set(fbRedis.KeyData(hash), payload))
+	_, err = suite.redisServer.ZAdd(fbRedis.KeyCounters(), score, hash)
+	suite.Require().NoError(err)
+	return hash
+}
+
+func (suite *CounterMigratorSuite) TestMigrateIdentity() {
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: "fizz", SndStr: "buzz"}
+	oldHash := suite.storeLegacy(`{"fst_mod":3,"snd_mod":5,"limit":15,"fst_str":"fizz","snd_str":"buzz"}`, 4)
+	// Same request with another field order, it was counted apart
+	otherHash := suite.storeLegacy(`{"limit":15,"fst_mod":3,"snd_mod":5,"fst_str":"fizz","snd_str":"buzz"}`, 2)
+	suite.storeLegacy(`not a request`, 1)
+	// The rest of the state of both counters
+	chunk := fbRedis.KeySeries(oldHash, "minute", 3)
+	for i, hash := range []string{oldHash, otherHash} {
+		_, err := suite.redisServer.ZAdd(fbRedis.KeyCountersSeen(), float64(100*(2-i)), hash)
+		suite.Require().NoError(err)
+		_, err = suite.redisServer.ZAdd(fbRedis.KeyCountersGlobal(), float64(5-i), hash)
+		suite.Require().NoError(err)
+		suite.redisServer.HSet(fbRedis.KeyReplicas(hash), "peer", strconv.Itoa(2+i))
+		_, err = suite.redisServer.ZAdd(fbRedis.KeyCountersPeers(), float64(2+i), hash)
+		suite.Require().NoError(err)
+		suite.redisServer.HSet(fbRedis.KeySeries(hash, "minute", 3), "10", strconv.Itoa(1+i))
+	}
+	suite.redisServer.SetTTL(chunk, time.Hour)
+
+	report, err := suite.migrator.MigrateIdentity(context.Background())
+	suite.Require().NoError(err)
+	suite.Equal(MigrationReport{Scanned: 3, Migrated: 2, Merged: 1, Invalid: 1}, report)
+
+	newHash, err := usecase.GetIdentityHash(usecase.XXHasher{}, request)
+	suite.Require().NoError(err)
+	score, err := suite.redisServer.ZScore(fbRedis.KeyCounters(), newHash)
+	suite.Require().NoError(err)
+	suite.EqualValues(6, score)
+
+	members, err := suite.redisServer.ZMembers(fbRedis.KeyCounters())
+	suite.Require().NoError(err)
+	for _, hash := range []string{oldHash, otherHash} {
+		suite.False(suite.redisServer.Exists(fbRedis.KeyData(hash)))
+		suite.NotContains(members, hash)
+		for _, key := range []string{fbRedis.KeyCountersSeen(), fbRedis.KeyCountersGlobal(), fbRedis.KeyCountersPeers()} {
+			members, err := suite.redisServer.ZMembers(key)
+			suite.Require().NoError(err)
+			suite.NotContains(members, hash, key)
+		}
+		suite.False(suite.redisServer.Exists(fbRedis.KeyReplicas(hash)))
+		suite.False(suite.redisServer.Exists(fbRedis.KeySeries(hash, "minute", 3)))
+	}
+	seen, err := suite.redisServer.ZScore(fbRedis.KeyCountersSeen(), newHash)
+	suite.Require().NoError(err)
+	suite.EqualValues(200, seen)
+	global, err := suite.redisServer.ZScore(fbRedis.KeyCountersGlobal(), newHash)
+	suite.Require().NoError(err)
+	suite.EqualValues(9, global)
+	peers, err := suite.redisServer.ZScore(fbRedis.KeyCountersPeers(), newHash)
+	suite.Require().NoError(err)
+	suite.EqualValues(5, peers)
+	suite.Equal("5", suite.redisServer.HGet(fbRedis.KeyReplicas(newHash), "peer"))
+	newChunk := fbRedis.KeySeries(newHash, "minute", 3)
+	suite.Equal("3", suite.redisServer.HGet(newChunk, "10"))
+	suite.Positive(suite.redisServer.TTL(newChunk))
+	payload, err := suite.redisServer.Get(fbRedis.KeyData(newHash))
+	suite.Require().NoError(err)
+	suite.Equal(*request, *domain.FromStrToRequestFB(payload))
+
+	// Nothing left to do
+	report, err = suite.migrator.MigrateIdentity(context.Background())
+	suite.Require().NoError(err)
+	suite.Equal(MigrationReport{Scanned: 2, Invalid: 1}, report)
+}
+
+// Term requests are stored with their own identity, they must not be taken for fizzbuzz ones
+func (suite *CounterMigratorSuite) TestMigrateIdentityTermRequest() {
+	request := &domain.TermRequest{FstModulo: 3, SndModulo: 5, FstStr: "fizz", SndStr: "buzz", N: []int{15}}
+	hash, _, payload, err := keyAndPayload(usecase.XXHasher{}, request)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.redisServer.Set(fbRedis.KeyData(hash), string(payload)))
+	_, err = suite.redisServer.ZAdd(fbRedis.KeyCounters(), 3, hash)
+	suite.Require().NoError(err)
+
+	report, err := suite.migrator.MigrateIdentity(context.Background())
+	suite.Require().NoError(err)
+	suite.Equal(MigrationReport{Scanned: 1}, report)
+	suite.True(suite.redisServer.Exists(fbRedis.KeyData(hash)))
+}
+
+func TestCounterMigratorSuite(t *testing.T) {
+	suite.Run(t, new(CounterMigratorSuite))
+}
@@ -46,10 +46,112 @@ func KeyData(hash string) string {
 	return fmt.Sprintf("fizzbuzz/data/%s", hash)
 }
 
+// KeyIdentities holds the identity of the request stored at each data key
+func KeyIdentities() string {
+	return "fizzbuzz/identities"
+}
+
 func KeyCounters() string {
 	return "fizzbuzz/counters"
 }
 
+// KeyCountersSeen holds the last time each counter was incremented
+func KeyCountersSeen() string {
+	return "fizzbuzz/counters/seen"
+}
+
+// KeyClients holds the HyperLogLog of the clients of a counter
+func KeyClients(hash string) string {
+	return fmt.Sprintf("fizzbuzz/clients/%s", hash)
+}
+
+// KeyCountersClients ranks counters by their number of unique clients
+func KeyCountersClients() string {
+	return "fizzbuzz/counters/clients"
+}
+
+func KeyAnalytics(dimension string) string {
+	return fmt.Sprintf("fizzbuzz/analytics/%s", dimension)
+}
+
+func KeyAnalyticsLimits() string {
+	return "fizzbuzz/analytics/histogram/limit"
+}
+
+// KeyCountersCost ranks counters by the sum of a cost: items, time or bytes
+func KeyCountersCost(cost string) string {
+	return fmt.Sprintf("fizzbuzz/counters/cost/%s", cost)
+}
+
+// KeySeries holds the buckets of a chunk of the series of a counter
+func KeySeries(hash, resolution string, chunk int64) string {
+	return fmt.Sprintf("%s%s/%d", KeySeriesPrefix(hash), resolution, chunk)
+}
+
+// KeySeriesPrefix starts the keys of every chunk of the series of a counter
+func KeySeriesPrefix(hash string) string {
+	return fmt.Sprintf("fizzbuzz/series/%s/", hash)
+}
+
+// KeyReplicas holds the components of the peer replicas of a counter
+func KeyReplicas(hash string) string {
+	return fmt.Sprintf("fizzbuzz/replicas/%s", hash)
+}
+
+// KeyCountersPeers ranks counters by the sum of their peer components
+func KeyCountersPeers() string {
+	return "fizzbuzz/counters/peers"
+}
+
+// KeyCountersGlobal ranks counters by their sum over every replica
+func KeyCountersGlobal() string {
+	return "fizzbuzz/counters/global"
+}
+
+func KeyLock(name string) string {
+	return fmt.Sprintf("fizzbuzz/locks/%s", name)
+}
+
+func ChannelCounters() string {
+	return "fizzbuzz/events/counters"
+}
+
+func KeySketch() string {
+	return "fizzbuzz/sketch/counters"
+}
+
+func KeySketchTop() string {
+	return "fizzbuzz/sketch/top"
+}
+
+func KeySketchData(hash string) string {
+	return fmt.Sprintf("fizzbuzz/sketch/data/%s", hash)
+}
+
+func KeyWebhooks() string {
+	return "fizzbuzz/webhooks"
+}
+
+func KeyWebhookClaim(fingerprint string) string {
+	return fmt.Sprintf("fizzbuzz/webhooks/claims/%s", fingerprint)
+}
+
+func KeyWebhookScores(id string) string {
+	return fmt.Sprintf("fizzbuzz/webhooks/scores/%s", id)
+}
+
+func KeyWebhookDeadLetters() string {
+	return "fizzbuzz/webhooks/dead"
+}
+
+func KeyJob(id string) string {
+	return fmt.Sprintf("fizzbuzz/jobs/%s", id)
+}
+
+func KeyJobs() string {
+	return "fizzbuzz/jobs"
+}
+
 func NewRedis(host, port, pwd string) *redis.Client {
 	redisCli := redis.NewClient(&redis.Options{
 		Addr:     net.JoinHostPort(host, port),
@@ -0,0 +1,348 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/replication.go -source replication.go
+
+import (
+	"FizzBuzz"
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var ReplicationConflictCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: FizzBuzz.PrometheusNamespace,
+	Subsystem: "replication",
+	Name:      "conflicts",
+	Help:      "count merged counters whose key holds another request locally",
+})
+
+// replicationBatch is the number of counters read or merged per round trip
+const replicationBatch = 500
+
+// mergeScript keeps the highest value of each peer component of a counter and
+// ranks it by their sum. The component of this replica is its local counter and
+// is never taken from a peer.
+// It returns -1 when the key holds another request and -2 when its data was
+// stored before identities.
+//
+// KEYS[1] peer components, KEYS[2] peers ranking, KEYS[3] data, KEYS[4]
+// identities, KEYS[5] counters, KEYS[6] global ranking
+// ARGV[1] this replica, ARGV[2] key, ARGV[3] data, ARGV[4] identity, then
+// replica and count pairs
+var mergeScript = redis.NewScript(`
+local stored = redis.call('HGET', KEYS[4], ARGV[2])
+if stored and stored ~= ARGV[4] then
+  return -1
+end
+if not stored then
+  if redis.call('EXISTS', KEYS[3]) == 1 then
+    return -2
+  end
+  redis.call('SET', KEYS[3], ARGV[3])
+  redis.call('HSET', KEYS[4], ARGV[2], ARGV[4])
+end
+local changed = 0
+for i = 5, #ARGV, 2 do
+  if ARGV[i] ~= ARGV[1] then
+    local count = tonumber(ARGV[i + 1])
+    local current = tonumber(redis.call('HGET', KEYS[1], ARGV[i]) or '0')
+    if count > current then
+      redis.call('HSET', KEYS[1], ARGV[i], count)
+      changed = 1
+    end
+  end
+end
+if changed == 1 then
+  local sum = 0
+  for _, value in ipairs(redis.call('HVALS', KEYS[1])) do
+    sum = sum + tonumber(value)
+  end
+  redis.call('ZADD', KEYS[2], sum, ARGV[2])
+  local count = tonumber(redis.call('ZSCORE', KEYS[5], ARGV[2]) or '0')
+  redis.call('ZADD', KEYS[6], sum + count, ARGV[2])
+end
+return changed
+`)
+
+type ReplicationRepository interface {
+	// Snapshot returns every counter known by this replica
+	Snapshot(ctx context.Context) (*domain.ReplicaSnapshot, error)
+	// Merge keeps the highest value of each peer component and returns the number of
+	// counters changed, counters whose key holds another request are skipped.
+	Merge(ctx context.Context, snapshot domain.ReplicaSnapshot) (int, error)
+	// Top returns the count counters with the highest sum over every replica
+	Top(ctx context.Context, count int64) ([]domain.GlobalCounter, error)
+	// Rank ranks again every counter by its sum over every replica, increments
+	// and merges keep the ranking up to date afterwards.
+	Rank(ctx context.Context) error
+}
+
+type replicationRepository struct {
+	replica string
+	client  *redis.Client
+	hasher  usecase.Hasher
+	logger  *zap.Logger
+}
+
+// NewReplicationRepository replicates the counters as G-Counters, the component of
+// replica is its local counter so it keeps being incremented as usual.
+func NewReplicationRepository(redisCli *redis.Client,
+	hasher usecase.Hasher,
+	replica string,
+	logger *zap.Logger) ReplicationRepository {
+	return &replicationRepository{replica: replica, client: redisCli, hasher: hasher, logger: logger}
+}
+
+// components reads the G-Counters of keys, locals are the local counters
+func (r *replicationRepository) components(ctx context.Context,
+	keys []string,
+	locals map[string]int) ([]domain.ReplicaCounter, error) {
+	counters := make([]domain.ReplicaCounter, 0, len(keys))
+	for start := 0; start < len(keys); start += replicationBatch {
+		end := start + replicationBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+		peers := make([]*redis.MapStringStringCmd, len(batch))
+		data := make([]*redis.StringCmd, len(batch))
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range batch {
+				peers[i] = pipe.HGetAll(ctx, fbRedis.KeyReplicas(key))
+				data[i] = pipe.Get(ctx, fbRedis.KeyData(key))
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			fbRedis.ErrorCounter.Inc()
+			return nil, err
+		}
+
+		for i, key := range batch {
+			if data[i].Err() != nil {
+				r.logger.Warn("Replicated counter without data", zap.String("key", key))
+				continue
+			}
+			components := make(domain.GCounter, len(peers[i].Val())+1)
+			for replica, value := range peers[i].Val() {
+				if count, err := strconv.Atoi(value); err == nil && replica != r.replica {
+					components[replica] = count
+				}
+			}
+			if local := locals[key]; local > 0 {
+				components[r.replica] = local
+			}
+			counters = append(counters, domain.ReplicaCounter{
+				Key:        key,
+				Data:       data[i].Val(),
+				Components: components,
+			})
+		}
+	}
+	return counters, nil
+}
+
+func (r *replicationRepository) Snapshot(ctx context.Context) (*domain.ReplicaSnapshot, error) {
+	var locals *redis.ZSliceCmd
+	var peers *redis.StringSliceCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		locals = pipe.ZRangeWithScores(ctx, fbRedis.KeyCounters(), 0, -1)
+		peers = pipe.ZRange(ctx, fbRedis.KeyCountersPeers(), 0, -1)
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+
+	scores := make(map[string]int, len(locals.Val()))
+	keys := make([]string, 0, len(locals.Val())+len(peers.Val()))
+	for _, z := range locals.Val() {
+		key := z.Member.(string)
+		scores[key] = int(z.Score)
+		keys = append(keys, key)
+	}
+	for _, key := range peers.Val() {
+		if _, ok := scores[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	counters, err := r.components(ctx, keys, scores)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ReplicaSnapshot{Replica: r.replica, Counters: counters}, nil
+}
+
+// verify returns the identity and data of the counter when its data is a valid
+// request stored at one of the keys of its hash, false otherwise.
+func (r *replicationRepository) verify(counter domain.ReplicaCounter) (identity, data []byte, ok bool) {
+	request := domain.FromStrToRequest(counter.Data)
+	if request == nil {
+		return nil, nil, false
+	}
+	hash, identity, data, err := keyAndPayload(r.hasher, request)
+	if err != nil {
+		return nil, nil, false
+	}
+	for n := 0; n < maxProbes; n++ {
+		if collisionKey(hash, n) == counter.Key {
+			return identity, data, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (r *replicationRepository) Merge(ctx context.Context, snapshot domain.ReplicaSnapshot) (int, error) {
+	if err := mergeScript.Load(ctx, r.client).Err(); err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return 0, err
+	}
+
+	counters := make([]domain.ReplicaCounter, 0, len(snapshot.Counters))
+	identities := make([][]byte, 0, len(snapshot.Counters))
+	payloads := make([][]byte, 0, len(snapshot.Counters))
+	for _, counter := range snapshot.Counters {
+		identity, data, ok := r.verify(counter)
+		if !ok {
+			ReplicationConflictCounter.Inc()
+			r.logger.Warn("Replicated data doesn't match its key",
+				zap.String("key", counter.Key), zap.String("replica", snapshot.Replica))
+			continue
+		}
+		counters = append(counters, counter)
+		identities = append(identities, identity)
+		payloads = append(payloads, data)
+	}
+
+	merged := 0
+	for start := 0; start < len(counters); start += replicationBatch {
+		end := start + replicationBatch
+		if end > len(counters) {
+			end = len(counters)
+		}
+		pending := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			pending = append(pending, i)
+		}
+		// Data stored before identities gets its identity before being merged again
+		for attempt := 0; attempt < 2 && len(pending) > 0; attempt++ {
+			results := make([]*redis.Cmd, len(pending))
+			_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for j, i := range pending {
+					counter := counters[i]
+					keys := []string{
+						fbRedis.KeyReplicas(counter.Key),
+						fbRedis.KeyCountersPeers(),
+						fbRedis.KeyData(counter.Key),
+						fbRedis.KeyIdentities(),
+						fbRedis.KeyCounters(),
+						fbRedis.KeyCountersGlobal(),
+					}
+					args := make([]interface{}, 0, 4+2*len(counter.Components))
+					args = append(args, r.replica, counter.Key, payloads[i], identities[i])
+					for replica, count := range counter.Components {
+						args = append(args, replica, count)
+					}
+					results[j] = mergeScript.EvalSha(ctx, pipe, keys, args...)
+				}
+				return nil
+			})
+			if err != nil {
+				fbRedis.ErrorCounter.Inc()
+				return merged, err
+			}
+
+			next := pending[:0]
+			for j, i := range pending {
+				changed, _ := results[j].Int()
+				if changed == -2 && attempt == 0 {
+					if _, _, err := storedIdentity(ctx, r.client, counters[i].Key); err != nil {
+						fbRedis.ErrorCounter.Inc()
+						return merged, err
+					}
+					next = append(next, i)
+					continue
+				}
+				switch changed {
+				case -1, -2:
+					ReplicationConflictCounter.Inc()
+					r.logger.Warn("Replicated key holds another request",
+						zap.String("key", counters[i].Key), zap.String("replica", snapshot.Replica))
+				case 1:
+					merged++
+				}
+			}
+			pending = next
+		}
+	}
+	return merged, nil
+}
+
+func (r *replicationRepository) Top(ctx context.Context, count int64) ([]domain.GlobalCounter, error) {
+	top, err := r.client.ZRevRangeWithScores(ctx, fbRedis.KeyCountersGlobal(), 0, count-1).Result()
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+
+	keys := make([]string, len(top))
+	for i, z := range top {
+		keys[i] = z.Member.(string)
+	}
+	locals := make([]*redis.FloatCmd, len(keys))
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			locals[i] = pipe.ZScore(ctx, fbRedis.KeyCounters(), key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+	scores := make(map[string]int, len(keys))
+	for i, key := range keys {
+		scores[key] = int(locals[i].Val())
+	}
+
+	counters, err := r.components(ctx, keys, scores)
+	if err != nil {
+		return nil, err
+	}
+	global := make([]domain.GlobalCounter, 0, len(counters))
+	for _, counter := range counters {
+		request := domain.FromStrToRequest(counter.Data)
+		if request == nil {
+			r.logger.Warn("Invalid replicated data", zap.String("key", counter.Key))
+			continue
+		}
+		global = append(global, domain.GlobalCounter{
+			Key:        counter.Key,
+			Score:      counter.Components.Value(),
+			Components: counter.Components,
+			Request:    domain.CountedRequest{Identifiable: request},
+		})
+	}
+	return global, nil
+}
+
+func (r *replicationRepository) Rank(ctx context.Context) error {
+	err := r.client.ZUnionStore(ctx, fbRedis.KeyCountersGlobal(), &redis.ZStore{
+		Keys:      []string{fbRedis.KeyCounters(), fbRedis.KeyCountersPeers()},
+		Aggregate: "SUM",
+	}).Err()
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
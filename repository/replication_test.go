@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// region is an instance with its own redis
+type region struct {
+	server *miniredis.Miniredis
+	cache  CacheCounterRepository
+	repo   ReplicationRepository
+}
+
+type ReplicationRepositorySuite struct {
+	suite.Suite
+	eu, us, asia region
+	requests     []*domain.FizzBuzzRequest
+}
+
+func (suite *ReplicationRepositorySuite) newRegion(replica string) region {
+	server, err := miniredis.Run()
+	suite.Require().NoError(err)
+	host := strings.Split(server.Addr(), ":")
+	client := fbRedis.NewRedis(host[0], host[1], "")
+	return region{
+		server: server,
+		cache:  NewCacheCounterRepository(client, usecase.XXHasher{}, zap.NewExample(), WithGlobalRanking()),
+		repo:   NewReplicationRepository(client, usecase.XXHasher{}, replica, zap.NewExample()),
+	}
+}
+
+func (suite *ReplicationRepositorySuite) SetupTest() {
+	suite.eu, suite.us, suite.asia = suite.newRegion("eu"), suite.newRegion("us"), suite.newRegion("asia")
+	suite.requests = []*domain.FizzBuzzRequest{
+		{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "a", SndStr: "b"},
+		{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "c", SndStr: "d"},
+	}
+}
+
+func (suite *ReplicationRepositorySuite) TearDownTest() {
+	suite.eu.server.Close()
+	suite.us.server.Close()
+	suite.asia.server.Close()
+}
+
+func (suite *ReplicationRepositorySuite) increment(r region, request *domain.FizzBuzzRequest, n int) {
+	for i := 0; i < n; i++ {
+		suite.Require().NoError(r.cache.IncrementRequest(context.Background(), request, ""))
+	}
+}
+
+func (suite *ReplicationRepositorySuite) snapshot(r region) domain.ReplicaSnapshot {
+	snapshot, err := r.repo.Snapshot(context.Background())
+	suite.Require().NoError(err)
+	return *snapshot
+}
+
+func (suite *ReplicationRepositorySuite) merge(r region, snapshot domain.ReplicaSnapshot) int {
+	merged, err := r.repo.Merge(context.Background(), snapshot)
+	suite.Require().NoError(err)
+	return merged
+}
+
+func (suite *ReplicationRepositorySuite) top(r region) []domain.GlobalCounter {
+	top, err := r.repo.Top(context.Background(), 10)
+	suite.Require().NoError(err)
+	return top
+}
+
+func (suite *ReplicationRepositorySuite) TestMergeOrder() {
+	suite.increment(suite.eu, suite.requests[0], 3)
+	suite.increment(suite.us, suite.requests[0], 1)
+	suite.increment(suite.us, suite.requests[1], 5)
+	suite.increment(suite.asia, suite.requests[1], 1)
+	eu, us, asia := suite.snapshot(suite.eu), suite.snapshot(suite.us), suite.snapshot(suite.asia)
+
+	// eu merges us then asia, asia merges eu then us, us merges a relayed snapshot
+	suite.Equal(2, suite.merge(suite.eu, us))
+	suite.Equal(1, suite.merge(suite.eu, asia))
+	suite.merge(suite.asia, eu)
+	suite.merge(suite.asia, us)
+	suite.merge(suite.us, suite.snapshot(suite.eu))
+
+	for _, r := range []region{suite.eu, suite.us, suite.asia} {
+		top := suite.top(r)
+		suite.Require().Len(top, 2)
+		suite.Equal(6, top[0].Score)
+		suite.Equal(domain.GCounter{"us": 5, "asia": 1}, top[0].Components)
+		suite.Equal(suite.requests[1], top[0].Request.FizzBuzz())
+		suite.Equal(4, top[1].Score)
+		suite.Equal(domain.GCounter{"eu": 3, "us": 1}, top[1].Components)
+	}
+
+	// Merging again changes nothing, a stale snapshot doesn't lower counters
+	suite.Equal(0, suite.merge(suite.eu, us))
+	suite.increment(suite.us, suite.requests[0], 2)
+	suite.Equal(1, suite.merge(suite.eu, suite.snapshot(suite.us)))
+	suite.Equal(0, suite.merge(suite.eu, us))
+	suite.Equal(6, suite.top(suite.eu)[0].Score)
+	suite.Equal(6, suite.top(suite.eu)[1].Score)
+}
+
+func (suite *ReplicationRepositorySuite) TestOwnComponentIsLocal() {
+	suite.increment(suite.eu, suite.requests[0], 2)
+	eu := suite.snapshot(suite.eu)
+	eu.Counters[0].Components["eu"] = 10
+
+	// A peer can't change the counter of this replica
+	suite.Equal(0, suite.merge(suite.eu, eu))
+	suite.Equal(2, suite.top(suite.eu)[0].Score)
+}
+
+func (suite *ReplicationRepositorySuite) TestConflict() {
+	suite.increment(suite.eu, suite.requests[0], 1)
+	snapshot := suite.snapshot(suite.eu)
+	snapshot.Replica = "us"
+	snapshot.Counters[0].Data = "{}"
+	snapshot.Counters[0].Components = domain.GCounter{"us": 5}
+
+	suite.Equal(0, suite.merge(suite.eu, snapshot))
+	suite.Equal(1, suite.top(suite.eu)[0].Score)
+}
+
+func (suite *ReplicationRepositorySuite) TestForgedData() {
+	suite.increment(suite.us, suite.requests[0], 1)
+	snapshot := suite.snapshot(suite.us)
+	// Data of another request can't be planted at a key
+	snapshot.Counters[0].Data = `{"fst_mod":2,"snd_mod":5,"limit":10,"fst_str":"a","snd_str":"b"}`
+
+	suite.Equal(0, suite.merge(suite.eu, snapshot))
+	suite.Empty(suite.top(suite.eu))
+	suite.False(suite.eu.server.Exists(fbRedis.KeyData(snapshot.Counters[0].Key)))
+}
+
+func (suite *ReplicationRepositorySuite) TestRank() {
+	// Counters incremented before the global ranking are ranked again
+	host := strings.Split(suite.eu.server.Addr(), ":")
+	cache := NewCacheCounterRepository(fbRedis.NewRedis(host[0], host[1], ""), usecase.XXHasher{}, zap.NewExample())
+	suite.Require().NoError(cache.IncrementRequest(context.Background(), suite.requests[0], ""))
+	suite.Empty(suite.top(suite.eu))
+
+	suite.Require().NoError(suite.eu.repo.Rank(context.Background()))
+	suite.increment(suite.eu, suite.requests[0], 1)
+	suite.Equal(2, suite.top(suite.eu)[0].Score)
+}
+
+func TestReplicationRepositorySuite(t *testing.T) {
+	suite.Run(t, new(ReplicationRepositorySuite))
+}
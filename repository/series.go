@@ -0,0 +1,102 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/series.go -source series.go
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type SeriesRepository interface {
+	// Add counts the keys in the bucket holding at, for every resolution
+	Add(ctx context.Context, keys []string, at time.Time) error
+	// Pipe queues the commands of Add in pipe, to be sent along other commands
+	Pipe(ctx context.Context, pipe redis.Pipeliner, keys []string, at time.Time)
+	// Get returns every bucket of the key from from to to, missing ones are zeros
+	Get(ctx context.Context, key string, resolution domain.SeriesResolution, from, to time.Time) ([]domain.SeriesPoint, error)
+}
+
+type seriesRepository struct {
+	resolutions []domain.SeriesResolution
+	client      *redis.Client
+	logger      *zap.Logger
+}
+
+// NewSeriesRepository keeps a series of each resolution for every key, buckets
+// are dropped after the retention of their resolution.
+func NewSeriesRepository(redisCli *redis.Client,
+	resolutions []domain.SeriesResolution,
+	logger *zap.Logger) SeriesRepository {
+	return &seriesRepository{resolutions: resolutions, client: redisCli, logger: logger}
+}
+
+func seriesKey(key string, resolution domain.SeriesResolution, chunk time.Time) string {
+	return fbRedis.KeySeries(key, resolution.Name, chunk.Unix())
+}
+
+func (s *seriesRepository) Add(ctx context.Context, keys []string, at time.Time) error {
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.Pipe(ctx, pipe, keys, at)
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+func (s *seriesRepository) Pipe(ctx context.Context, pipe redis.Pipeliner, keys []string, at time.Time) {
+	for _, resolution := range s.resolutions {
+		chunk := resolution.ChunkOf(at)
+		field := strconv.FormatInt(resolution.Bucket(at).Unix(), 10)
+		for _, key := range keys {
+			seriesKey := seriesKey(key, resolution, chunk)
+			pipe.HIncrBy(ctx, seriesKey, field, 1)
+			pipe.ExpireAt(ctx, seriesKey, chunk.Add(resolution.Chunk+resolution.Retention))
+		}
+	}
+}
+
+func (s *seriesRepository) Get(ctx context.Context,
+	key string,
+	resolution domain.SeriesResolution,
+	from, to time.Time) ([]domain.SeriesPoint, error) {
+	from, to = resolution.Bucket(from), resolution.Bucket(to)
+	var chunks []*redis.MapStringStringCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for chunk := resolution.ChunkOf(from); !chunk.After(to); chunk = chunk.Add(resolution.Chunk) {
+			chunks = append(chunks, pipe.HGetAll(ctx, seriesKey(key, resolution, chunk)))
+		}
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, err
+	}
+
+	counts := make(map[int64]int)
+	for _, chunk := range chunks {
+		for bucket, count := range chunk.Val() {
+			t, errT := strconv.ParseInt(bucket, 10, 64)
+			n, errN := strconv.Atoi(count)
+			if errT != nil || errN != nil {
+				s.logger.Error("Invalid series bucket", zap.String("bucket", bucket), zap.String("count", count))
+				continue
+			}
+			counts[t] = n
+		}
+	}
+
+	points := make([]domain.SeriesPoint, 0, int(to.Sub(from)/resolution.Step)+1)
+	for bucket := from; !bucket.After(to); bucket = bucket.Add(resolution.Step) {
+		points = append(points, domain.SeriesPoint{Time: bucket.UTC(), Count: counts[bucket.Unix()]})
+	}
+	return points, nil
+}
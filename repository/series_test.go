@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type SeriesRepositorySuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	redisClient *redis.Client
+	repo        SeriesRepository
+	minute      domain.SeriesResolution
+}
+
+func (suite *SeriesRepositorySuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	suite.redisClient = fbRedis.NewRedis(host[0], host[1], "")
+	suite.minute = domain.SeriesMinute
+	suite.minute.Retention = 2 * time.Hour
+	suite.repo = NewSeriesRepository(suite.redisClient, []domain.SeriesResolution{suite.minute}, zap.NewExample())
+}
+
+func (suite *SeriesRepositorySuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+func (suite *SeriesRepositorySuite) TestZeroFilledAcrossChunks() {
+	ctx := context.Background()
+	// The buckets span two chunks
+	start := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Minute)
+	suite.Require().NoError(suite.repo.Add(ctx, []string{"a", "b"}, start.Add(10*time.Second)))
+	suite.Require().NoError(suite.repo.Add(ctx, []string{"a"}, start.Add(30*time.Second)))
+	suite.Require().NoError(suite.repo.Add(ctx, []string{"a"}, start.Add(3*time.Minute)))
+
+	points, err := suite.repo.Get(ctx, "a", suite.minute, start, start.Add(3*time.Minute+59*time.Second))
+	suite.Require().NoError(err)
+	suite.Equal([]domain.SeriesPoint{
+		{Time: start, Count: 2},
+		{Time: start.Add(time.Minute), Count: 0},
+		{Time: start.Add(2 * time.Minute), Count: 0},
+		{Time: start.Add(3 * time.Minute), Count: 1},
+	}, points)
+
+	points, err = suite.repo.Get(ctx, "b", suite.minute, start, start)
+	suite.Require().NoError(err)
+	suite.Equal([]domain.SeriesPoint{{Time: start, Count: 1}}, points)
+}
+
+func (suite *SeriesRepositorySuite) TestRetention() {
+	ctx := context.Background()
+	at := time.Now()
+	suite.Require().NoError(suite.repo.Add(ctx, []string{"a"}, at))
+
+	chunk := suite.minute.ChunkOf(at)
+	expireAt := chunk.Add(suite.minute.Chunk + suite.minute.Retention)
+	ttl := suite.redisServer.TTL(fbRedis.KeySeries("a", suite.minute.Name, chunk.Unix()))
+	suite.InDelta(time.Until(expireAt).Seconds(), ttl.Seconds(), 2)
+}
+
+func TestSeriesRepositorySuite(t *testing.T) {
+	suite.Run(t, new(SeriesRepositorySuite))
+}
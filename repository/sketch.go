@@ -0,0 +1,213 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/sketch.go -source sketch.go
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// SketchRepository counts requests approximately in bounded memory: a Count-Min
+// Sketch estimates every count and only the K heaviest requests are kept with
+// their payload.
+type SketchRepository interface {
+	Add(ctx context.Context, requests []domain.Identifiable) error
+	// Top returns at most count heaviest requests, the heaviest first
+	Top(ctx context.Context, count int64) (domain.MetricCountersScores, error)
+	GetData(ctx context.Context, key string) (string, error)
+}
+
+// sketchScript increments the sketch cells and updates the top K with the new
+// estimate, evicting the lightest request when it is heavier.
+// KEYS[1] sketch, KEYS[2] top K, ARGV: k, member, payload, data key prefix, cells...
+var sketchScript = redis.NewScript(`
+local estimate = nil
+for i = 5, #ARGV do
+	local count = redis.call('HINCRBY', KEYS[1], ARGV[i], 1)
+	if estimate == nil or count < estimate then
+		estimate = count
+	end
+end
+local member = ARGV[2]
+if redis.call('ZSCORE', KEYS[2], member) then
+	redis.call('ZADD', KEYS[2], estimate, member)
+	return estimate
+end
+if redis.call('ZCARD', KEYS[2]) >= tonumber(ARGV[1]) then
+	local lightest = redis.call('ZRANGE', KEYS[2], 0, 0, 'WITHSCORES')
+	if tonumber(lightest[2]) >= estimate then
+		return estimate
+	end
+	redis.call('ZREM', KEYS[2], lightest[1])
+	redis.call('DEL', ARGV[4] .. lightest[1])
+end
+redis.call('ZADD', KEYS[2], estimate, member)
+redis.call('SET', ARGV[4] .. member, ARGV[3])
+return estimate
+`)
+
+type redisSketchRepository struct {
+	sketch usecase.CountMinSketch
+	k      int
+	client *redis.Client
+	hasher usecase.Hasher
+	logger *zap.Logger
+}
+
+func NewRedisSketchRepository(redisCli *redis.Client,
+	sketch usecase.CountMinSketch,
+	k int,
+	hasher usecase.Hasher,
+	logger *zap.Logger) SketchRepository {
+	return &redisSketchRepository{sketch: sketch, k: k, client: redisCli, hasher: hasher, logger: logger}
+}
+
+func (r *redisSketchRepository) Add(ctx context.Context, requests []domain.Identifiable) error {
+	keys := []string{fbRedis.KeySketch(), fbRedis.KeySketchTop()}
+	// Scripts of a pipeline can't fall back from EVALSHA, make sure it is loaded
+	if err := sketchScript.Load(ctx, r.client).Err(); err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, request := range requests {
+			hash, identity, data, err := keyAndPayload(r.hasher, request)
+			if err != nil {
+				return err
+			}
+			cells := r.sketch.Cells(identity)
+			args := make([]interface{}, 0, 4+len(cells))
+			args = append(args, r.k, hash, data, fbRedis.KeySketchData(""))
+			for _, cell := range cells {
+				args = append(args, strconv.Itoa(cell))
+			}
+			sketchScript.EvalSha(ctx, pipe, keys, args...)
+		}
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *redisSketchRepository) Top(ctx context.Context, count int64) (domain.MetricCountersScores, error) {
+	scores := r.client.ZRevRangeWithScores(ctx, fbRedis.KeySketchTop(), 0, count-1)
+	if scores.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return domain.MetricCountersScores{}, scores.Err()
+	}
+	return usecase.FromRedisZScoreToMetric(scores.Val()), nil
+}
+
+func (r *redisSketchRepository) GetData(ctx context.Context, key string) (string, error) {
+	res := r.client.Get(ctx, fbRedis.KeySketchData(key))
+	if res.Err() == redis.Nil {
+		return "", ErrCacheKeyNotFound
+	} else if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return "", res.Err()
+	}
+	return res.Val(), nil
+}
+
+type sketchEntry struct {
+	count   int
+	payload string
+}
+
+// memorySketchRepository counts requests of a single instance
+type memorySketchRepository struct {
+	mu       sync.Mutex
+	sketch   usecase.CountMinSketch
+	k        int
+	counters []int
+	top      map[string]*sketchEntry
+	hasher   usecase.Hasher
+}
+
+func NewMemorySketchRepository(sketch usecase.CountMinSketch, k int, hasher usecase.Hasher) SketchRepository {
+	return &memorySketchRepository{
+		sketch:   sketch,
+		k:        k,
+		counters: make([]int, sketch.Size()),
+		top:      make(map[string]*sketchEntry, k),
+		hasher:   hasher,
+	}
+}
+
+func (m *memorySketchRepository) Add(_ context.Context, requests []domain.Identifiable) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, request := range requests {
+		hash, identity, data, err := keyAndPayload(m.hasher, request)
+		if err != nil {
+			return err
+		}
+
+		estimate := 0
+		for i, cell := range m.sketch.Cells(identity) {
+			m.counters[cell]++
+			if i == 0 || m.counters[cell] < estimate {
+				estimate = m.counters[cell]
+			}
+		}
+
+		if entry, ok := m.top[hash]; ok {
+			entry.count = estimate
+			continue
+		}
+		if len(m.top) >= m.k {
+			lightest := ""
+			for key, entry := range m.top {
+				if lightest == "" || entry.count < m.top[lightest].count {
+					lightest = key
+				}
+			}
+			if m.top[lightest].count >= estimate {
+				continue
+			}
+			delete(m.top, lightest)
+		}
+		m.top[hash] = &sketchEntry{count: estimate, payload: string(data)}
+	}
+	return nil
+}
+
+func (m *memorySketchRepository) Top(_ context.Context, count int64) (domain.MetricCountersScores, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scores := make(domain.MetricCountersScores, 0, len(m.top))
+	for key, entry := range m.top {
+		scores = append(scores, domain.MetricCounterScore{Key: key, ScoreCounter: entry.count})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].ScoreCounter == scores[j].ScoreCounter {
+			return scores[i].Key > scores[j].Key
+		}
+		return scores[i].ScoreCounter > scores[j].ScoreCounter
+	})
+	if int64(len(scores)) > count {
+		scores = scores[:count]
+	}
+	return scores, nil
+}
+
+func (m *memorySketchRepository) GetData(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.top[key]
+	if !ok {
+		return "", ErrCacheKeyNotFound
+	}
+	return entry.payload, nil
+}
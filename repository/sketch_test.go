@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type SketchRepositorySuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	repos       map[string]SketchRepository
+}
+
+func (suite *SketchRepositorySuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+	host := strings.Split(suite.redisServer.Addr(), ":")
+
+	sketch, err := usecase.NewCountMinSketch(0.01, 0.01)
+	suite.Require().NoError(err)
+	suite.repos = map[string]SketchRepository{
+		"redis": NewRedisSketchRepository(fbRedis.NewRedis(host[0], host[1], ""),
+			sketch, 3, usecase.XXHasher{}, zap.NewExample()),
+		"memory": NewMemorySketchRepository(sketch, 3, usecase.XXHasher{}),
+	}
+}
+
+func (suite *SketchRepositorySuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+func sketchRequest(i int) *domain.FizzBuzzRequest {
+	return &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: fmt.Sprintf("fizz%d", i), SndStr: "buzz"}
+}
+
+func (suite *SketchRepositorySuite) TestKeepsHeaviest() {
+	for name, repo := range suite.repos {
+		suite.Run(name, func() {
+			ctx := context.Background()
+			var requests []domain.Identifiable
+			for heavy := 0; heavy < 3; heavy++ {
+				for i := 0; i < 10*(heavy+1); i++ {
+					requests = append(requests, sketchRequest(heavy))
+				}
+			}
+			// Many light requests trying to push the heavy ones out
+			for light := 3; light < 200; light++ {
+				requests = append(requests, sketchRequest(light))
+			}
+			suite.Require().NoError(repo.Add(ctx, requests))
+
+			top, err := repo.Top(ctx, 10)
+			suite.Require().NoError(err)
+			suite.Require().Len(top, 3)
+			for i, heavy := range []int{2, 1, 0} {
+				hash, _, data, err := keyAndPayload(usecase.XXHasher{}, sketchRequest(heavy))
+				suite.Require().NoError(err)
+				suite.Equal(hash, top[i].Key)
+				// Estimates never go below the real count
+				suite.GreaterOrEqual(top[i].ScoreCounter, 10*(heavy+1))
+
+				payload, err := repo.GetData(ctx, hash)
+				suite.Require().NoError(err)
+				suite.Equal(string(data), payload)
+			}
+
+			light, _, _, err := keyAndPayload(usecase.XXHasher{}, sketchRequest(42))
+			suite.Require().NoError(err)
+			_, err = repo.GetData(ctx, light)
+			suite.ErrorIs(err, ErrCacheKeyNotFound)
+		})
+	}
+}
+
+func (suite *SketchRepositorySuite) TestBounds() {
+	_, err := usecase.NewCountMinSketch(0, 0.1)
+	suite.ErrorIs(err, usecase.ErrInvalidSketchBounds)
+	sketch, err := usecase.NewCountMinSketch(0.01, 0.01)
+	suite.Require().NoError(err)
+	suite.Equal(272, sketch.Width)
+	suite.Equal(5, sketch.Depth)
+}
+
+func TestSketchRepositorySuite(t *testing.T) {
+	suite.Run(t, new(SketchRepositorySuite))
+}
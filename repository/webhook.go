@@ -0,0 +1,179 @@
+package repository
+
+//go:generate ../.deps/mockgen -destination mock/webhook.go -source webhook.go
+
+import (
+	"FizzBuzz/domain"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+)
+
+// maxDeadLetters is the number of failed deliveries kept
+const maxDeadLetters = 1000
+
+type WebhookRepository interface {
+	SaveSubscription(ctx context.Context, sub domain.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	// TrackScore records the score of the counter seen by the subscription and returns
+	// the highest one recorded before, known is false when it is the first one.
+	TrackScore(ctx context.Context, id string, key string, score int) (previous int, known bool, err error)
+	// Claim returns true only for the first caller of a fingerprint during ttl,
+	// so an event seen by every instance is only delivered once.
+	Claim(ctx context.Context, fingerprint string, ttl time.Duration) (bool, error)
+	PushDeadLetter(ctx context.Context, delivery domain.WebhookDelivery) error
+	ListDeadLetters(ctx context.Context, count int64) ([]domain.WebhookDelivery, error)
+}
+
+// trackScoreScript keeps the highest score of each counter and returns the previous one
+var trackScoreScript = redis.NewScript(`
+local previous = redis.call('HGET', KEYS[1], ARGV[1])
+if not previous or tonumber(previous) < tonumber(ARGV[2]) then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+return previous
+`)
+
+type webhookRepository struct {
+	client *redis.Client
+	logger *zap.Logger
+}
+
+func NewWebhookRepository(redisCli *redis.Client, logger *zap.Logger) WebhookRepository {
+	return &webhookRepository{client: redisCli, logger: logger}
+}
+
+func (w *webhookRepository) SaveSubscription(ctx context.Context, sub domain.WebhookSubscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	if err := w.client.HSet(ctx, fbRedis.KeyWebhooks(), sub.ID, data).Err(); err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+func (w *webhookRepository) GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	res := w.client.HGet(ctx, fbRedis.KeyWebhooks(), id)
+	if res.Err() == redis.Nil {
+		return nil, ErrWebhookNotFound
+	} else if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, res.Err()
+	}
+
+	var sub domain.WebhookSubscription
+	if err := json.Unmarshal([]byte(res.Val()), &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (w *webhookRepository) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	res := w.client.HGetAll(ctx, fbRedis.KeyWebhooks())
+	if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, res.Err()
+	}
+
+	subs := make([]domain.WebhookSubscription, 0, len(res.Val()))
+	for id, data := range res.Val() {
+		var sub domain.WebhookSubscription
+		if err := json.Unmarshal([]byte(data), &sub); err != nil {
+			w.logger.Error("Invalid webhook subscription", zap.String("id", id), zap.Error(err))
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs, nil
+}
+
+func (w *webhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	var res *redis.IntCmd
+	_, err := w.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		res = pipe.HDel(ctx, fbRedis.KeyWebhooks(), id)
+		pipe.Del(ctx, fbRedis.KeyWebhookScores(id))
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	if res.Val() == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (w *webhookRepository) TrackScore(ctx context.Context, id string, key string, score int) (int, bool, error) {
+	previous, err := trackScoreScript.Run(ctx, w.client, []string{fbRedis.KeyWebhookScores(id)}, key, score).Int()
+	if err == redis.Nil {
+		return 0, false, nil
+	} else if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return 0, false, err
+	}
+	return previous, true, nil
+}
+
+func (w *webhookRepository) Claim(ctx context.Context, fingerprint string, ttl time.Duration) (bool, error) {
+	res := w.client.SetNX(ctx, fbRedis.KeyWebhookClaim(fingerprint), 1, ttl)
+	if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return false, res.Err()
+	}
+	return res.Val(), nil
+}
+
+func (w *webhookRepository) PushDeadLetter(ctx context.Context, delivery domain.WebhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	_, err = w.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, fbRedis.KeyWebhookDeadLetters(), data)
+		pipe.LTrim(ctx, fbRedis.KeyWebhookDeadLetters(), 0, maxDeadLetters-1)
+		return nil
+	})
+	if err != nil {
+		fbRedis.ErrorCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+func (w *webhookRepository) ListDeadLetters(ctx context.Context, count int64) ([]domain.WebhookDelivery, error) {
+	res := w.client.LRange(ctx, fbRedis.KeyWebhookDeadLetters(), 0, count-1)
+	if res.Err() != nil {
+		fbRedis.ErrorCounter.Inc()
+		return nil, res.Err()
+	}
+
+	deliveries := make([]domain.WebhookDelivery, 0, len(res.Val()))
+	for _, data := range res.Val() {
+		var delivery domain.WebhookDelivery
+		if err := json.Unmarshal([]byte(data), &delivery); err != nil {
+			w.logger.Error("Invalid dead letter", zap.Error(err))
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,248 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/analytics_service.go -source analytics_service.go
+
+import (
+	"FizzBuzz"
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	AnalyticsDroppedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "analytics",
+		Name:      "dropped",
+		Help:      "count requests not analysed because the buffer was full",
+	})
+)
+
+// analyticsBatch is the number of requests sent at once to the repository
+const analyticsBatch = 256
+
+type AnalyticsConfig struct {
+	// LimitBuckets are the upper bounds of the limit histogram
+	LimitBuckets []int
+	// Pairs are the fields counted together
+	Pairs [][2]string
+	// Buffer is the number of requests waiting to be analysed before dropping new ones
+	Buffer int
+}
+
+type AnalyticsService interface {
+	// Record queues the requests without ever blocking the caller
+	Record(requests []domain.Identifiable)
+	// Report returns the top values of each field and pair, and the limit histogram
+	Report(top int64) (*domain.AnalyticsReport, error)
+	// Close analyses queued requests
+	Close()
+}
+
+type analyticsService struct {
+	analyticsRepo repository.AnalyticsRepository
+	config        AnalyticsConfig
+	queue         chan domain.AnalyticsSample
+	done          chan struct{}
+	// mu guards the queue against a send once closed
+	mu     sync.RWMutex
+	closed bool
+	logger *zap.Logger
+}
+
+func NewAnalyticsService(analyticsRepo repository.AnalyticsRepository,
+	config AnalyticsConfig,
+	logger *zap.Logger) AnalyticsService {
+	config.LimitBuckets = append([]int(nil), config.LimitBuckets...)
+	sort.Ints(config.LimitBuckets)
+	as := &analyticsService{
+		analyticsRepo: analyticsRepo,
+		config:        config,
+		queue:         make(chan domain.AnalyticsSample, config.Buffer),
+		done:          make(chan struct{}),
+		logger:        logger,
+	}
+	go as.run()
+	return as
+}
+
+// pairDimension names the dimension counting fields together
+func pairDimension(pair [2]string) string {
+	return pair[0] + "+" + pair[1]
+}
+
+func (as *analyticsService) bucket(limit string) string {
+	n, err := strconv.Atoi(limit)
+	if err != nil {
+		return ""
+	}
+	for _, upper := range as.config.LimitBuckets {
+		if n <= upper {
+			return strconv.Itoa(upper)
+		}
+	}
+	return "+Inf"
+}
+
+func (as *analyticsService) sample(fields map[string]string) domain.AnalyticsSample {
+	sample := domain.AnalyticsSample{
+		Members: make(map[string]string, len(fields)+len(as.config.Pairs)),
+		Bucket:  as.bucket(fields["limit"]),
+	}
+	for field, value := range fields {
+		sample.Members[field] = value
+	}
+	for _, pair := range as.config.Pairs {
+		fst, okFst := fields[pair[0]]
+		snd, okSnd := fields[pair[1]]
+		if !okFst || !okSnd {
+			continue
+		}
+		// Values are JSON encoded as they may contain any separator
+		member, err := json.Marshal([]string{fst, snd})
+		if err != nil {
+			continue
+		}
+		sample.Members[pairDimension(pair)] = string(member)
+	}
+	return sample
+}
+
+func (as *analyticsService) Record(requests []domain.Identifiable) {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+	if as.closed {
+		AnalyticsDroppedCounter.Add(float64(len(requests)))
+		return
+	}
+	for _, request := range requests {
+		analysable, ok := request.(domain.Analysable)
+		if !ok {
+			continue
+		}
+		select {
+		case as.queue <- as.sample(analysable.Fields()):
+		default:
+			AnalyticsDroppedCounter.Inc()
+		}
+	}
+}
+
+func (as *analyticsService) run() {
+	defer close(as.done)
+	batch := make([]domain.AnalyticsSample, 0, analyticsBatch)
+	for sample := range as.queue {
+		batch = append(batch[:0], sample)
+		// Take whatever is already waiting
+	drain:
+		for len(batch) < analyticsBatch {
+			select {
+			case sample, ok := <-as.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, sample)
+			default:
+				break drain
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		if err := as.analyticsRepo.Add(ctx, batch); err != nil {
+			as.logger.Error("Failed to record analytics", zap.Int("requests", len(batch)), zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+func (as *analyticsService) Close() {
+	as.mu.Lock()
+	if !as.closed {
+		as.closed = true
+		close(as.queue)
+	}
+	as.mu.Unlock()
+	<-as.done
+}
+
+func (as *analyticsService) Report(top int64) (*domain.AnalyticsReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	report := &domain.AnalyticsReport{
+		Fields: map[string][]domain.AnalyticsValue{},
+		Pairs:  map[string][]domain.AnalyticsPair{},
+	}
+	for field := range (&domain.FizzBuzzRequest{}).Fields() {
+		values, err := as.analyticsRepo.Top(ctx, field, top)
+		if err != nil {
+			return nil, err
+		}
+		report.Fields[field] = values
+	}
+
+	for _, pair := range as.config.Pairs {
+		values, err := as.analyticsRepo.Top(ctx, pairDimension(pair), top)
+		if err != nil {
+			return nil, err
+		}
+		pairs := make([]domain.AnalyticsPair, 0, len(values))
+		for _, value := range values {
+			var members []string
+			if err := json.Unmarshal([]byte(value.Value), &members); err != nil {
+				as.logger.Error("Invalid analytics pair", zap.String("pair", value.Value))
+				continue
+			}
+			pairs = append(pairs, domain.AnalyticsPair{Values: members, Count: value.Count})
+		}
+		report.Pairs[strings.Join(pair[:], ",")] = pairs
+	}
+
+	histogram, err := as.analyticsRepo.Histogram(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Limits = make([]domain.HistogramBucket, 0, len(as.config.LimitBuckets)+1)
+	for _, upper := range as.config.LimitBuckets {
+		bound := strconv.Itoa(upper)
+		report.Limits = append(report.Limits, domain.HistogramBucket{UpperBound: bound, Count: histogram[bound]})
+	}
+	report.Limits = append(report.Limits, domain.HistogramBucket{UpperBound: "+Inf", Count: histogram["+Inf"]})
+	return report, nil
+}
+
+type analyticsMetricService struct {
+	MetricService
+	as AnalyticsService
+}
+
+// NewAnalyticsMetricService records every request counted by ms in the analytics
+func NewAnalyticsMetricService(ms MetricService, as AnalyticsService) MetricService {
+	return &analyticsMetricService{MetricService: ms, as: as}
+}
+
+func (ams *analyticsMetricService) Increment(request domain.Identifiable, client string) error {
+	if err := ams.MetricService.Increment(request, client); err != nil {
+		return err
+	}
+	ams.as.Record([]domain.Identifiable{request})
+	return nil
+}
+
+func (ams *analyticsMetricService) IncrementBatch(requests []domain.Identifiable, client string) error {
+	if err := ams.MetricService.IncrementBatch(requests, client); err != nil {
+		return err
+	}
+	ams.as.Record(requests)
+	return nil
+}
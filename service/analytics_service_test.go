@@ -0,0 +1,63 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	fbRedis "FizzBuzz/repository/redis"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type AnalyticsServiceSuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	as          AnalyticsService
+}
+
+func (suite *AnalyticsServiceSuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	logger := zap.NewExample()
+	suite.as = NewAnalyticsService(repository.NewAnalyticsRepository(fbRedis.NewRedis(host[0], host[1], ""), 100, logger),
+		AnalyticsConfig{
+			LimitBuckets: []int{1000, 10},
+			Pairs:        [][2]string{{"fst_mod", "snd_mod"}},
+			Buffer:       100,
+		}, logger)
+}
+
+func (suite *AnalyticsServiceSuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+func (suite *AnalyticsServiceSuite) TestReport() {
+	suite.as.Record([]domain.Identifiable{
+		&domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 5, FstStr: "fizz", SndStr: "buzz"},
+		&domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 100, FstStr: "fizz", SndStr: "bazz"},
+		&domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 7, Limit: 5000, FstStr: "foo", SndStr: "buzz"},
+	})
+	// Waits for the queued requests
+	suite.as.Close()
+
+	report, err := suite.as.Report(1)
+	suite.Require().NoError(err)
+	suite.Equal([]domain.AnalyticsValue{{Value: "3", Count: 3}}, report.Fields["fst_mod"])
+	suite.Equal([]domain.AnalyticsValue{{Value: "fizz", Count: 2}}, report.Fields["fst_str"])
+	suite.Equal([]domain.AnalyticsPair{{Values: []string{"3", "5"}, Count: 2}}, report.Pairs["fst_mod,snd_mod"])
+	suite.Equal([]domain.HistogramBucket{
+		{UpperBound: "10", Count: 1},
+		{UpperBound: "1000", Count: 1},
+		{UpperBound: "+Inf", Count: 1},
+	}, report.Limits)
+}
+
+func TestAnalyticsServiceSuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsServiceSuite))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type approxMetricService struct {
+	sketchRepo repository.SketchRepository
+	logger     *zap.Logger
+}
+
+// NewApproxMetricService counts requests in bounded memory, counters are
+// estimates which may exceed the real ones within the sketch error bounds.
+// Unique clients are not counted.
+func NewApproxMetricService(sketchRepo repository.SketchRepository,
+	logger *zap.Logger) MetricService {
+	return &approxMetricService{
+		logger:     logger,
+		sketchRepo: sketchRepo,
+	}
+}
+
+func (ms *approxMetricService) Increment(request domain.Identifiable, _ string) error {
+	return ms.sketchRepo.Add(context.Background(), []domain.Identifiable{request})
+}
+
+func (ms *approxMetricService) IncrementBatch(requests []domain.Identifiable, _ string) error {
+	if len(requests) == 0 {
+		return nil
+	}
+	return ms.sketchRepo.Add(context.Background(), requests)
+}
+
+// AddCosts is ignored, only hits are counted
+func (ms *approxMetricService) AddCosts([]domain.Identifiable, []domain.RequestCost) error {
+	return nil
+}
+
+func (ms *approxMetricService) MostRequested(ranking domain.Ranking) (*domain.MetricCountFizzBuzz, error) {
+	if ranking != "" && ranking != domain.RankByHits {
+		return nil, ErrMetricsNotSupported
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	top, err := ms.sketchRepo.Top(ctx, 1)
+	if err != nil {
+		ms.logger.Error("Failed to get top counter", zap.Error(err))
+		return nil, err
+	}
+	if len(top) == 0 {
+		return nil, ErrMetricsNoCountersFound
+	}
+
+	payload, err := ms.sketchRepo.GetData(ctx, top[0].Key)
+	if errors.Is(err, repository.ErrCacheKeyNotFound) {
+		// Evicted meanwhile
+		return nil, ErrMetricsNoDataFound
+	} else if err != nil {
+		ms.logger.Error("Failed to get data", zap.Error(err))
+		return nil, ErrMetricsNoDataFound
+	}
+	request := domain.FromStrToRequest(payload)
+	if request == nil {
+		return nil, ErrMetricsNoRequestFound
+	}
+	return &domain.MetricCountFizzBuzz{
+		Key:     top[0].Key,
+		Score:   top[0].ScoreCounter,
+		Request: domain.CountedRequest{Identifiable: request},
+	}, nil
+}
+
+// Lookup is not supported, requests out of the top K have no rank
+func (ms *approxMetricService) Lookup(domain.FizzBuzzRequest, domain.Ranking) (*domain.MetricLookup, error) {
+	return nil, ErrMetricsNotSupported
+}
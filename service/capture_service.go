@@ -0,0 +1,111 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/capture_service.go -source capture_service.go
+
+import (
+	"FizzBuzz"
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"math/rand"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	CaptureDroppedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "capture",
+		Name:      "dropped",
+		Help:      "count captured requests dropped because the buffer was full",
+	})
+	CaptureWrittenCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "capture",
+		Name:      "written",
+		Help:      "count captured requests written to file",
+	})
+)
+
+type CaptureService interface {
+	// Sample tells if the current request should be captured
+	Sample() bool
+	// Capture queues the record without ever blocking the caller
+	Capture(record domain.CapturedRequest)
+	// Close flushes queued records and closes the capture file
+	Close() error
+}
+
+type captureService struct {
+	captureRepo repository.CaptureRepository
+	rate        float64
+	queue       chan domain.CapturedRequest
+	done        chan struct{}
+	// mu guards the queue against a send once closed
+	mu     sync.RWMutex
+	closed bool
+	logger *zap.Logger
+}
+
+// NewCaptureService samples requests at rate (between 0 and 1) and writes them
+// asynchronously through captureRepo, buffering at most bufferSize records.
+func NewCaptureService(captureRepo repository.CaptureRepository,
+	rate float64,
+	bufferSize int,
+	logger *zap.Logger) CaptureService {
+	cs := &captureService{
+		captureRepo: captureRepo,
+		rate:        rate,
+		queue:       make(chan domain.CapturedRequest, bufferSize),
+		done:        make(chan struct{}),
+		logger:      logger,
+	}
+	go cs.run()
+	return cs
+}
+
+func (cs *captureService) run() {
+	defer close(cs.done)
+	for record := range cs.queue {
+		if err := cs.captureRepo.Append(record); err != nil {
+			cs.logger.Error("Failed to write captured request", zap.Error(err))
+			continue
+		}
+		CaptureWrittenCounter.Inc()
+	}
+}
+
+func (cs *captureService) Sample() bool {
+	if cs.rate <= 0 {
+		return false
+	}
+	//nolint:gosec // sampling does not need a secure source
+	return cs.rate >= 1 || rand.Float64() < cs.rate
+}
+
+func (cs *captureService) Capture(record domain.CapturedRequest) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if cs.closed {
+		CaptureDroppedCounter.Inc()
+		return
+	}
+	select {
+	case cs.queue <- record:
+	default:
+		CaptureDroppedCounter.Inc()
+	}
+}
+
+func (cs *captureService) Close() error {
+	cs.mu.Lock()
+	if !cs.closed {
+		cs.closed = true
+		close(cs.queue)
+	}
+	cs.mu.Unlock()
+	<-cs.done
+	return cs.captureRepo.Close()
+}
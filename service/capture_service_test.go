@@ -0,0 +1,37 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCaptureServiceClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	captureRepo, err := repository.NewFileCaptureRepository(path, 1<<20, 1, zap.NewExample())
+	require.NoError(t, err)
+	cs := NewCaptureService(captureRepo, 1, 16, zap.NewExample())
+
+	for i := 0; i < 3; i++ {
+		cs.Capture(domain.CapturedRequest{Title: "fizzbuzz"})
+	}
+	// Queued records are written before closing
+	require.NoError(t, cs.Close())
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	require.Equal(t, 3, lines)
+
+	// A late record is dropped instead of panicking
+	require.NotPanics(t, func() { cs.Capture(domain.CapturedRequest{Title: "fizzbuzz"}) })
+}
@@ -0,0 +1,111 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/compaction_service.go -source compaction_service.go
+
+import (
+	"FizzBuzz"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	CompactionPrunedHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "compaction",
+		Name:      "pruned",
+		Help:      "counters pruned per compaction run",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	})
+	CompactionRunsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "compaction",
+		Name:      "runs",
+		Help:      "count compaction runs by result",
+	}, []string{"result"})
+)
+
+type CompactionConfig struct {
+	Interval time.Duration
+	// MinScore prunes counters requested less, disabled when 0
+	MinScore int
+	// TTL prunes counters not requested for this long, disabled when 0
+	TTL time.Duration
+	// LockTTL bounds how long a crashed replica can block the others
+	LockTTL time.Duration
+}
+
+type CompactionService interface {
+	// Compact runs a compaction if no other replica is running one, it
+	// returns false when the lock was not acquired.
+	Compact(ctx context.Context) (int, bool, error)
+	// Run compacts every interval until ctx is done
+	Run(ctx context.Context)
+}
+
+type compactionService struct {
+	compactionRepo repository.CompactionRepository
+	config         CompactionConfig
+	logger         *zap.Logger
+}
+
+func NewCompactionService(compactionRepo repository.CompactionRepository,
+	config CompactionConfig,
+	logger *zap.Logger) CompactionService {
+	return &compactionService{compactionRepo: compactionRepo, config: config, logger: logger}
+}
+
+func (cs *compactionService) Compact(ctx context.Context) (int, bool, error) {
+	token, err := usecase.RandomID(16)
+	if err != nil {
+		return 0, false, err
+	}
+	acquired, err := cs.compactionRepo.AcquireLock(ctx, token, cs.config.LockTTL)
+	if err != nil || !acquired {
+		return 0, false, err
+	}
+	defer func() {
+		if err := cs.compactionRepo.ReleaseLock(context.Background(), token); err != nil {
+			cs.logger.Error("Failed to release compaction lock", zap.Error(err))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, cs.config.LockTTL)
+	defer cancel()
+	var before time.Time
+	if cs.config.TTL > 0 {
+		before = time.Now().Add(-cs.config.TTL)
+	}
+	pruned, err := cs.compactionRepo.Compact(ctx, cs.config.MinScore, before)
+	return pruned, true, err
+}
+
+func (cs *compactionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(cs.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, acquired, err := cs.Compact(ctx)
+			switch {
+			case err != nil:
+				CompactionRunsCounter.WithLabelValues("error").Inc()
+				cs.logger.Error("Compaction failed", zap.Int("pruned", pruned), zap.Error(err))
+			case !acquired:
+				CompactionRunsCounter.WithLabelValues("skipped").Inc()
+				continue
+			default:
+				CompactionRunsCounter.WithLabelValues("success").Inc()
+				cs.logger.Info("Compaction done", zap.Int("pruned", pruned))
+			}
+			CompactionPrunedHistogram.Observe(float64(pruned))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"FizzBuzz/repository"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type CompactionServiceSuite struct {
+	suite.Suite
+	redisServer    *miniredis.Miniredis
+	compactionRepo repository.CompactionRepository
+	cs             CompactionService
+}
+
+func (suite *CompactionServiceSuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	suite.compactionRepo = repository.NewCompactionRepository(fbRedis.NewRedis(host[0], host[1], ""), zap.NewExample())
+	suite.cs = NewCompactionService(suite.compactionRepo, CompactionConfig{
+		Interval: time.Minute,
+		MinScore: 2,
+		LockTTL:  time.Minute,
+	}, zap.NewExample())
+}
+
+func (suite *CompactionServiceSuite) TearDownTest() {
+	suite.redisServer.Close()
+}
+
+func (suite *CompactionServiceSuite) TestSingleReplica() {
+	ctx := context.Background()
+	acquired, err := suite.compactionRepo.AcquireLock(ctx, "other-replica", time.Minute)
+	suite.Require().NoError(err)
+	suite.Require().True(acquired)
+
+	_, acquired, err = suite.cs.Compact(ctx)
+	suite.Require().NoError(err)
+	suite.False(acquired)
+
+	suite.Require().NoError(suite.compactionRepo.ReleaseLock(ctx, "other-replica"))
+	_, acquired, err = suite.cs.Compact(ctx)
+	suite.Require().NoError(err)
+	suite.True(acquired)
+
+	// The lock is released after the run
+	suite.False(suite.redisServer.Exists(fbRedis.KeyLock("compaction")))
+}
+
+func TestCompactionServiceSuite(t *testing.T) {
+	suite.Run(t, new(CompactionServiceSuite))
+}
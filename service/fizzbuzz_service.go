@@ -3,15 +3,64 @@ package service
 //go:generate ../.deps/mockgen -destination mock/fizzbuzz_service.go -source fizzbuzz_service.go
 
 import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/numfmt"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"strconv"
 
 	"go.uber.org/zap"
 )
 
 type FizzBuzzService interface {
-	SimpleFizzBuzz(limit, firstMod, sndMod int, firsStr, sndStr string) []string
+	// SimpleFizzBuzz numbers which are not replaced are written with numberFormat,
+	// decimal when empty
+	SimpleFizzBuzz(limit, firstMod, sndMod int, firsStr, sndStr, numberFormat string) []string
+	// WriteFizzBuzz writes the sequence one term per line without holding it in memory,
+	// progress is called regularly with the number of terms written.
+	WriteFizzBuzz(ctx context.Context, w io.Writer, request domain.FizzBuzzRequest, progress func(done int)) error
+	// ExplainFizzBuzz computes the sequence telling for each term which rules matched
+	ExplainFizzBuzz(request domain.FizzBuzzRequest) []domain.ExplainedTerm
+	// WriteExplainedFizzBuzz writes the explained terms one JSON object per line,
+	// like WriteFizzBuzz.
+	WriteExplainedFizzBuzz(ctx context.Context, w io.Writer, request domain.FizzBuzzRequest, progress func(done int)) error
+	// BigFizzBuzz computes the terms of a window of numbers which may not fit in int64
+	BigFizzBuzz(request domain.BigFizzBuzzRequest) ([]string, error)
+	// Terms computes the terms at the indexes of the request, each one on its own
+	Terms(request domain.TermRequest) []domain.Term
+	// Stats counts the words of the sequence up to its limit without generating it
+	Stats(request domain.StatsRequest) *domain.Stats
+	// Check grades the terms read from submission against the sequence of the request,
+	// the submission is read one term at a time.
+	Check(request domain.FizzBuzzRequest, submission TermReader) (*domain.CheckReport, error)
+	// Infer finds the rule sets producing an observed sequence
+	Infer(request domain.InferRequest) *domain.InferReport
 }
 
+// TermReader reads the terms of a submitted sequence, io.EOF after the last one
+type TermReader interface {
+	ReadTerm() (string, error)
+}
+
+var (
+	ErrBigMalformed     = errors.New("malformed big integer")
+	ErrBigInvalidModulo = errors.New("modulos should be greater than or equal to 1")
+	ErrBigInvalidRange  = errors.New("range should start before it ends")
+	ErrBigTooManyTerms  = errors.New("range has too many terms")
+	ErrCheckSubmission  = errors.New("submission can't be read")
+)
+
+// progressStep is the number of terms written between two progress reports
+const progressStep = 1 << 16
+
+// MaxBigTerms is the maximum number of terms of a big request
+const MaxBigTerms = 10_000
+
 type fizzBuzzService struct {
 	logger *zap.Logger
 }
@@ -22,21 +71,339 @@ func NewFizzBuzzService(logger *zap.Logger) FizzBuzzService {
 	}
 }
 
-func (fbs *fizzBuzzService) SimpleFizzBuzz(limit, firstMod, sndMod int, firsStr, sndStr string) []string {
+// template compiles a word, requests are validated when bound so an invalid
+// template is only written as is.
+func (fbs *fizzBuzzService) template(word string) *numfmt.Template {
+	t, err := numfmt.Compile(word)
+	if err != nil {
+		fbs.logger.Warn("Invalid word template", zap.String("word", word), zap.Error(err))
+		return numfmt.Literal(word)
+	}
+	return t
+}
+
+// numberFormat parses the format of the numbers, requests are validated when bound
+// so an invalid format is only written in decimal.
+func (fbs *fizzBuzzService) numberFormat(spec string) *numfmt.NumberFormat {
+	f, err := numfmt.ParseNumberFormat(spec)
+	if err != nil {
+		fbs.logger.Warn("Invalid number format", zap.String("number_format", spec), zap.Error(err))
+		f, _ = numfmt.ParseNumberFormat("")
+	}
+	return f
+}
+
+func (fbs *fizzBuzzService) SimpleFizzBuzz(limit, firstMod, sndMod int, firsStr, sndStr, numberFormat string) []string {
+	fst, snd := fbs.template(firsStr), fbs.template(sndStr)
+	format := fbs.numberFormat(numberFormat)
+	decimal := format.Default()
+	// Static words are rendered once and shared by every term, escaped braces
+	// are only written once rendered
+	static := fst.Static() && snd.Static()
+	both := ""
+	if static {
+		both = fst.Render(0) + snd.Render(0)
+	}
 	res := make([]string, limit)
+	buf := make([]byte, 0, 64)
+	render := func(t *numfmt.Template, nb int) string {
+		if t.Static() {
+			return t.Render(0)
+		}
+		buf = t.AppendTo(buf[:0], int64(nb))
+		return string(buf)
+	}
 	for i := 0; i < limit; i++ {
 		nb := i + 1
 		m1 := nb % firstMod
 		m2 := nb % sndMod
 		if m1 == 0 && m2 == 0 {
-			res[i] = firsStr + sndStr
+			if static {
+				res[i] = both
+			} else {
+				buf = snd.AppendTo(fst.AppendTo(buf[:0], int64(nb)), int64(nb))
+				res[i] = string(buf)
+			}
 		} else if m1 == 0 {
-			res[i] = firsStr
+			res[i] = render(fst, nb)
 		} else if m2 == 0 {
-			res[i] = sndStr
-		} else {
+			res[i] = render(snd, nb)
+		} else if decimal {
 			res[i] = strconv.Itoa(nb)
+		} else {
+			buf = format.AppendTo(buf[:0], int64(nb))
+			res[i] = string(buf)
 		}
 	}
 	return res
 }
+
+func (fbs *fizzBuzzService) WriteFizzBuzz(ctx context.Context,
+	w io.Writer,
+	request domain.FizzBuzzRequest,
+	progress func(done int)) error {
+	return writeTerms(ctx, w, request.Limit, progress, fbs.termAppender(request))
+}
+
+// termAppender returns a function writing the term of nb to buf
+func (fbs *fizzBuzzService) termAppender(request domain.FizzBuzzRequest) func(buf []byte, nb int) []byte {
+	fst, snd := fbs.template(request.FstStr), fbs.template(request.SndStr)
+	format := fbs.numberFormat(request.NumberFormat)
+	return func(buf []byte, nb int) []byte {
+		m1 := nb % request.FstModulo
+		m2 := nb % request.SndModulo
+		if m1 == 0 {
+			buf = fst.AppendTo(buf, int64(nb))
+		}
+		if m2 == 0 {
+			buf = snd.AppendTo(buf, int64(nb))
+		}
+		if m1 != 0 && m2 != 0 {
+			buf = format.AppendTo(buf, int64(nb))
+		}
+		return buf
+	}
+}
+
+// writeTerms writes the terms from 1 to limit one per line, appendTerm writes
+// the term of nb to buf.
+func writeTerms(ctx context.Context,
+	w io.Writer,
+	limit int,
+	progress func(done int),
+	appendTerm func(buf []byte, nb int) []byte) error {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	buf := make([]byte, 0, 64)
+	for nb := 1; nb <= limit; nb++ {
+		buf = append(appendTerm(buf[:0], nb), '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+
+		if nb%progressStep == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if progress != nil {
+				progress(nb)
+			}
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(limit)
+	}
+	return nil
+}
+
+// explainer computes the explained terms of a request
+type explainer struct {
+	request  domain.FizzBuzzRequest
+	fst, snd *numfmt.Template
+	format   *numfmt.NumberFormat
+	buf      []byte
+}
+
+func (fbs *fizzBuzzService) explainer(request domain.FizzBuzzRequest) *explainer {
+	return &explainer{
+		request: request,
+		fst:     fbs.template(request.FstStr),
+		snd:     fbs.template(request.SndStr),
+		format:  fbs.numberFormat(request.NumberFormat),
+		buf:     make([]byte, 0, 64),
+	}
+}
+
+func (e *explainer) term(nb int) domain.ExplainedTerm {
+	term := domain.ExplainedTerm{
+		N:        nb,
+		Matched:  []string{},
+		Residues: domain.Residues{Fst: nb % e.request.FstModulo, Snd: nb % e.request.SndModulo},
+	}
+	e.buf = e.buf[:0]
+	if term.Residues.Fst == 0 {
+		e.buf = e.fst.AppendTo(e.buf, int64(nb))
+		term.Matched = append(term.Matched, domain.RuleFst)
+	}
+	if term.Residues.Snd == 0 {
+		e.buf = e.snd.AppendTo(e.buf, int64(nb))
+		term.Matched = append(term.Matched, domain.RuleSnd)
+	}
+	if len(term.Matched) == 0 {
+		e.buf = e.format.AppendTo(e.buf, int64(nb))
+	}
+	term.Value = string(e.buf)
+	return term
+}
+
+func (fbs *fizzBuzzService) ExplainFizzBuzz(request domain.FizzBuzzRequest) []domain.ExplainedTerm {
+	e := fbs.explainer(request)
+	res := make([]domain.ExplainedTerm, request.Limit)
+	for i := range res {
+		res[i] = e.term(i + 1)
+	}
+	return res
+}
+
+func (fbs *fizzBuzzService) WriteExplainedFizzBuzz(ctx context.Context,
+	w io.Writer,
+	request domain.FizzBuzzRequest,
+	progress func(done int)) error {
+	e := fbs.explainer(request)
+	return writeTerms(ctx, w, request.Limit, progress, func(buf []byte, nb int) []byte {
+		// Explained terms only hold strings and integers, they always marshal
+		data, _ := json.Marshal(e.term(nb))
+		return append(buf, data...)
+	})
+}
+
+func (fbs *fizzBuzzService) BigFizzBuzz(request domain.BigFizzBuzzRequest) ([]string, error) {
+	numbers := make([]*big.Int, 4)
+	for i, numeral := range []string{request.FstModulo, request.SndModulo, request.Start, request.End} {
+		n, ok := domain.ParseBigInt(numeral)
+		if !ok {
+			return nil, ErrBigMalformed
+		}
+		numbers[i] = n
+	}
+	firstMod, sndMod, start, end := numbers[0], numbers[1], numbers[2], numbers[3]
+	if firstMod.Sign() <= 0 || sndMod.Sign() <= 0 {
+		return nil, ErrBigInvalidModulo
+	}
+	count := new(big.Int).Sub(end, start)
+	if count.Sign() < 0 {
+		return nil, ErrBigInvalidRange
+	}
+	if !count.IsInt64() || count.Int64() >= MaxBigTerms {
+		return nil, ErrBigTooManyTerms
+	}
+
+	fst, snd := fbs.template(request.FstStr), fbs.template(request.SndStr)
+	res := make([]string, count.Int64()+1)
+	buf := make([]byte, 0, 64)
+	if start.IsInt64() && end.IsInt64() && firstMod.IsInt64() && sndMod.IsInt64() {
+		// Fast path, every term fits in int64
+		mod1, mod2 := firstMod.Int64(), sndMod.Int64()
+		for i := range res {
+			nb := start.Int64() + int64(i)
+			buf = buf[:0]
+			m1 := nb % mod1
+			m2 := nb % mod2
+			if m1 == 0 {
+				buf = fst.AppendTo(buf, nb)
+			}
+			if m2 == 0 {
+				buf = snd.AppendTo(buf, nb)
+			}
+			if m1 != 0 && m2 != 0 {
+				buf = strconv.AppendInt(buf, nb, 10)
+			}
+			res[i] = string(buf)
+		}
+		return res, nil
+	}
+
+	// Remainders are incremented along the numbers instead of dividing each of them
+	one := big.NewInt(1)
+	nb := new(big.Int).Set(start)
+	m1 := new(big.Int).Mod(start, firstMod)
+	m2 := new(big.Int).Mod(start, sndMod)
+	for i := range res {
+		buf = buf[:0]
+		if m1.Sign() == 0 {
+			buf = fst.AppendBigTo(buf, nb)
+		}
+		if m2.Sign() == 0 {
+			buf = snd.AppendBigTo(buf, nb)
+		}
+		if m1.Sign() != 0 && m2.Sign() != 0 {
+			buf = nb.Append(buf, 10)
+		}
+		res[i] = string(buf)
+
+		nb.Add(nb, one)
+		if m1.Add(m1, one).Cmp(firstMod) == 0 {
+			m1.SetInt64(0)
+		}
+		if m2.Add(m2, one).Cmp(sndMod) == 0 {
+			m2.SetInt64(0)
+		}
+	}
+	return res, nil
+}
+
+func (fbs *fizzBuzzService) Terms(request domain.TermRequest) []domain.Term {
+	fst, snd := fbs.template(request.FstStr), fbs.template(request.SndStr)
+	format := fbs.numberFormat(request.NumberFormat)
+	terms := make([]domain.Term, len(request.N))
+	buf := make([]byte, 0, 64)
+	for i, nb := range request.N {
+		buf = buf[:0]
+		m1 := nb % request.FstModulo
+		m2 := nb % request.SndModulo
+		if m1 == 0 {
+			buf = fst.AppendTo(buf, int64(nb))
+		}
+		if m2 == 0 {
+			buf = snd.AppendTo(buf, int64(nb))
+		}
+		if m1 != 0 && m2 != 0 {
+			buf = format.AppendTo(buf, int64(nb))
+		}
+		terms[i] = domain.Term{N: nb, Value: string(buf)}
+	}
+	return terms
+}
+
+func (fbs *fizzBuzzService) Stats(request domain.StatsRequest) *domain.Stats {
+	return domain.ComputeStats(request)
+}
+
+func (fbs *fizzBuzzService) Check(request domain.FizzBuzzRequest, submission TermReader) (*domain.CheckReport, error) {
+	appendTerm := fbs.termAppender(request)
+	report := &domain.CheckReport{Limit: request.Limit, Mismatches: []domain.CheckMismatch{}}
+	buf := make([]byte, 0, 64)
+	for {
+		actual, err := submission.ReadTerm()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCheckSubmission, err)
+		}
+		report.Submitted++
+		nb := report.Submitted
+		if nb > request.Limit {
+			report.Extra++
+			continue
+		}
+
+		buf = appendTerm(buf[:0], nb)
+		if string(buf) == actual {
+			report.Correct++
+			continue
+		}
+		mismatch := domain.CheckMismatch{N: nb, Expected: string(buf), Actual: actual}
+		if report.FirstMismatch == nil {
+			report.FirstMismatch = &mismatch
+		}
+		report.MismatchCount++
+		if len(report.Mismatches) < domain.MaxCheckMismatches {
+			report.Mismatches = append(report.Mismatches, mismatch)
+		} else {
+			report.Truncated = true
+		}
+	}
+
+	if report.Submitted < request.Limit {
+		report.Missing = request.Limit - report.Submitted
+	}
+	report.Score = float64(report.Correct) / float64(request.Limit+report.Extra)
+	return report, nil
+}
+
+func (fbs *fizzBuzzService) Infer(request domain.InferRequest) *domain.InferReport {
+	return domain.InferRules(request)
+}
@@ -1,6 +1,11 @@
 package service
 
 import (
+	"FizzBuzz/domain"
+	"bytes"
+	"context"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/go-playground/assert/v2"
@@ -9,28 +14,28 @@ import (
 func BenchmarkSimpleFizzBuzz1000(b *testing.B) {
 	s := NewFizzBuzzService(nil)
 	for i := 0; i < b.N; i++ {
-		s.SimpleFizzBuzz(1000, 3, 5, "fizz", "buzz")
+		s.SimpleFizzBuzz(1000, 3, 5, "fizz", "buzz", "")
 	}
 }
 
 func BenchmarkSimpleFizzBuzz10000(b *testing.B) {
 	s := NewFizzBuzzService(nil)
 	for i := 0; i < b.N; i++ {
-		s.SimpleFizzBuzz(10000, 3, 5, "fizz", "buzz")
+		s.SimpleFizzBuzz(10000, 3, 5, "fizz", "buzz", "")
 	}
 }
 
 func BenchmarkSimpleFizzBuzz100000(b *testing.B) {
 	s := NewFizzBuzzService(nil)
 	for i := 0; i < b.N; i++ {
-		s.SimpleFizzBuzz(100000, 3, 5, "fizz", "buzz")
+		s.SimpleFizzBuzz(100000, 3, 5, "fizz", "buzz", "")
 	}
 }
 
 func BenchmarkSimpleFizzBuzz1000000(b *testing.B) {
 	s := NewFizzBuzzService(nil)
 	for i := 0; i < b.N; i++ {
-		s.SimpleFizzBuzz(1000000, 3, 5, "fizz", "buzz")
+		s.SimpleFizzBuzz(1000000, 3, 5, "fizz", "buzz", "")
 	}
 }
 
@@ -42,6 +47,7 @@ func TestSimpleFizzBuzz(t *testing.T) {
 		mod2     int
 		r1       string
 		r2       string
+		format   string
 		expected []string
 	}{
 		{
@@ -109,6 +115,42 @@ func TestSimpleFizzBuzz(t *testing.T) {
 				"twofour",
 			},
 		},
+		{
+			name:  "templates",
+			limit: 6,
+			mod1:  2,
+			mod2:  3,
+			r1:    "{n:hex}",
+			r2:    "-{n:roman}",
+			expected: []string{
+				"1",
+				"2",
+				"-III",
+				"4",
+				"5",
+				"6-VI",
+			},
+		},
+		{
+			name:     "number format",
+			limit:    6,
+			mod1:     3,
+			mod2:     5,
+			r1:       "fizz",
+			r2:       "{n:dec}",
+			format:   "base=2,pad=4",
+			expected: []string{"0001", "0010", "fizz", "0100", "5", "fizz"},
+		},
+		{
+			name:     "number words",
+			limit:    4,
+			mod1:     3,
+			mod2:     5,
+			r1:       "fizz",
+			r2:       "buzz",
+			format:   "words",
+			expected: []string{"one", "two", "fizz", "four"},
+		},
 	}
 	t.Parallel()
 	for _, test := range tests {
@@ -116,8 +158,184 @@ func TestSimpleFizzBuzz(t *testing.T) {
 			fbs := NewFizzBuzzService(nil)
 			assert.Equal(t,
 				test.expected,
-				fbs.SimpleFizzBuzz(test.limit, test.mod1, test.mod2, test.r1, test.r2),
+				fbs.SimpleFizzBuzz(test.limit, test.mod1, test.mod2, test.r1, test.r2, test.format),
 			)
 		})
 	}
 }
+
+func TestWriteFizzBuzz(t *testing.T) {
+	request := domain.FizzBuzzRequest{
+		FstModulo: 3,
+		SndModulo: 5,
+		Limit:     100,
+		FstStr:    "fizz",
+		SndStr:    "buzz",
+		// Numbers are written with the same format
+		NumberFormat: "roman",
+	}
+	fbs := NewFizzBuzzService(nil)
+
+	var buf bytes.Buffer
+	var done int
+	err := fbs.WriteFizzBuzz(context.Background(), &buf, request, func(d int) { done = d })
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 100, done)
+
+	expected := fbs.SimpleFizzBuzz(request.Limit, request.FstModulo, request.SndModulo, request.FstStr, request.SndStr, request.NumberFormat)
+	assert.Equal(t, strings.Join(expected, "\n")+"\n", buf.String())
+}
+
+func TestEscapedBracesSamePaths(t *testing.T) {
+	request := domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: "a{{b", SndStr: "c}}d"}
+	fbs := NewFizzBuzzService(nil)
+
+	simple := fbs.SimpleFizzBuzz(request.Limit, request.FstModulo, request.SndModulo, request.FstStr, request.SndStr, request.NumberFormat)
+	assert.Equal(t, "a{b", simple[2])
+	assert.Equal(t, "c}d", simple[4])
+	assert.Equal(t, "a{bc}d", simple[14])
+
+	// The streamed terms are the same as the simple ones
+	var buf bytes.Buffer
+	assert.Equal(t, nil, fbs.WriteFizzBuzz(context.Background(), &buf, request, nil))
+	assert.Equal(t, strings.Join(simple, "\n")+"\n", buf.String())
+}
+
+func TestBigFizzBuzz(t *testing.T) {
+	fbs := NewFizzBuzzService(nil)
+
+	// Values fitting in int64 give the same terms as the simple fizzbuzz
+	res, err := fbs.BigFizzBuzz(domain.BigFizzBuzzRequest{
+		FstModulo: "3", SndModulo: "5", Start: "1", End: "15", FstStr: "fizz", SndStr: "buzz",
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, fbs.SimpleFizzBuzz(15, 3, 5, "fizz", "buzz", ""), res)
+
+	// 10^30 is 1 modulo 3 and 0 modulo 5
+	res, err = fbs.BigFizzBuzz(domain.BigFizzBuzzRequest{
+		FstModulo: "3",
+		SndModulo: "5",
+		Start:     "1000000000000000000000000000000",
+		End:       "1000000000000000000000000000005",
+		FstStr:    "fizz",
+		SndStr:    "buzz({n:ordinal})",
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{
+		"buzz(1000000000000000000000000000000th)",
+		"1000000000000000000000000000001",
+		"fizz",
+		"1000000000000000000000000000003",
+		"1000000000000000000000000000004",
+		"fizzbuzz(1000000000000000000000000000005th)",
+	}, res)
+
+	// Big modulos
+	res, err = fbs.BigFizzBuzz(domain.BigFizzBuzzRequest{
+		FstModulo: "9223372036854775808",
+		SndModulo: "1",
+		Start:     "9223372036854775807",
+		End:       "9223372036854775808",
+		FstStr:    "fizz",
+		SndStr:    "",
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"", "fizz"}, res)
+}
+
+func TestBigFizzBuzzErrors(t *testing.T) {
+	fbs := NewFizzBuzzService(nil)
+	tests := []struct {
+		request  domain.BigFizzBuzzRequest
+		expected error
+	}{
+		{request: domain.BigFizzBuzzRequest{FstModulo: "3x", SndModulo: "5", Start: "1", End: "2"}, expected: ErrBigMalformed},
+		{request: domain.BigFizzBuzzRequest{FstModulo: "0", SndModulo: "5", Start: "1", End: "2"}, expected: ErrBigInvalidModulo},
+		{request: domain.BigFizzBuzzRequest{FstModulo: "3", SndModulo: "5", Start: "2", End: "1"}, expected: ErrBigInvalidRange},
+		{request: domain.BigFizzBuzzRequest{FstModulo: "3", SndModulo: "5", Start: "1", End: "100000000000000000000"}, expected: ErrBigTooManyTerms},
+	}
+	for _, test := range tests {
+		_, err := fbs.BigFizzBuzz(test.request)
+		assert.Equal(t, test.expected, err)
+	}
+}
+
+func TestTerms(t *testing.T) {
+	fbs := NewFizzBuzzService(nil)
+	expected := fbs.SimpleFizzBuzz(100, 3, 5, "fizz", "{n:roman}", "base=2")
+	terms := fbs.Terms(domain.TermRequest{
+		FstModulo:    3,
+		SndModulo:    5,
+		FstStr:       "fizz",
+		SndStr:       "{n:roman}",
+		NumberFormat: "base=2",
+		N:            []int{100, 1, 15, 98},
+	})
+	assert.Equal(t, []domain.Term{
+		{N: 100, Value: expected[99]},
+		{N: 1, Value: expected[0]},
+		{N: 15, Value: expected[14]},
+		{N: 98, Value: expected[97]},
+	}, terms)
+}
+
+func TestExplainFizzBuzz(t *testing.T) {
+	request := domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 30, FstStr: "fizz", SndStr: "{n:roman}", NumberFormat: "pad=2"}
+	fbs := NewFizzBuzzService(nil)
+	explained := fbs.ExplainFizzBuzz(request)
+	expected := fbs.SimpleFizzBuzz(request.Limit, request.FstModulo, request.SndModulo, request.FstStr, request.SndStr, request.NumberFormat)
+	for i, term := range explained {
+		assert.Equal(t, i+1, term.N)
+		assert.Equal(t, expected[i], term.Value)
+	}
+	assert.Equal(t, domain.ExplainedTerm{
+		N:        10,
+		Value:    "X",
+		Matched:  []string{domain.RuleSnd},
+		Residues: domain.Residues{Fst: 1, Snd: 0},
+	}, explained[9])
+	assert.Equal(t, []string{domain.RuleFst, domain.RuleSnd}, explained[29].Matched)
+}
+
+// sliceReader submits the terms of a slice
+type sliceReader []string
+
+func (sr *sliceReader) ReadTerm() (string, error) {
+	if len(*sr) == 0 {
+		return "", io.EOF
+	}
+	term := (*sr)[0]
+	*sr = (*sr)[1:]
+	return term, nil
+}
+
+func TestCheck(t *testing.T) {
+	request := domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 5, FstStr: "fizz", SndStr: "buzz"}
+	fbs := NewFizzBuzzService(nil)
+
+	submission := sliceReader{"1", "2", "fizz", "4", "buzz"}
+	report, err := fbs.Check(request, &submission)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &domain.CheckReport{Limit: 5, Submitted: 5, Correct: 5, Mismatches: []domain.CheckMismatch{}, Score: 1}, report)
+
+	submission = sliceReader{"1", "2", "3", "4", "buzz", "fizz", "7"}
+	report, err = fbs.Check(request, &submission)
+	assert.Equal(t, nil, err)
+	mismatch := domain.CheckMismatch{N: 3, Expected: "fizz", Actual: "3"}
+	assert.Equal(t, &domain.CheckReport{
+		Limit:         5,
+		Submitted:     7,
+		Correct:       4,
+		FirstMismatch: &mismatch,
+		MismatchCount: 1,
+		Mismatches:    []domain.CheckMismatch{mismatch},
+		Extra:         2,
+		Score:         4.0 / 7,
+	}, report)
+
+	submission = sliceReader{"1"}
+	report, err = fbs.Check(request, &submission)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, report.Missing)
+	assert.Equal(t, 0.2, report.Score)
+}
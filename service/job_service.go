@@ -0,0 +1,302 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/job_service.go -source job_service.go
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobQueueFull   = errors.New("too many jobs waiting")
+	ErrJobNotFinished = errors.New("job is not done")
+	ErrJobFinished    = errors.New("job is already finished")
+	ErrJobNoResult    = errors.New("job result is not on this instance")
+)
+
+type JobService interface {
+	// Enqueue queues the computation of the request, written as explained terms when explain is set
+	Enqueue(request domain.FizzBuzzRequest, explain bool) (*domain.Job, error)
+	Get(id string) (*domain.Job, error)
+	Cancel(id string) (*domain.Job, error)
+	// ResultPath returns the file holding the output of a done job
+	ResultPath(id string) (string, error)
+	// RunJanitor purges jobs older than the ttl every given time, until ctx is done
+	RunJanitor(ctx context.Context, every time.Duration)
+}
+
+type jobService struct {
+	jobRepo repository.JobRepository
+	fbs     FizzBuzzService
+	dir     string
+	ttl     time.Duration
+	queue   chan string
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	logger  *zap.Logger
+}
+
+// NewJobService runs jobs on workers goroutines, at most queueSize jobs can wait
+// for a worker. Results are written in dir and removed along the job after ttl.
+func NewJobService(jobRepo repository.JobRepository,
+	fbs FizzBuzzService,
+	dir string,
+	workers, queueSize int,
+	ttl time.Duration,
+	logger *zap.Logger) (JobService, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("impossible to create jobs directory: %w", err)
+	}
+
+	js := &jobService{
+		jobRepo: jobRepo,
+		fbs:     fbs,
+		dir:     dir,
+		ttl:     ttl,
+		queue:   make(chan string, queueSize),
+		running: make(map[string]context.CancelFunc),
+		logger:  logger,
+	}
+	for i := 0; i < workers; i++ {
+		go js.work()
+	}
+	return js, nil
+}
+
+func (js *jobService) resultPath(id string) string {
+	return filepath.Join(js.dir, id+".txt")
+}
+
+func (js *jobService) get(ctx context.Context, id string) (*domain.Job, error) {
+	job, err := js.jobRepo.Get(ctx, id)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
+
+func (js *jobService) Enqueue(request domain.FizzBuzzRequest, explain bool) (*domain.Job, error) {
+	id, err := usecase.RandomID(16)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	job := domain.Job{
+		ID:        id,
+		Status:    domain.JobPending,
+		Request:   request,
+		Explain:   explain,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := js.jobRepo.Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case js.queue <- id:
+	default:
+		job.Status = domain.JobFailed
+		job.Error = ErrJobQueueFull.Error()
+		if err := js.jobRepo.Save(ctx, job); err != nil {
+			js.logger.Error("Failed to save rejected job", zap.Error(err))
+		}
+		return nil, ErrJobQueueFull
+	}
+	return &job, nil
+}
+
+func (js *jobService) Get(id string) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	return js.get(ctx, id)
+}
+
+func (js *jobService) Cancel(id string) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	job, err := js.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return job, ErrJobFinished
+	}
+
+	// The worker may finish the job meanwhile, the cancel must not overwrite it
+	expected := job.Status
+	job.Status = domain.JobCancelled
+	job.UpdatedAt = time.Now().UTC()
+	saved, err := js.jobRepo.SaveIf(ctx, *job, expected)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return js.Cancel(id)
+	}
+
+	// Running on another instance, its worker sees the status on next progress
+	js.mu.Lock()
+	if stop, ok := js.running[id]; ok {
+		stop()
+	}
+	js.mu.Unlock()
+	return job, nil
+}
+
+func (js *jobService) ResultPath(id string) (string, error) {
+	job, err := js.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != domain.JobDone {
+		return "", ErrJobNotFinished
+	}
+
+	path := js.resultPath(id)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrJobNoResult
+	}
+	return path, nil
+}
+
+func (js *jobService) work() {
+	for id := range js.queue {
+		js.run(id)
+	}
+}
+
+func (js *jobService) run(id string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job, err := js.get(ctx, id)
+	if err != nil {
+		js.logger.Error("Failed to get queued job", zap.String("job", id), zap.Error(err))
+		return
+	}
+	if job.Status != domain.JobPending {
+		return
+	}
+
+	js.mu.Lock()
+	js.running[id] = cancel
+	js.mu.Unlock()
+	defer func() {
+		js.mu.Lock()
+		delete(js.running, id)
+		js.mu.Unlock()
+	}()
+
+	job.Status = domain.JobRunning
+	if !js.saveIf(ctx, job, domain.JobPending) {
+		return
+	}
+
+	// A save failing on the status means the job was cancelled or purged
+	progress := func(done int) {
+		job.Progress = done
+		if !js.saveIf(ctx, job, domain.JobRunning) {
+			cancel()
+		}
+	}
+
+	err = js.write(ctx, job, progress)
+	switch {
+	case errors.Is(err, context.Canceled):
+		js.logger.Debug("Job cancelled", zap.String("job", id))
+		_ = os.Remove(js.resultPath(id))
+		return
+	case err != nil:
+		js.logger.Error("Job failed", zap.String("job", id), zap.Error(err))
+		_ = os.Remove(js.resultPath(id))
+		job.Status = domain.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = domain.JobDone
+	}
+	if !js.saveIf(context.Background(), job, domain.JobRunning) {
+		js.logger.Debug("Job cancelled once finished", zap.String("job", id))
+		_ = os.Remove(js.resultPath(id))
+	}
+}
+
+func (js *jobService) write(ctx context.Context, job *domain.Job, progress func(int)) error {
+	file, err := os.Create(js.resultPath(job.ID))
+	if err != nil {
+		return err
+	}
+	write := js.fbs.WriteFizzBuzz
+	if job.Explain {
+		write = js.fbs.WriteExplainedFizzBuzz
+	}
+	if err := write(ctx, file, job.Request, progress); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// saveIf saves the job while its stored status is still expected and tells if it did
+func (js *jobService) saveIf(ctx context.Context, job *domain.Job, expected domain.JobStatus) bool {
+	job.UpdatedAt = time.Now().UTC()
+	saved, err := js.jobRepo.SaveIf(ctx, *job, expected)
+	if err != nil {
+		// The worker keeps going, the next save may succeed
+		js.logger.Error("Failed to save job", zap.String("job", job.ID), zap.Error(err))
+		return true
+	}
+	return saved
+}
+
+func (js *jobService) purge(ctx context.Context) {
+	ids, err := js.jobRepo.Purge(ctx, time.Now().Add(-js.ttl))
+	if err != nil {
+		js.logger.Error("Failed to purge jobs", zap.Error(err))
+		return
+	}
+	for _, id := range ids {
+		js.mu.Lock()
+		if stop, ok := js.running[id]; ok {
+			stop()
+		}
+		js.mu.Unlock()
+		if err := os.Remove(js.resultPath(id)); err != nil && !os.IsNotExist(err) {
+			js.logger.Error("Failed to remove job result", zap.String("job", id), zap.Error(err))
+		}
+	}
+	if len(ids) > 0 {
+		js.logger.Debug("Jobs purged", zap.Int("count", len(ids)))
+	}
+}
+
+func (js *jobService) RunJanitor(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			js.purge(ctx)
+		}
+	}
+}
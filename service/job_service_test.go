@@ -0,0 +1,144 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type JobServiceSuite struct {
+	suite.Suite
+	dir     string
+	jobRepo repository.JobRepository
+	js      JobService
+}
+
+func (suite *JobServiceSuite) SetupTest() {
+	var err error
+	logger := zap.NewExample()
+	suite.dir = suite.T().TempDir()
+	suite.jobRepo = repository.NewMemoryJobRepository()
+	suite.js, err = NewJobService(suite.jobRepo, NewFizzBuzzService(logger), suite.dir, 1, 10, time.Hour, logger)
+	suite.Require().NoError(err)
+}
+
+func (suite *JobServiceSuite) waitFinished(id string) *domain.Job {
+	var job *domain.Job
+	suite.Require().Eventually(func() bool {
+		var err error
+		job, err = suite.js.Get(id)
+		suite.Require().NoError(err)
+		return job.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func (suite *JobServiceSuite) TestJobDone() {
+	job, err := suite.js.Enqueue(domain.FizzBuzzRequest{
+		FstModulo: 3,
+		SndModulo: 5,
+		Limit:     15,
+		FstStr:    "fizz",
+		SndStr:    "buzz",
+	}, false)
+	suite.Require().NoError(err)
+	suite.Equal(domain.JobPending, job.Status)
+
+	job = suite.waitFinished(job.ID)
+	suite.Equal(domain.JobDone, job.Status)
+	suite.Equal(15, job.Progress)
+	suite.EqualValues(100, job.Percent())
+
+	path, err := suite.js.ResultPath(job.ID)
+	suite.Require().NoError(err)
+	content, err := os.ReadFile(path)
+	suite.Require().NoError(err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	suite.Len(lines, 15)
+	suite.Equal("fizz", lines[2])
+	suite.Equal("fizzbuzz", lines[14])
+}
+
+func (suite *JobServiceSuite) TestJobExplained() {
+	job, err := suite.js.Enqueue(domain.FizzBuzzRequest{
+		FstModulo: 3,
+		SndModulo: 5,
+		Limit:     15,
+		FstStr:    "fizz",
+		SndStr:    "buzz",
+	}, true)
+	suite.Require().NoError(err)
+	job = suite.waitFinished(job.ID)
+	suite.Equal(domain.JobDone, job.Status)
+
+	path, err := suite.js.ResultPath(job.ID)
+	suite.Require().NoError(err)
+	content, err := os.ReadFile(path)
+	suite.Require().NoError(err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	suite.Len(lines, 15)
+	suite.JSONEq(`{"n":15,"value":"fizzbuzz","matched":["fst","snd"],"residues":{"fst":0,"snd":0}}`, lines[14])
+}
+
+func (suite *JobServiceSuite) TestJobCancelled() {
+	job, err := suite.js.Enqueue(domain.FizzBuzzRequest{
+		FstModulo: 3,
+		SndModulo: 5,
+		Limit:     1_000_000_000,
+		FstStr:    "fizz",
+		SndStr:    "buzz",
+	}, false)
+	suite.Require().NoError(err)
+
+	_, err = suite.js.Cancel(job.ID)
+	suite.Require().NoError(err)
+
+	job = suite.waitFinished(job.ID)
+	suite.Equal(domain.JobCancelled, job.Status)
+	_, err = suite.js.ResultPath(job.ID)
+	suite.ErrorIs(err, ErrJobNotFinished)
+
+	_, err = suite.js.Cancel(job.ID)
+	suite.ErrorIs(err, ErrJobFinished)
+}
+
+func (suite *JobServiceSuite) TestJobNotFound() {
+	_, err := suite.js.Get("unknown")
+	suite.ErrorIs(err, ErrJobNotFound)
+	_, err = suite.js.Cancel("unknown")
+	suite.ErrorIs(err, ErrJobNotFound)
+}
+
+func (suite *JobServiceSuite) TestJobPurged() {
+	job, err := suite.js.Enqueue(domain.FizzBuzzRequest{
+		FstModulo: 3,
+		SndModulo: 5,
+		Limit:     15,
+		FstStr:    "fizz",
+		SndStr:    "buzz",
+	}, false)
+	suite.Require().NoError(err)
+	job = suite.waitFinished(job.ID)
+	path, err := suite.js.ResultPath(job.ID)
+	suite.Require().NoError(err)
+
+	js := suite.js.(*jobService)
+	js.ttl = -time.Minute
+	js.purge(context.Background())
+
+	_, err = suite.js.Get(job.ID)
+	suite.ErrorIs(err, ErrJobNotFound)
+	_, err = os.Stat(path)
+	suite.True(os.IsNotExist(err))
+}
+
+func TestJobServiceSuite(t *testing.T) {
+	suite.Run(t, new(JobServiceSuite))
+}
@@ -0,0 +1,273 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/leaderboard_service.go -source leaderboard_service.go
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrTooManySubscribers = errors.New("too many leaderboard subscribers")
+)
+
+// leaderboardHistory is the number of events kept to resume a subscription
+const leaderboardHistory = 64
+
+// Bounds of the wait before subscribing again to the counter changes
+const (
+	minResubscribeBackoff = 100 * time.Millisecond
+	maxResubscribeBackoff = 30 * time.Second
+)
+
+type LeaderboardService interface {
+	// Subscribe sends the events following lastEventID, or the current ranking
+	// when lastEventID is unknown, then every new ranking.
+	Subscribe(lastEventID string) (*LeaderboardSubscription, error)
+	// Run watches the counters until ctx is done
+	Run(ctx context.Context) error
+}
+
+type LeaderboardSubscription struct {
+	Events <-chan domain.LeaderboardEvent
+	Close  func()
+}
+
+type leaderboardService struct {
+	cacheRepo      repository.CacheCounterRepository
+	size           int
+	interval       time.Duration
+	maxSubscribers int
+
+	mu          sync.Mutex
+	history     []domain.LeaderboardEvent
+	lastID      int64
+	subscribers map[chan domain.LeaderboardEvent]struct{}
+	payloads    map[string]domain.Identifiable
+	logger      *zap.Logger
+}
+
+// NewLeaderboardService tracks the top size requests, checking at most every
+// interval if the ranking changed, for at most maxSubscribers at once.
+func NewLeaderboardService(cacheRepo repository.CacheCounterRepository,
+	size int,
+	interval time.Duration,
+	maxSubscribers int,
+	logger *zap.Logger) LeaderboardService {
+	return &leaderboardService{
+		cacheRepo:      cacheRepo,
+		size:           size,
+		interval:       interval,
+		maxSubscribers: maxSubscribers,
+		subscribers:    make(map[chan domain.LeaderboardEvent]struct{}),
+		payloads:       make(map[string]domain.Identifiable),
+		logger:         logger,
+	}
+}
+
+func (ls *leaderboardService) Subscribe(lastEventID string) (*LeaderboardSubscription, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if len(ls.subscribers) >= ls.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+
+	missed := ls.missedEvents(lastEventID)
+	events := make(chan domain.LeaderboardEvent, len(missed)+leaderboardHistory)
+	for _, event := range missed {
+		events <- event
+	}
+	ls.subscribers[events] = struct{}{}
+
+	var once sync.Once
+	return &LeaderboardSubscription{
+		Events: events,
+		Close: func() {
+			once.Do(func() {
+				ls.mu.Lock()
+				delete(ls.subscribers, events)
+				ls.mu.Unlock()
+			})
+		},
+	}, nil
+}
+
+// missedEvents must be called with the lock held
+func (ls *leaderboardService) missedEvents(lastEventID string) []domain.LeaderboardEvent {
+	if len(ls.history) == 0 {
+		return nil
+	}
+	for i, event := range ls.history {
+		if event.ID == lastEventID {
+			return ls.history[i+1:]
+		}
+	}
+	// Unknown or too old, the latest ranking is enough to catch up
+	return ls.history[len(ls.history)-1:]
+}
+
+// watchChanges streams the counter changes until ctx is done, subscribing again
+// with an exponential backoff whenever the subscription fails or is closed. An
+// empty batch follows each new subscription as changes may have been missed.
+func watchChanges(ctx context.Context,
+	cacheRepo repository.CacheCounterRepository,
+	logger *zap.Logger) <-chan []domain.CounterChange {
+	out := make(chan []domain.CounterChange)
+	go func() {
+		defer close(out)
+		backoff := minResubscribeBackoff
+		for ctx.Err() == nil {
+			changes, err := cacheRepo.SubscribeChanges(ctx)
+			if err != nil {
+				logger.Error("Failed to subscribe to counter changes", zap.Duration("retry", backoff), zap.Error(err))
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				if backoff *= 2; backoff > maxResubscribeBackoff {
+					backoff = maxResubscribeBackoff
+				}
+				continue
+			}
+			backoff = minResubscribeBackoff
+
+			batch := []domain.CounterChange{}
+			for ok := true; ok; batch, ok = <-changes {
+				select {
+				case out <- batch:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() == nil {
+				logger.Warn("Counter changes subscription closed, subscribing again")
+			}
+		}
+	}()
+	return out
+}
+
+func (ls *leaderboardService) Run(ctx context.Context) error {
+	changes := watchChanges(ctx, ls.cacheRepo, ls.logger)
+
+	ticker := time.NewTicker(ls.interval)
+	defer ticker.Stop()
+	dirty := true
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-changes:
+			if !ok {
+				return ctx.Err()
+			}
+			dirty = true
+		case <-ticker.C:
+			if !dirty {
+				continue
+			}
+			dirty = false
+			if err := ls.refresh(ctx); err != nil {
+				ls.logger.Error("Failed to refresh leaderboard", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (ls *leaderboardService) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	counters, err := ls.cacheRepo.GetCounters(ctx, int64(-ls.size), -1)
+	if err != nil {
+		return err
+	}
+
+	ranking := make([]domain.MetricCountFizzBuzz, 0, len(counters))
+	// Counters come from the lowest to the highest score
+	for i := len(counters) - 1; i >= 0; i-- {
+		request, err := ls.payload(ctx, counters[i].Key)
+		if err != nil {
+			return err
+		}
+		ranking = append(ranking, domain.MetricCountFizzBuzz{
+			Key:     counters[i].Key,
+			Score:   counters[i].ScoreCounter,
+			Request: domain.CountedRequest{Identifiable: request},
+		})
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if len(ls.history) > 0 && sameRanking(ls.history[len(ls.history)-1].Ranking, ranking) {
+		return nil
+	}
+
+	id := time.Now().UnixNano()
+	if id <= ls.lastID {
+		id = ls.lastID + 1
+	}
+	ls.lastID = id
+	event := domain.LeaderboardEvent{ID: strconv.FormatInt(id, 10), Ranking: ranking}
+
+	ls.history = append(ls.history, event)
+	if len(ls.history) > leaderboardHistory {
+		ls.history = ls.history[len(ls.history)-leaderboardHistory:]
+	}
+	for subscriber := range ls.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			ls.logger.Debug("Leaderboard subscriber too slow, event skipped")
+		}
+	}
+	return nil
+}
+
+// payload must not be called with the lock held
+func (ls *leaderboardService) payload(ctx context.Context, key string) (domain.Identifiable, error) {
+	ls.mu.Lock()
+	request, ok := ls.payloads[key]
+	ls.mu.Unlock()
+	if ok {
+		return request, nil
+	}
+
+	data, err := ls.cacheRepo.GetData(ctx, key)
+	if err != nil {
+		return nil, ErrMetricsNoDataFound
+	}
+	request = domain.FromStrToRequest(data)
+	if request == nil {
+		return nil, ErrMetricsNoRequestFound
+	}
+
+	ls.mu.Lock()
+	// Payloads never change for a key, only keep the ones that may be displayed
+	if len(ls.payloads) > 10*ls.size {
+		ls.payloads = make(map[string]domain.Identifiable)
+	}
+	ls.payloads[key] = request
+	ls.mu.Unlock()
+	return request, nil
+}
+
+// sameRanking compares the order of the requests, not their scores
+func sameRanking(a, b []domain.MetricCountFizzBuzz) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type LeaderboardServiceSuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	cacheRepo   repository.CacheCounterRepository
+	ls          LeaderboardService
+	cancel      context.CancelFunc
+}
+
+func (suite *LeaderboardServiceSuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	logger := zap.NewExample()
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	suite.cacheRepo = repository.NewCacheCounterRepository(fbRedis.NewRedis(host[0], host[1], ""), usecase.XXHasher{}, logger)
+	suite.ls = NewLeaderboardService(suite.cacheRepo, 2, 10*time.Millisecond, 1, logger)
+
+	var ctx context.Context
+	ctx, suite.cancel = context.WithCancel(context.Background())
+	go func() {
+		_ = suite.ls.Run(ctx)
+	}()
+}
+
+func (suite *LeaderboardServiceSuite) TearDownTest() {
+	suite.cancel()
+	suite.redisServer.Close()
+}
+
+func (suite *LeaderboardServiceSuite) increment(fstStr string, times int) {
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: fstStr, SndStr: "buzz"}
+	for i := 0; i < times; i++ {
+		suite.Require().NoError(suite.cacheRepo.IncrementRequest(context.Background(), request, ""))
+	}
+}
+
+func (suite *LeaderboardServiceSuite) nextEvent(events <-chan domain.LeaderboardEvent) domain.LeaderboardEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		suite.FailNow("no leaderboard event received")
+	}
+	return domain.LeaderboardEvent{}
+}
+
+func (suite *LeaderboardServiceSuite) TestRankingChanges() {
+	sub, err := suite.ls.Subscribe("")
+	suite.Require().NoError(err)
+	defer sub.Close()
+
+	// Initial empty ranking
+	suite.Empty(suite.nextEvent(sub.Events).Ranking)
+
+	suite.increment("fizz", 2)
+	event := suite.nextEvent(sub.Events)
+	suite.Require().Len(event.Ranking, 1)
+	suite.Equal("fizz", event.Ranking[0].Request.FizzBuzz().FstStr)
+	suite.Equal(2, event.Ranking[0].Score)
+
+	suite.increment("foo", 3)
+	event = suite.nextEvent(sub.Events)
+	suite.Require().Len(event.Ranking, 2)
+	suite.Equal("foo", event.Ranking[0].Request.FizzBuzz().FstStr)
+	suite.Equal("fizz", event.Ranking[1].Request.FizzBuzz().FstStr)
+	lastID := event.ID
+
+	// Same ranking, no new event
+	suite.increment("foo", 1)
+	select {
+	case event := <-sub.Events:
+		suite.Failf("unexpected event", "%v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+	sub.Close()
+
+	// Resuming from the last event only sends the new ones
+	suite.increment("fizz", 5)
+	suite.Require().Eventually(func() bool {
+		resumed, err := suite.ls.Subscribe(lastID)
+		suite.Require().NoError(err)
+		defer resumed.Close()
+		select {
+		case event := <-resumed.Events:
+			return event.Ranking[0].Request.FizzBuzz().FstStr == "fizz"
+		default:
+			return false
+		}
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func (suite *LeaderboardServiceSuite) TestRedisUnavailable() {
+	addr := suite.redisServer.Addr()
+	suite.redisServer.Close()
+	ls := NewLeaderboardService(suite.cacheRepo, 2, 10*time.Millisecond, 1, zap.NewExample())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ls.Run(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	suite.Require().NoError(suite.redisServer.StartAddr(addr))
+
+	sub, err := ls.Subscribe("")
+	suite.Require().NoError(err)
+	defer sub.Close()
+	// Subscribed once redis is back, the client may still refuse to dial for a while
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: "fizz", SndStr: "buzz"}
+	suite.Require().Eventually(func() bool {
+		if err := suite.cacheRepo.IncrementRequest(context.Background(), request, ""); err != nil {
+			return false
+		}
+		select {
+		case event := <-sub.Events:
+			return len(event.Ranking) == 1
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (suite *LeaderboardServiceSuite) TestTooManySubscribers() {
+	sub, err := suite.ls.Subscribe("")
+	suite.Require().NoError(err)
+
+	_, err = suite.ls.Subscribe("")
+	suite.ErrorIs(err, ErrTooManySubscribers)
+
+	sub.Close()
+	sub, err = suite.ls.Subscribe("")
+	suite.Require().NoError(err)
+	sub.Close()
+}
+
+func (suite *LeaderboardServiceSuite) TestTermRequests() {
+	sub, err := suite.ls.Subscribe("")
+	suite.Require().NoError(err)
+	defer sub.Close()
+	suite.Empty(suite.nextEvent(sub.Events).Ranking)
+
+	// Term requests share the counters of the fizzbuzz ones
+	term := &domain.TermRequest{FstModulo: 3, SndModulo: 5, FstStr: "fizz", SndStr: "buzz", N: []int{15}}
+	suite.Require().NoError(suite.cacheRepo.IncrementRequest(context.Background(), term, ""))
+	event := suite.nextEvent(sub.Events)
+	suite.Require().Len(event.Ranking, 1)
+	suite.Equal(term, event.Ranking[0].Request.Identifiable)
+	suite.Nil(event.Ranking[0].Request.FizzBuzz())
+}
+
+func TestLeaderboardServiceSuite(t *testing.T) {
+	suite.Run(t, new(LeaderboardServiceSuite))
+}
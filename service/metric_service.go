@@ -16,39 +16,84 @@ var (
 	ErrMetricsNoCountersFound = errors.New("no metric for top request fizzbuzz was found")
 	ErrMetricsNoRequestFound  = errors.New("no payload found from requested data")
 	ErrMetricsNoDataFound     = errors.New("no data found from requested data")
+	ErrMetricsNotRequested    = errors.New("request was never made")
+	ErrMetricsNotSupported    = errors.New("not supported by this counting mode")
 )
 
 type MetricService interface {
-	Increment(request domain.ToBytes) error
-	MostRequested() (*domain.MetricCountFizzBuzz, error)
+	// Increment counts the request, client identifies the caller to count unique clients
+	Increment(request domain.Identifiable, client string) error
+	IncrementBatch(requests []domain.Identifiable, client string) error
+	// AddCosts sums what serving each request took
+	AddCosts(requests []domain.Identifiable, costs []domain.RequestCost) error
+	// MostRequested returns the first request of the ranking, the default one when empty
+	MostRequested(ranking domain.Ranking) (*domain.MetricCountFizzBuzz, error)
+	// Lookup returns how many times request was made and its rank, by the default
+	// ranking when empty
+	Lookup(request domain.FizzBuzzRequest, ranking domain.Ranking) (*domain.MetricLookup, error)
 }
 
 type metricService struct {
 	cacheRepo repository.CacheCounterRepository
+	rankBy    domain.Ranking
 	logger    *zap.Logger
 }
 
+// NewMetricService ranks requests by rankBy unless another ranking is asked
 func NewMetricService(cacheRepo repository.CacheCounterRepository,
+	rankBy domain.Ranking,
 	logger *zap.Logger) MetricService {
 	return &metricService{
 		logger:    logger,
+		rankBy:    rankBy,
 		cacheRepo: cacheRepo,
 	}
 }
 
-func (ms *metricService) Increment(request domain.ToBytes) error {
+func (ms *metricService) Increment(request domain.Identifiable, client string) error {
 	ctx := context.Background()
-	if err := ms.cacheRepo.IncrementRequest(ctx, request); err != nil {
+	if err := ms.cacheRepo.IncrementRequest(ctx, request, client); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (ms *metricService) MostRequested() (*domain.MetricCountFizzBuzz, error) {
+func (ms *metricService) IncrementBatch(requests []domain.Identifiable, client string) error {
+	if len(requests) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	return ms.cacheRepo.IncrementRequests(ctx, requests, client)
+}
+
+func (ms *metricService) AddCosts(requests []domain.Identifiable, costs []domain.RequestCost) error {
+	if len(requests) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	return ms.cacheRepo.AddCosts(ctx, requests, costs)
+}
+
+func (ms *metricService) ranking(ranking domain.Ranking) domain.Ranking {
+	if ranking == "" {
+		return ms.rankBy
+	}
+	return ranking
+}
+
+func (ms *metricService) MostRequested(ranking domain.Ranking) (*domain.MetricCountFizzBuzz, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
-	counter, err := ms.cacheRepo.GetCounters(ctx, -1, -1)
+	ranking = ms.ranking(ranking)
+	var counter domain.MetricCountersScores
+	var err error
+	if ranking == domain.RankByHits {
+		counter, err = ms.cacheRepo.GetCounters(ctx, -1, -1)
+	} else {
+		counter, err = ms.cacheRepo.GetRankedCounters(ctx, ranking, -1, -1)
+	}
 	if err != nil {
 		ms.logger.Error("Failed to get top counter", zap.Error(err))
 		return nil, err
@@ -63,18 +108,46 @@ func (ms *metricService) MostRequested() (*domain.MetricCountFizzBuzz, error) {
 		Key:   counter[0].Key,
 		Score: counter[0].ScoreCounter,
 	}
+	if ranking != domain.RankByHits {
+		if mcfbr.Score, err = ms.cacheRepo.GetScore(ctx, mcfbr.Key); err != nil {
+			ms.logger.Error("Failed to get counter", zap.Error(err))
+			return nil, err
+		}
+	}
+	if mcfbr.UniqueClients, err = ms.cacheRepo.GetUniqueClients(ctx, mcfbr.Key); err != nil {
+		ms.logger.Error("Failed to get unique clients", zap.Error(err))
+		return nil, err
+	}
+	if mcfbr.Cost, err = ms.cacheRepo.GetCost(ctx, mcfbr.Key); err != nil {
+		ms.logger.Error("Failed to get cost", zap.Error(err))
+		return nil, err
+	}
 
 	requestPayload, err := ms.cacheRepo.GetData(ctx, mcfbr.Key)
 	if err != nil {
 		ms.logger.Error("Failed to get data", zap.Error(err))
 		return nil, ErrMetricsNoDataFound
 	}
-	fbr := domain.FromStrToRequestFB(requestPayload)
-	if fbr == nil {
+	request := domain.FromStrToRequest(requestPayload)
+	if request == nil {
 		ms.logger.Debug("No request")
 		return nil, ErrMetricsNoRequestFound
 	}
 
-	mcfbr.Request = *fbr
+	mcfbr.Request = domain.CountedRequest{Identifiable: request}
 	return &mcfbr, nil
 }
+
+func (ms *metricService) Lookup(request domain.FizzBuzzRequest, ranking domain.Ranking) (*domain.MetricLookup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	lookup, err := ms.cacheRepo.Lookup(ctx, &request, ms.ranking(ranking))
+	if errors.Is(err, repository.ErrCacheKeyNotFound) {
+		return nil, ErrMetricsNotRequested
+	} else if err != nil {
+		ms.logger.Error("Failed to lookup counter", zap.Error(err))
+		return nil, err
+	}
+	lookup.Request = request
+	return lookup, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/replication_service.go -source replication_service.go
+
+import (
+	"FizzBuzz"
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrReplicationPeer = errors.New("peer snapshot could not be fetched")
+
+	ReplicationSyncCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "replication",
+		Name:      "syncs",
+		Help:      "count snapshots merged from peers by result",
+	}, []string{"result"})
+)
+
+// ReplicationSnapshotPath is where an instance serves its snapshot to its peers
+const ReplicationSnapshotPath = "/admin/replication/snapshot"
+
+type ReplicationConfig struct {
+	// Peers are the base URLs of the other instances
+	Peers []string
+	// Token authenticates this instance on its peers
+	Token    string
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+type ReplicationService interface {
+	Snapshot() (*domain.ReplicaSnapshot, error)
+	// Merge returns the number of counters changed by snapshot
+	Merge(snapshot domain.ReplicaSnapshot) (int, error)
+	// Top returns the count counters with the highest sum over every replica
+	Top(count int64) ([]domain.GlobalCounter, error)
+	// Sync merges the snapshot of every peer, a failing peer doesn't prevent the
+	// others to be merged.
+	Sync(ctx context.Context) (int, error)
+	// Run syncs every interval until ctx is done
+	Run(ctx context.Context)
+}
+
+type replicationService struct {
+	replicationRepo repository.ReplicationRepository
+	config          ReplicationConfig
+	client          *http.Client
+	logger          *zap.Logger
+}
+
+func NewReplicationService(replicationRepo repository.ReplicationRepository,
+	config ReplicationConfig,
+	logger *zap.Logger) ReplicationService {
+	return &replicationService{
+		replicationRepo: replicationRepo,
+		config:          config,
+		client:          &http.Client{Timeout: config.Timeout},
+		logger:          logger,
+	}
+}
+
+// timeout bounds the snapshots which hold every counter
+func (rs *replicationService) timeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), rs.config.Timeout)
+}
+
+func (rs *replicationService) Snapshot() (*domain.ReplicaSnapshot, error) {
+	ctx, cancel := rs.timeout()
+	defer cancel()
+	return rs.replicationRepo.Snapshot(ctx)
+}
+
+func (rs *replicationService) Merge(snapshot domain.ReplicaSnapshot) (int, error) {
+	ctx, cancel := rs.timeout()
+	defer cancel()
+	return rs.replicationRepo.Merge(ctx, snapshot)
+}
+
+func (rs *replicationService) Top(count int64) ([]domain.GlobalCounter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	return rs.replicationRepo.Top(ctx, count)
+}
+
+func (rs *replicationService) fetch(ctx context.Context, peer string) (*domain.ReplicaSnapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(peer, "/")+ReplicationSnapshotPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rs.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+rs.config.Token)
+	}
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrReplicationPeer, resp.StatusCode)
+	}
+	var snapshot domain.ReplicaSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (rs *replicationService) Sync(ctx context.Context) (int, error) {
+	merged := 0
+	var lastErr error
+	for _, peer := range rs.config.Peers {
+		ctx, cancel := context.WithTimeout(ctx, rs.config.Timeout)
+		snapshot, err := rs.fetch(ctx, peer)
+		if err == nil {
+			var n int
+			n, err = rs.replicationRepo.Merge(ctx, *snapshot)
+			merged += n
+		}
+		cancel()
+		if err != nil {
+			ReplicationSyncCounter.WithLabelValues("error").Inc()
+			rs.logger.Error("Failed to sync peer", zap.String("peer", peer), zap.Error(err))
+			lastErr = err
+			continue
+		}
+		ReplicationSyncCounter.WithLabelValues("success").Inc()
+	}
+	return merged, lastErr
+}
+
+func (rs *replicationService) Run(ctx context.Context) {
+	if rs.config.Interval <= 0 {
+		rs.logger.Error("Replication interval should be positive", zap.Duration("interval", rs.config.Interval))
+		return
+	}
+	ticker := time.NewTicker(rs.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if merged, err := rs.Sync(ctx); err == nil {
+				rs.logger.Debug("Replication synced", zap.Int("merged", merged))
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type ReplicationServiceSuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	peer        *httptest.Server
+	broken      *httptest.Server
+	cacheRepo   repository.CacheCounterRepository
+	rs          ReplicationService
+}
+
+func (suite *ReplicationServiceSuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	redisCli := fbRedis.NewRedis(host[0], host[1], "")
+	suite.cacheRepo = repository.NewCacheCounterRepository(redisCli, usecase.XXHasher{}, zap.NewExample(), repository.WithGlobalRanking())
+
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 10, FstStr: "a", SndStr: "b"}
+	hash, err := usecase.GetIdentityHash(usecase.XXHasher{}, request)
+	suite.Require().NoError(err)
+	data, err := request.ToBytes()
+	suite.Require().NoError(err)
+	suite.peer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ReplicationSnapshotPath || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(domain.ReplicaSnapshot{
+			Replica: "us",
+			Counters: []domain.ReplicaCounter{
+				{Key: hash, Data: string(data), Components: domain.GCounter{"us": 4, "asia": 1}},
+			},
+		})
+	}))
+	suite.broken = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	suite.rs = NewReplicationService(repository.NewReplicationRepository(redisCli, usecase.XXHasher{}, "eu", zap.NewExample()),
+		ReplicationConfig{
+			Peers:    []string{suite.broken.URL, suite.peer.URL + "/"},
+			Token:    "token",
+			Interval: time.Minute,
+			Timeout:  time.Second,
+		}, zap.NewExample())
+	suite.Require().NoError(suite.cacheRepo.IncrementRequest(context.Background(), request, ""))
+}
+
+func (suite *ReplicationServiceSuite) TearDownTest() {
+	suite.peer.Close()
+	suite.broken.Close()
+	suite.redisServer.Close()
+}
+
+func (suite *ReplicationServiceSuite) TestSync() {
+	// The broken peer doesn't prevent the other one to be merged
+	merged, err := suite.rs.Sync(context.Background())
+	suite.ErrorIs(err, ErrReplicationPeer)
+	suite.Equal(1, merged)
+
+	top, err := suite.rs.Top(10)
+	suite.Require().NoError(err)
+	suite.Require().Len(top, 1)
+	suite.Equal(6, top[0].Score)
+	suite.Equal(domain.GCounter{"eu": 1, "us": 4, "asia": 1}, top[0].Components)
+
+	snapshot, err := suite.rs.Snapshot()
+	suite.Require().NoError(err)
+	suite.Equal("eu", snapshot.Replica)
+	suite.Equal(top[0].Components, snapshot.Counters[0].Components)
+}
+
+func TestReplicationServiceSuite(t *testing.T) {
+	suite.Run(t, new(ReplicationServiceSuite))
+}
@@ -0,0 +1,88 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/rule_service.go -source rule_service.go
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/expr"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type RuleConfig struct {
+	// ExprSteps bounds the steps of every rule of a request, expressions included
+	ExprSteps int64
+	// ExprTimeout bounds the time spent in the expressions of a request
+	ExprTimeout time.Duration
+	// ExprCacheSize is the number of compiled expressions kept
+	ExprCacheSize int
+}
+
+type RuleService interface {
+	// Evaluate replaces each number up to the limit by the words of its matching
+	// rules, it returns domain.RuleErrors when a rule is invalid or an expression
+	// fails, and wraps expr.ErrStepBudget or expr.ErrTimeBudget when a budget is
+	// exhausted.
+	Evaluate(request domain.RulesRequest) ([]string, error)
+}
+
+type ruleService struct {
+	config RuleConfig
+	cache  *expr.Cache
+	logger *zap.Logger
+}
+
+func NewRuleService(config RuleConfig, logger *zap.Logger) RuleService {
+	return &ruleService{config: config, cache: expr.NewCache(config.ExprCacheSize), logger: logger}
+}
+
+func (rs *ruleService) Evaluate(request domain.RulesRequest) ([]string, error) {
+	budget := expr.NewBudget(rs.config.ExprSteps, rs.config.ExprTimeout)
+	rules, err := request.Parse(domain.RuleEnv{Compile: rs.cache.Compile, Budget: budget})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, request.Limit)
+	buf := make([]byte, 0, 64)
+	for i := range res {
+		nb := i + 1
+		buf = buf[:0]
+		for r, rule := range rules {
+			// Built-in rules share the budget of the expressions
+			if !budget.Spend(rule.Rule.Cost(nb)) {
+				return nil, exprError(budget.Err(), r, nb)
+			}
+			if rule.Rule.Match(nb) {
+				buf = rule.Word.AppendTo(buf, int64(nb))
+			}
+			if err := budget.Err(); err != nil {
+				return nil, exprError(err, r, nb)
+			}
+		}
+		if len(buf) == 0 {
+			res[i] = strconv.Itoa(nb)
+		} else {
+			res[i] = string(buf)
+		}
+	}
+	return res, nil
+}
+
+// exprError reports the failure of an expression of the r-th rule
+func exprError(err error, r, nb int) error {
+	field := fmt.Sprintf("rules[%d]", r)
+	var exprErr *expr.Error
+	if errors.As(err, &exprErr) {
+		return domain.RuleErrors{{
+			Field:    field,
+			Message:  fmt.Sprintf("%s for n = %d", exprErr.Message, nb),
+			Position: exprErr.Pos,
+		}}
+	}
+	return fmt.Errorf("%s at n = %d: %w", field, nb, err)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/expr"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRuleEvaluate(t *testing.T) {
+	three, nine := 3, 9
+	s := NewRuleService(RuleConfig{ExprSteps: 1000, ExprTimeout: time.Second, ExprCacheSize: 10}, zap.NewExample())
+	res, err := s.Evaluate(domain.RulesRequest{
+		Limit: 15,
+		Rules: []domain.RuleSpec{
+			{Type: "divisible", Mod: 3, Str: "fizz"},
+			{Type: "contains_digit", Digit: &three, Str: "three"},
+			{Type: "all", Str: "!", Rules: []domain.RuleSpec{
+				{Type: "perfect_square"},
+				{Type: "not", Rule: &domain.RuleSpec{Type: "range", Min: &nine, Max: &nine}},
+			}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"!", "2", "fizzthree", "!", "5", "fizz", "7", "8", "fizz", "10", "11", "fizz", "three", "14", "fizz",
+	}, res)
+
+	_, err = s.Evaluate(domain.RulesRequest{Limit: 15, Rules: []domain.RuleSpec{{Type: "divisible", Str: "fizz"}}})
+	assert.Equal(t, domain.RuleErrors{{Field: "rules[0].mod", Message: "Should be greater than or equal to 1"}}, err)
+}
+
+func TestRuleEvaluateExpr(t *testing.T) {
+	s := NewRuleService(RuleConfig{ExprSteps: 1000, ExprTimeout: time.Second, ExprCacheSize: 10}, zap.NewExample())
+	res, err := s.Evaluate(domain.RulesRequest{
+		Limit: 8,
+		Rules: []domain.RuleSpec{
+			{Type: "expr", Expr: "n % 3 == 0 && n > 3 || digits(n) contains 7", Str: "fizz"},
+			{Type: "not", Str: "!", Rule: &domain.RuleSpec{Type: "expr", Expr: "n < 8"}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "4", "5", "fizz", "fizz", "!"}, res)
+
+	_, err = s.Evaluate(domain.RulesRequest{
+		Limit: 8,
+		Rules: []domain.RuleSpec{
+			{Type: "prime", Str: "prime"},
+			{Type: "expr", Expr: "10 / (n - 4) > 1", Str: "fizz"},
+		},
+	})
+	assert.Equal(t, domain.RuleErrors{{Field: "rules[1]", Message: "Division by zero for n = 4", Position: 4}}, err)
+
+	_, err = s.Evaluate(domain.RulesRequest{
+		Limit: 1000,
+		Rules: []domain.RuleSpec{{Type: "expr", Expr: "n > 0", Str: "fizz"}},
+	})
+	assert.ErrorIs(t, err, expr.ErrStepBudget)
+}
+
+func TestRuleEvaluateBuiltinBudget(t *testing.T) {
+	s := NewRuleService(RuleConfig{ExprSteps: 100_000, ExprTimeout: time.Second, ExprCacheSize: 10}, zap.NewExample())
+	// Built-in rules without expression are bounded as well
+	_, err := s.Evaluate(domain.RulesRequest{
+		Limit: 1_000_000,
+		Rules: []domain.RuleSpec{{Type: "prime", Str: "prime"}, {Type: "prime", Str: "again"}},
+	})
+	assert.ErrorIs(t, err, expr.ErrStepBudget)
+}
@@ -0,0 +1,84 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/series_service.go -source series_service.go
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/repository"
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrSeriesUnknownResolution = errors.New("unknown series resolution")
+	ErrSeriesInvalidRange      = errors.New("series should start before it ends")
+	ErrSeriesTooManyPoints     = errors.New("series has too many points")
+	ErrSeriesNotFound          = errors.New("no counter for this key")
+)
+
+const (
+	// MaxSeriesPoints is the maximum number of buckets returned at once
+	MaxSeriesPoints = 10_000
+	// defaultSeriesPoints is the number of buckets returned without a start
+	defaultSeriesPoints = 60
+)
+
+type SeriesService interface {
+	// Get returns the zero-filled buckets of the counter key from from to to, they
+	// default to the last buckets when zero.
+	Get(key, resolution string, from, to time.Time) ([]domain.SeriesPoint, error)
+}
+
+type seriesService struct {
+	seriesRepo  repository.SeriesRepository
+	cacheRepo   repository.CacheCounterRepository
+	resolutions map[string]domain.SeriesResolution
+	logger      *zap.Logger
+}
+
+func NewSeriesService(seriesRepo repository.SeriesRepository,
+	cacheRepo repository.CacheCounterRepository,
+	resolutions []domain.SeriesResolution,
+	logger *zap.Logger) SeriesService {
+	ss := &seriesService{
+		seriesRepo:  seriesRepo,
+		cacheRepo:   cacheRepo,
+		resolutions: make(map[string]domain.SeriesResolution, len(resolutions)),
+		logger:      logger,
+	}
+	for _, resolution := range resolutions {
+		ss.resolutions[resolution.Name] = resolution
+	}
+	return ss
+}
+
+func (ss *seriesService) Get(key, name string, from, to time.Time) ([]domain.SeriesPoint, error) {
+	resolution, ok := ss.resolutions[name]
+	if !ok {
+		return nil, ErrSeriesUnknownResolution
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-(defaultSeriesPoints - 1) * resolution.Step)
+	}
+	if to.Before(from) {
+		return nil, ErrSeriesInvalidRange
+	}
+	if to.Sub(from)/resolution.Step >= MaxSeriesPoints {
+		return nil, ErrSeriesTooManyPoints
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := ss.cacheRepo.GetScore(ctx, key); errors.Is(err, repository.ErrCacheKeyNotFound) {
+		return nil, ErrSeriesNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return ss.seriesRepo.Get(ctx, key, resolution, from, to)
+}
@@ -0,0 +1,385 @@
+package service
+
+//go:generate ../.deps/mockgen -destination mock/webhook_service.go -source webhook_service.go
+
+import (
+	"FizzBuzz"
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+
+	WebhookDeliveriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: FizzBuzz.PrometheusNamespace,
+		Subsystem: "webhook",
+		Name:      "deliveries",
+		Help:      "count webhook deliveries by result",
+	}, []string{"result"})
+)
+
+const (
+	// leaderClaimTTL avoids notifying twice the same leader change seen by every instance
+	leaderClaimTTL = time.Minute
+	// subscriptionsRefresh is how long subscriptions are cached by each instance
+	subscriptionsRefresh = 10 * time.Second
+)
+
+type WebhookConfig struct {
+	Workers     int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	Timeout     time.Duration
+}
+
+type WebhookService interface {
+	Create(sub domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	Get(id string) (*domain.WebhookSubscription, error)
+	List() ([]domain.WebhookSubscription, error)
+	Delete(id string) error
+	DeadLetters(count int64) ([]domain.WebhookDelivery, error)
+	// Run watches counters and delivers notifications until ctx is done
+	Run(ctx context.Context) error
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	cacheRepo   repository.CacheCounterRepository
+	config      WebhookConfig
+	client      *http.Client
+	deliveries  chan domain.WebhookDelivery
+
+	mu         sync.Mutex
+	subs       []domain.WebhookSubscription
+	subsLoaded time.Time
+	leader     domain.MetricCounterScore
+
+	logger *zap.Logger
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository,
+	cacheRepo repository.CacheCounterRepository,
+	config WebhookConfig,
+	logger *zap.Logger) WebhookService {
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		cacheRepo:   cacheRepo,
+		config:      config,
+		client:      &http.Client{Timeout: config.Timeout},
+		deliveries:  make(chan domain.WebhookDelivery, 1024),
+		logger:      logger,
+	}
+}
+
+func (ws *webhookService) timeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 500*time.Millisecond)
+}
+
+func (ws *webhookService) Create(sub domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	var err error
+	if sub.ID, err = usecase.RandomID(8); err != nil {
+		return nil, err
+	}
+	if sub.Secret == "" {
+		if sub.Secret, err = usecase.RandomID(32); err != nil {
+			return nil, err
+		}
+	}
+	sub.CreatedAt = time.Now().UTC()
+
+	ctx, cancel := ws.timeout()
+	defer cancel()
+	if err := ws.webhookRepo.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	ws.invalidate()
+	return &sub, nil
+}
+
+func (ws *webhookService) Get(id string) (*domain.WebhookSubscription, error) {
+	ctx, cancel := ws.timeout()
+	defer cancel()
+	sub, err := ws.webhookRepo.GetSubscription(ctx, id)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	return sub, err
+}
+
+func (ws *webhookService) List() ([]domain.WebhookSubscription, error) {
+	ctx, cancel := ws.timeout()
+	defer cancel()
+	return ws.webhookRepo.ListSubscriptions(ctx)
+}
+
+func (ws *webhookService) Delete(id string) error {
+	ctx, cancel := ws.timeout()
+	defer cancel()
+	err := ws.webhookRepo.DeleteSubscription(ctx, id)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		return ErrWebhookNotFound
+	}
+	ws.invalidate()
+	return err
+}
+
+func (ws *webhookService) DeadLetters(count int64) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := ws.timeout()
+	defer cancel()
+	return ws.webhookRepo.ListDeadLetters(ctx, count)
+}
+
+func (ws *webhookService) invalidate() {
+	ws.mu.Lock()
+	ws.subsLoaded = time.Time{}
+	ws.mu.Unlock()
+}
+
+func (ws *webhookService) subscriptions(ctx context.Context) []domain.WebhookSubscription {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if time.Since(ws.subsLoaded) < subscriptionsRefresh {
+		return ws.subs
+	}
+
+	subs, err := ws.webhookRepo.ListSubscriptions(ctx)
+	if err != nil {
+		ws.logger.Error("Failed to load webhook subscriptions", zap.Error(err))
+		return ws.subs
+	}
+	ws.subs = subs
+	ws.subsLoaded = time.Now()
+	return subs
+}
+
+func (ws *webhookService) Run(ctx context.Context) error {
+	changes := watchChanges(ctx, ws.cacheRepo, ws.logger)
+
+	for i := 0; i < ws.config.Workers; i++ {
+		go ws.work(ctx)
+	}
+
+	// The current leader isn't a change
+	if top, err := ws.cacheRepo.GetCounters(ctx, -1, -1); err == nil && len(top) > 0 {
+		ws.leader = top[0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case batch, ok := <-changes:
+			if !ok {
+				return ctx.Err()
+			}
+			for _, change := range batch {
+				ws.handleChange(ctx, change)
+			}
+		}
+	}
+}
+
+func (ws *webhookService) handleChange(ctx context.Context, change domain.CounterChange) {
+	subs := ws.subscriptions(ctx)
+	for i := range subs {
+		sub := &subs[i]
+		if sub.Wants(domain.WebhookThresholdCrossed) && sub.Threshold > 0 && ws.crossed(ctx, sub, change) {
+			ws.notify(ctx, sub, "", 0, domain.WebhookPayload{
+				Event:     domain.WebhookThresholdCrossed,
+				Key:       change.Key,
+				Score:     change.Score,
+				Threshold: sub.Threshold,
+			})
+		}
+	}
+
+	if change.Key == ws.leader.Key {
+		ws.leader.ScoreCounter = change.Score
+		return
+	}
+	if change.Score < ws.leader.ScoreCounter {
+		return
+	}
+
+	// Ties are resolved by redis, ask it who is first
+	top, err := ws.cacheRepo.GetCounters(ctx, -1, -1)
+	if err != nil || len(top) == 0 {
+		return
+	}
+	previous := ws.leader
+	ws.leader = top[0]
+	if previous.Key == ws.leader.Key {
+		return
+	}
+	for i := range subs {
+		sub := &subs[i]
+		if !sub.Wants(domain.WebhookLeaderChanged) {
+			continue
+		}
+		ws.notify(ctx, sub, fmt.Sprintf("%s/leader/%s/%s", sub.ID, previous.Key, ws.leader.Key), leaderClaimTTL,
+			domain.WebhookPayload{
+				Event:       domain.WebhookLeaderChanged,
+				Key:         ws.leader.Key,
+				Score:       ws.leader.ScoreCounter,
+				PreviousKey: previous.Key,
+			})
+	}
+}
+
+// crossed tells if the counter went over the threshold of the subscription since
+// its last change seen, the score may jump as changes can be missed or merged.
+// Only one instance sees the crossing as the scores are tracked atomically.
+func (ws *webhookService) crossed(ctx context.Context, sub *domain.WebhookSubscription, change domain.CounterChange) bool {
+	previous, known, err := ws.webhookRepo.TrackScore(ctx, sub.ID, change.Key, change.Score)
+	if err != nil {
+		ws.logger.Error("Failed to track webhook score", zap.Error(err))
+		return false
+	}
+	if !known {
+		// Never seen by the subscription, the change is an increment
+		previous = change.Score - 1
+	}
+	return previous < sub.Threshold && sub.Threshold <= change.Score
+}
+
+// notify queues the payload, once for every instance when a fingerprint is given
+func (ws *webhookService) notify(ctx context.Context,
+	sub *domain.WebhookSubscription,
+	fingerprint string,
+	ttl time.Duration,
+	payload domain.WebhookPayload) {
+	if fingerprint != "" {
+		claimed, err := ws.webhookRepo.Claim(ctx, fingerprint, ttl)
+		if err != nil {
+			ws.logger.Error("Failed to claim webhook event", zap.Error(err))
+			return
+		}
+		if !claimed {
+			return
+		}
+	}
+
+	payload.Timestamp = time.Now().UTC()
+	if data, err := ws.cacheRepo.GetData(ctx, payload.Key); err == nil {
+		if request := domain.FromStrToRequest(data); request != nil {
+			payload.Request = &domain.CountedRequest{Identifiable: request}
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		ws.logger.Error("Failed to marshal webhook payload", zap.Error(err))
+		return
+	}
+	id, err := usecase.RandomID(8)
+	if err != nil {
+		return
+	}
+
+	delivery := domain.WebhookDelivery{
+		ID:             id,
+		SubscriptionID: sub.ID,
+		URL:            sub.URL,
+		Event:          payload.Event,
+		Payload:        body,
+	}
+	select {
+	case ws.deliveries <- delivery:
+	default:
+		ws.deadLetter(delivery, "delivery queue is full")
+	}
+}
+
+func (ws *webhookService) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delivery := <-ws.deliveries:
+			ws.deliver(ctx, delivery)
+		}
+	}
+}
+
+// deliver retries with an exponential backoff before giving up to the dead letters
+func (ws *webhookService) deliver(ctx context.Context, delivery domain.WebhookDelivery) {
+	backoff := ws.config.BaseBackoff
+	for delivery.Attempts < ws.config.MaxAttempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		delivery.Attempts++
+		err := ws.send(ctx, delivery)
+		if err == nil {
+			WebhookDeliveriesCounter.WithLabelValues("success").Inc()
+			return
+		}
+		delivery.LastError = err.Error()
+		WebhookDeliveriesCounter.WithLabelValues("retry").Inc()
+		ws.logger.Debug("Webhook delivery failed",
+			zap.String("url", delivery.URL),
+			zap.Int("attempt", delivery.Attempts),
+			zap.Error(err))
+	}
+
+	ws.deadLetter(delivery, delivery.LastError)
+}
+
+func (ws *webhookService) send(ctx context.Context, delivery domain.WebhookDelivery) error {
+	// The secret may have been rotated since the event
+	ctxGet, cancel := ws.timeout()
+	sub, err := ws.webhookRepo.GetSubscription(ctxGet, delivery.SubscriptionID)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Fizzbuzz-Event", string(delivery.Event))
+	req.Header.Set("X-Fizzbuzz-Delivery", delivery.ID)
+	req.Header.Set("X-Fizzbuzz-Signature", usecase.SignPayload(sub.Secret, delivery.Payload))
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (ws *webhookService) deadLetter(delivery domain.WebhookDelivery, reason string) {
+	WebhookDeliveriesCounter.WithLabelValues("dead").Inc()
+	delivery.LastError = reason
+	delivery.FailedAt = time.Now().UTC()
+	ctx, cancel := ws.timeout()
+	defer cancel()
+	if err := ws.webhookRepo.PushDeadLetter(ctx, delivery); err != nil {
+		ws.logger.Error("Failed to save webhook dead letter", zap.Error(err))
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"FizzBuzz/domain"
+	"FizzBuzz/domain/usecase"
+	"FizzBuzz/repository"
+	fbRedis "FizzBuzz/repository/redis"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type receivedWebhook struct {
+	signature string
+	body      []byte
+	payload   domain.WebhookPayload
+}
+
+type WebhookServiceSuite struct {
+	suite.Suite
+	redisServer *miniredis.Miniredis
+	cacheRepo   repository.CacheCounterRepository
+	ws          WebhookService
+	cancel      context.CancelFunc
+
+	receiver *httptest.Server
+	status   int
+	mu       sync.Mutex
+	received []receivedWebhook
+}
+
+func (suite *WebhookServiceSuite) SetupTest() {
+	var err error
+	suite.redisServer, err = miniredis.Run()
+	suite.Require().NoError(err)
+
+	logger := zap.NewExample()
+	host := strings.Split(suite.redisServer.Addr(), ":")
+	redisCli := fbRedis.NewRedis(host[0], host[1], "")
+	suite.cacheRepo = repository.NewCacheCounterRepository(redisCli, usecase.XXHasher{}, logger)
+	suite.ws = NewWebhookService(repository.NewWebhookRepository(redisCli, logger), suite.cacheRepo,
+		WebhookConfig{Workers: 1, MaxAttempts: 2, BaseBackoff: time.Millisecond, Timeout: time.Second},
+		logger)
+
+	suite.status = http.StatusOK
+	suite.received = nil
+	suite.receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload domain.WebhookPayload
+		suite.NoError(json.Unmarshal(body, &payload))
+		suite.mu.Lock()
+		suite.received = append(suite.received, receivedWebhook{
+			signature: r.Header.Get("X-Fizzbuzz-Signature"),
+			body:      body,
+			payload:   payload,
+		})
+		status := suite.status
+		suite.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+}
+
+func (suite *WebhookServiceSuite) start() {
+	var ctx context.Context
+	ctx, suite.cancel = context.WithCancel(context.Background())
+	go func() {
+		_ = suite.ws.Run(ctx)
+	}()
+	// Let the service subscribe to the counters
+	time.Sleep(50 * time.Millisecond)
+}
+
+func (suite *WebhookServiceSuite) TearDownTest() {
+	if suite.cancel != nil {
+		suite.cancel()
+	}
+	suite.receiver.Close()
+	suite.redisServer.Close()
+}
+
+func (suite *WebhookServiceSuite) increment(fstStr string, times int) {
+	request := &domain.FizzBuzzRequest{FstModulo: 3, SndModulo: 5, Limit: 15, FstStr: fstStr, SndStr: "buzz"}
+	for i := 0; i < times; i++ {
+		suite.Require().NoError(suite.cacheRepo.IncrementRequest(context.Background(), request, ""))
+	}
+}
+
+func (suite *WebhookServiceSuite) waitReceived(count int) []receivedWebhook {
+	suite.Require().Eventually(func() bool {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		return len(suite.received) >= count
+	}, 2*time.Second, 10*time.Millisecond)
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	return append([]receivedWebhook{}, suite.received...)
+}
+
+func (suite *WebhookServiceSuite) TestNotifications() {
+	sub, err := suite.ws.Create(domain.WebhookSubscription{
+		URL:       suite.receiver.URL,
+		Events:    []domain.WebhookEvent{domain.WebhookLeaderChanged, domain.WebhookThresholdCrossed},
+		Threshold: 3,
+	})
+	suite.Require().NoError(err)
+	suite.NotEmpty(sub.Secret)
+
+	suite.increment("fizz", 1)
+	suite.start()
+
+	// foo takes the lead on its second request, then crosses the threshold
+	suite.increment("foo", 3)
+	received := suite.waitReceived(2)
+	suite.Len(received, 2)
+
+	suite.Equal(domain.WebhookLeaderChanged, received[0].payload.Event)
+	suite.Equal("foo", received[0].payload.Request.FizzBuzz().FstStr)
+	suite.NotEmpty(received[0].payload.PreviousKey)
+	suite.Equal(domain.WebhookThresholdCrossed, received[1].payload.Event)
+	suite.Equal(3, received[1].payload.Score)
+	suite.Equal(3, received[1].payload.Threshold)
+	for _, r := range received {
+		suite.True(usecase.VerifySignature(sub.Secret, r.body, r.signature))
+	}
+}
+
+func (suite *WebhookServiceSuite) TestThresholdJump() {
+	sub, err := suite.ws.Create(domain.WebhookSubscription{
+		URL:       suite.receiver.URL,
+		Events:    []domain.WebhookEvent{domain.WebhookThresholdCrossed},
+		Threshold: 3,
+	})
+	suite.Require().NoError(err)
+	ws := suite.ws.(*webhookService)
+	ctx := context.Background()
+
+	// The change reaching the threshold was missed, the next one is past it
+	ws.handleChange(ctx, domain.CounterChange{Key: "abc", Score: 1})
+	ws.handleChange(ctx, domain.CounterChange{Key: "abc", Score: 5})
+	ws.handleChange(ctx, domain.CounterChange{Key: "abc", Score: 6})
+	// Already over the threshold when first seen
+	ws.handleChange(ctx, domain.CounterChange{Key: "def", Score: 7})
+	suite.Require().Len(ws.deliveries, 1)
+	delivery := <-ws.deliveries
+	suite.Equal(sub.ID, delivery.SubscriptionID)
+	suite.Equal(domain.WebhookThresholdCrossed, delivery.Event)
+
+	// Scores are forgotten with the subscription
+	suite.Require().NoError(suite.ws.Delete(sub.ID))
+	suite.False(suite.redisServer.Exists(fbRedis.KeyWebhookScores(sub.ID)))
+}
+
+func (suite *WebhookServiceSuite) TestDeadLetter() {
+	suite.status = http.StatusInternalServerError
+	sub, err := suite.ws.Create(domain.WebhookSubscription{
+		URL:       suite.receiver.URL,
+		Events:    []domain.WebhookEvent{domain.WebhookThresholdCrossed},
+		Threshold: 1,
+	})
+	suite.Require().NoError(err)
+	suite.start()
+
+	suite.increment("fizz", 1)
+	suite.waitReceived(2)
+
+	var dead []domain.WebhookDelivery
+	suite.Require().Eventually(func() bool {
+		dead, err = suite.ws.DeadLetters(10)
+		suite.Require().NoError(err)
+		return len(dead) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	suite.Equal(sub.ID, dead[0].SubscriptionID)
+	suite.Equal(2, dead[0].Attempts)
+	suite.Contains(dead[0].LastError, "500")
+}
+
+func (suite *WebhookServiceSuite) TestSubscriptions() {
+	sub, err := suite.ws.Create(domain.WebhookSubscription{
+		URL:    "http://localhost/hook",
+		Secret: "secret",
+		Events: []domain.WebhookEvent{domain.WebhookLeaderChanged},
+	})
+	suite.Require().NoError(err)
+	suite.Equal("secret", sub.Secret)
+
+	got, err := suite.ws.Get(sub.ID)
+	suite.Require().NoError(err)
+	suite.Equal(sub.URL, got.URL)
+
+	subs, err := suite.ws.List()
+	suite.Require().NoError(err)
+	suite.Len(subs, 1)
+
+	suite.Require().NoError(suite.ws.Delete(sub.ID))
+	_, err = suite.ws.Get(sub.ID)
+	suite.ErrorIs(err, ErrWebhookNotFound)
+	suite.ErrorIs(suite.ws.Delete(sub.ID), ErrWebhookNotFound)
+}
+
+func TestWebhookServiceSuite(t *testing.T) {
+	suite.Run(t, new(WebhookServiceSuite))
+}